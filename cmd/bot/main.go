@@ -1,17 +1,27 @@
 package main
 
 import (
+	"context"
+	"fmt"
 	"log"
-	"os"
 	"os/signal"
+	"strings"
+	"sync"
 	"syscall"
+	"time"
 
 	"github.com/RedNessen/inpars-telegram-bot/internal/config"
 	"github.com/RedNessen/inpars-telegram-bot/internal/inpars"
 	"github.com/RedNessen/inpars-telegram-bot/internal/monitor"
+	"github.com/RedNessen/inpars-telegram-bot/internal/places"
+	"github.com/RedNessen/inpars-telegram-bot/internal/storage"
+	"github.com/RedNessen/inpars-telegram-bot/internal/subscription"
 	"github.com/RedNessen/inpars-telegram-bot/internal/telegram"
 )
 
+// shutdownTimeout время на завершение текущей рассылки при остановке
+const shutdownTimeout = 30 * time.Second
+
 func main() {
 	log.Println("Starting InPars Telegram Bot...")
 
@@ -23,43 +33,190 @@ func main() {
 
 	log.Println("Configuration loaded successfully")
 
+	// Контекст отменяется по SIGINT/SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
 	// Создание клиента InPars API
-	inparsClient := inpars.NewClient(cfg.InParsToken)
+	inparsClient := inpars.NewClient(cfg.InParsToken,
+		inpars.WithRateLimit(cfg.InParsRateLimit, time.Minute),
+		inpars.WithMaxRetries(cfg.InParsMaxRetries),
+	)
 	log.Println("InPars API client initialized")
 
+	// Справочники регионов, городов и метро кэшируются в файле
+	ref := inpars.NewReference(inparsClient, cfg.ReferenceCachePath, time.Duration(cfg.ReferenceTTLHours)*time.Hour)
+	err = resolveDefaultNames(ctx, ref, cfg)
+	if ctx.Err() != nil {
+		log.Println("Stopped during startup")
+		return
+	}
+	if err != nil {
+		log.Fatalf("Failed to resolve default subscription: %v", err)
+	}
+
+	// Открытие хранилища состояния
+	store, err := openStorage(cfg.StoragePath)
+	if err != nil {
+		log.Fatalf("Failed to open storage: %v", err)
+	}
+	log.Printf("Storage opened: %s", cfg.StoragePath)
+
+	// Подписки чатов; фильтры из конфигурации используются как подписка по умолчанию
+	subs, err := subscription.NewManager(subscription.FromConfig(cfg), store)
+	if err != nil {
+		log.Fatalf("Failed to load subscriptions: %v", err)
+	}
+
+	// Места чатов для расчета расстояний в карточках
+	chatPlaces, err := places.NewManager(store)
+	if err != nil {
+		log.Fatalf("Failed to load places: %v", err)
+	}
+
 	// Создание Telegram бота
-	bot, err := telegram.NewBot(cfg.TelegramToken)
+	bot, err := telegram.NewBot(cfg.TelegramToken, inparsClient, ref, subs, chatPlaces, store)
 	if err != nil {
 		log.Fatalf("Failed to create Telegram bot: %v", err)
 	}
 	log.Println("Telegram bot initialized")
 
 	// Создание монитора
-	mon := monitor.NewMonitor(inparsClient, bot, cfg)
+	mon := monitor.NewMonitor(inparsClient, bot, subs, chatPlaces, store, cfg)
 	log.Println("Monitor initialized")
 
+	var wg sync.WaitGroup
+
 	// Запуск бота в отдельной горутине
+	wg.Add(1)
 	go func() {
-		if err := bot.Start(); err != nil {
-			log.Fatalf("Bot stopped with error: %v", err)
+		defer wg.Done()
+		if err := bot.Start(ctx); err != nil {
+			log.Printf("Bot stopped with error: %v", err)
+			stop()
 		}
 	}()
 
 	// Запуск монитора в отдельной горутине
+	wg.Add(1)
 	go func() {
-		if err := mon.Start(); err != nil {
-			log.Fatalf("Monitor stopped with error: %v", err)
+		defer wg.Done()
+		if err := mon.Start(ctx); err != nil {
+			log.Printf("Monitor stopped with error: %v", err)
+			stop()
 		}
 	}()
 
 	log.Println("Bot and monitor are running. Press Ctrl+C to stop.")
 
 	// Ожидание сигнала завершения
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
+	<-ctx.Done()
+	log.Println("Shutting down, finishing current delivery batch...")
+
+	// Ждем завершения текущей рассылки, но не дольше shutdownTimeout
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		log.Println("All workers stopped")
+	case <-time.After(shutdownTimeout):
+		log.Printf("Workers did not stop within %s, exiting anyway", shutdownTimeout)
+	}
 
-	log.Println("Shutting down...")
 	log.Println(mon.GetStatus())
+
+	// Сохраняем состояние на диск
+	if err := store.Close(); err != nil {
+		log.Printf("Failed to close storage: %v", err)
+	}
 	log.Println("Goodbye!")
 }
+
+// openStorage открывает файловое хранилище или хранилище в памяти для ":memory:"
+func openStorage(path string) (storage.Storage, error) {
+	if path == ":memory:" {
+		return storage.NewMemory(), nil
+	}
+	return storage.OpenBolt(path)
+}
+
+// resolveDefaultNames находит по справочнику ID регионов и городов, заданных
+// в DEFAULT_REGIONS и DEFAULT_CITIES названиями. Название должно однозначно
+// соответствовать одному региону или городу: иначе подписка по умолчанию
+// следила бы не за тем регионом или за всеми сразу, поэтому это ошибка запуска
+func resolveDefaultNames(ctx context.Context, ref *inpars.Reference, cfg *config.Config) error {
+	for _, name := range cfg.DefaultRegionNames {
+		regions, err := ref.FindRegions(ctx, name)
+		if err != nil {
+			return fmt.Errorf("failed to resolve default region %q: %w", name, err)
+		}
+		if len(regions) != 1 {
+			return fmt.Errorf("default region %q matches %d regions%s, use its ID instead", name, len(regions), regionTitles(regions))
+		}
+		log.Printf("Default region %q resolved to %s (%d)", name, regions[0].Title, regions[0].ID)
+		cfg.DefaultRegions = append(cfg.DefaultRegions, regions[0].ID)
+	}
+
+	searchRegions := cfg.DefaultRegions
+	if len(searchRegions) == 0 {
+		searchRegions = []int{0} // Все регионы
+	}
+	for _, name := range cfg.DefaultCityNames {
+		found, err := findCities(ctx, ref, searchRegions, name)
+		if err != nil {
+			return fmt.Errorf("failed to resolve default city %q: %w", name, err)
+		}
+		if len(found) != 1 {
+			return fmt.Errorf("default city %q matches %d cities%s, use its ID instead", name, len(found), cityTitles(found))
+		}
+		log.Printf("Default city %q resolved to %s (%d)", name, found[0].Title, found[0].ID)
+		cfg.DefaultCities = append(cfg.DefaultCities, found[0].ID)
+	}
+	return nil
+}
+
+// regionTitles перечисляет подошедшие регионы для сообщения об ошибке
+func regionTitles(regions []inpars.Region) string {
+	titles := make([]string, len(regions))
+	for i, region := range regions {
+		titles[i] = fmt.Sprintf("%s (%d)", region.Title, region.ID)
+	}
+	return matchList(titles)
+}
+
+// cityTitles перечисляет подошедшие города для сообщения об ошибке
+func cityTitles(cities []inpars.City) string {
+	titles := make([]string, len(cities))
+	for i, city := range cities {
+		titles[i] = fmt.Sprintf("%s (%d)", city.Title, city.ID)
+	}
+	return matchList(titles)
+}
+
+// matchList форматирует список совпадений: «: A (1), B (2)», не больше пяти
+func matchList(titles []string) string {
+	if len(titles) == 0 {
+		return ""
+	}
+	if len(titles) > 5 {
+		titles = append(titles[:5:5], "...")
+	}
+	return ": " + strings.Join(titles, ", ")
+}
+
+// findCities ищет город по названию в нескольких регионах
+func findCities(ctx context.Context, ref *inpars.Reference, regionIDs []int, name string) ([]inpars.City, error) {
+	var found []inpars.City
+	for _, regionID := range regionIDs {
+		cities, err := ref.FindCities(ctx, regionID, name)
+		if err != nil {
+			return nil, err
+		}
+		found = append(found, cities...)
+	}
+	return found, nil
+}
@@ -5,6 +5,8 @@ import (
 	"os"
 	"strconv"
 	"strings"
+
+	"github.com/RedNessen/inpars-telegram-bot/internal/rule"
 )
 
 // Config содержит конфигурацию приложения
@@ -13,42 +15,88 @@ type Config struct {
 	TelegramToken string
 
 	// InPars API
-	InParsToken string
+	InParsToken      string
+	InParsRateLimit  int // Допустимое количество запросов к API в минуту
+	InParsMaxRetries int // Количество повторов при 429, 5xx и сетевых ошибках
+
+	// Хранилище состояния
+	StoragePath string // Путь к файлу базы данных (":memory:" - без сохранения на диск)
+
+	// Кэш справочников регионов, городов и метро
+	ReferenceCachePath string // Путь к файлу кэша (пустая строка - без сохранения на диск)
+	ReferenceTTLHours  int    // Срок хранения справочников в часах (0 - бессрочно)
 
 	// Настройки мониторинга
-	PollInterval    int   // Интервал опроса API в секундах
-	MaxListings     int   // Максимальное количество объявлений за один запрос
+	PollInterval    int // Интервал опроса API в секундах
+	MaxListings     int // Максимальное количество объявлений за один запрос
+	MaxPagesPerPoll int // Максимальное количество страниц за один цикл проверки
+	CatchUpMax      int // Сколько пропущенных за время простоя объявлений отправлять в чат
+
+	// Уведомления об изменениях отправленных объявлений
+	UpdatePollInterval  int // Интервал проверки изменений в секундах (0 - отключено)
+	PriceDropMinPercent int // Минимальное снижение цены в процентах для уведомления
+	PriceRiseMinPercent int // Минимальный рост цены в процентах для уведомления (0 - не уведомлять)
+	WatchPollInterval   int // Интервал проверки объявлений из /watch в секундах (0 - отключено)
+
+	// Поиск копий объявления на разных площадках
+	DedupWindowHours    int // Сколько часов искать копии отправленного объявления (0 - отключено)
+	DedupPriceTolerance int // Допустимая разница цены копий в процентах
+	DedupMaxDistance    int // Допустимое расстояние между координатами копий в метрах
 
 	// Фильтры по умолчанию
-	DefaultRegions  []int // ID регионов для мониторинга
-	DefaultCities   []int // ID городов
-	TypeAd          []int // Типы объявлений (1-сдам по умолчанию)
-	SellerTypes     []int // Типы продавцов (1,2,3 - все)
+	DefaultRegions []int // ID регионов для мониторинга
+	DefaultCities  []int // ID городов
+	TypeAd         []int // Типы объявлений (1-сдам по умолчанию)
+	SellerTypes    []int // Типы продавцов (1,2,3 - все)
+
+	// Регионы и города, заданные названиями: ID находятся по справочнику при запуске
+	DefaultRegionNames []string
+	DefaultCityNames   []string
 
 	// Лимиты
-	MinCost         int
-	MaxCost         int
-	FloorMin        int // Минимальный этаж
-	FloorMax        int // Максимальный этаж
+	MinCost  int
+	MaxCost  int
+	FloorMin int // Минимальный этаж
+	FloorMax int // Максимальный этаж
+
+	// Правило для подписки по умолчанию (см. пакет rule)
+	DefaultRule string
 }
 
 // LoadFromEnv загружает конфигурацию из переменных окружения
 func LoadFromEnv() (*Config, error) {
 	cfg := &Config{
-		TelegramToken:   os.Getenv("TELEGRAM_BOT_TOKEN"),
-		InParsToken:     getEnvOrDefault("INPARS_API_TOKEN", "aEcS9UfAagInparSiv23aoa_vPzxqWvm"), // Тестовый токен по умолчанию
-		PollInterval:    getEnvAsInt("POLL_INTERVAL", 60),    // 60 секунд по умолчанию
-		MaxListings:     getEnvAsInt("MAX_LISTINGS", 50),     // 50 объявлений (лимит для тестового токена)
-		DefaultRegions:  getEnvAsIntSlice("DEFAULT_REGIONS", []int{77}), // Москва по умолчанию
-		DefaultCities:   getEnvAsIntSlice("DEFAULT_CITIES", []int{}),
-		TypeAd:          getEnvAsIntSlice("TYPE_AD", []int{1}), // 1 - сдам (аренда)
-		SellerTypes:     getEnvAsIntSlice("SELLER_TYPES", []int{1, 2, 3}), // Все типы
-		MinCost:         getEnvAsInt("MIN_COST", 0),
-		MaxCost:         getEnvAsInt("MAX_COST", 0),
-		FloorMin:        getEnvAsInt("FLOOR_MIN", 0),
-		FloorMax:        getEnvAsInt("FLOOR_MAX", 0),
+		TelegramToken:       os.Getenv("TELEGRAM_BOT_TOKEN"),
+		InParsToken:         getEnvOrDefault("INPARS_API_TOKEN", "aEcS9UfAagInparSiv23aoa_vPzxqWvm"), // Тестовый токен по умолчанию
+		InParsRateLimit:     getEnvAsInt("INPARS_RATE_LIMIT", 10),                                    // 10 запросов в минуту (тариф с API)
+		InParsMaxRetries:    getEnvAsInt("INPARS_MAX_RETRIES", 3),
+		StoragePath:         getEnvOrDefault("STORAGE_PATH", "data/bot.db"),
+		ReferenceCachePath:  getEnvOrDefault("REFERENCE_CACHE_PATH", "data/reference.json"),
+		ReferenceTTLHours:   getEnvAsInt("REFERENCE_TTL_HOURS", 168), // Неделя
+		PollInterval:        getEnvAsInt("POLL_INTERVAL", 60),        // 60 секунд по умолчанию
+		MaxListings:         getEnvAsInt("MAX_LISTINGS", 50),         // 50 объявлений (лимит для тестового токена)
+		MaxPagesPerPoll:     getEnvAsInt("MAX_PAGES_PER_POLL", 10),
+		CatchUpMax:          getEnvAsInt("CATCHUP_MAX_LISTINGS", 20),
+		UpdatePollInterval:  getEnvAsInt("UPDATE_POLL_INTERVAL", 600), // Раз в 10 минут
+		PriceDropMinPercent: getEnvAsInt("PRICE_DROP_MIN_PERCENT", 5),
+		PriceRiseMinPercent: getEnvAsInt("PRICE_RISE_MIN_PERCENT", 0),
+		WatchPollInterval:   getEnvAsInt("WATCH_POLL_INTERVAL", 1800), // Раз в 30 минут
+		DedupWindowHours:    getEnvAsInt("DEDUP_WINDOW_HOURS", 72),
+		DedupPriceTolerance: getEnvAsInt("DEDUP_PRICE_TOLERANCE", 3),
+		DedupMaxDistance:    getEnvAsInt("DEDUP_MAX_DISTANCE", 150),
+		TypeAd:              getEnvAsIntSlice("TYPE_AD", []int{1}),            // 1 - сдам (аренда)
+		SellerTypes:         getEnvAsIntSlice("SELLER_TYPES", []int{1, 2, 3}), // Все типы
+		MinCost:             getEnvAsInt("MIN_COST", 0),
+		MaxCost:             getEnvAsInt("MAX_COST", 0),
+		FloorMin:            getEnvAsInt("FLOOR_MIN", 0),
+		FloorMax:            getEnvAsInt("FLOOR_MAX", 0),
+		DefaultRule:         strings.TrimSpace(os.Getenv("DEFAULT_RULE")),
 	}
 
+	// Регионы и города можно задать названиями: «Москва,питер»
+	cfg.DefaultRegions, cfg.DefaultRegionNames = getEnvAsIDsOrNames("DEFAULT_REGIONS", []int{77}) // Москва по умолчанию
+	cfg.DefaultCities, cfg.DefaultCityNames = getEnvAsIDsOrNames("DEFAULT_CITIES", []int{})
+
 	// Валидация обязательных полей
 	if cfg.TelegramToken == "" {
 		return nil, fmt.Errorf("TELEGRAM_BOT_TOKEN is required")
@@ -58,6 +106,12 @@ func LoadFromEnv() (*Config, error) {
 		return nil, fmt.Errorf("INPARS_API_TOKEN is required")
 	}
 
+	if cfg.DefaultRule != "" {
+		if _, err := rule.Compile(cfg.DefaultRule); err != nil {
+			return nil, fmt.Errorf("invalid DEFAULT_RULE: %w", err)
+		}
+	}
+
 	return cfg, nil
 }
 
@@ -110,3 +164,28 @@ func getEnvAsIntSlice(key string, defaultValue []int) []int {
 	}
 	return result
 }
+
+// getEnvAsIDsOrNames возвращает ID и названия из переменной окружения.
+// Формат: "77,78", "Москва,Санкт-Петербург" или вперемешку. Значение по
+// умолчанию используется, только если переменная не задана
+func getEnvAsIDsOrNames(key string, defaultValue []int) ([]int, []string) {
+	valueStr := os.Getenv(key)
+	if strings.TrimSpace(valueStr) == "" {
+		return defaultValue, nil
+	}
+
+	var ids []int
+	var names []string
+	for _, part := range strings.Split(valueStr, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		if value, err := strconv.Atoi(part); err == nil {
+			ids = append(ids, value)
+		} else {
+			names = append(names, part)
+		}
+	}
+	return ids, names
+}
@@ -0,0 +1,170 @@
+package dedup
+
+import (
+	"math"
+	"strings"
+	"unicode"
+
+	"github.com/RedNessen/inpars-telegram-bot/internal/geo"
+	"github.com/RedNessen/inpars-telegram-bot/internal/inpars"
+)
+
+// Fingerprint признаки объявления, по которым сравниваются копии
+// одной квартиры с разных площадок
+type Fingerprint struct {
+	TypeAd  int
+	Rooms   int
+	Floor   int
+	Floors  int
+	Sq      float64
+	Cost    int
+	Address string // Нормализованный адрес
+	Lat     float64
+	Lng     float64
+}
+
+// FingerprintOf вычисляет отпечаток объявления
+func FingerprintOf(estate *inpars.Estate) Fingerprint {
+	return Fingerprint{
+		TypeAd:  estate.TypeAd,
+		Rooms:   estate.Rooms,
+		Floor:   estate.Floor,
+		Floors:  estate.Floors,
+		Sq:      estate.Sq,
+		Cost:    estate.Cost,
+		Address: NormalizeAddress(estate.Address),
+		Lat:     float64(estate.Lat),
+		Lng:     float64(estate.Lng),
+	}
+}
+
+// bucketKey ключ корзины индекса: дубликаты совпадают по этим полям точно
+type bucketKey struct {
+	typeAd int
+	rooms  int
+	floor  int
+}
+
+func (f Fingerprint) bucket() bucketKey {
+	return bucketKey{typeAd: f.TypeAd, rooms: f.Rooms, floor: f.Floor}
+}
+
+// Matches сообщает, описывают ли два отпечатка одну и ту же квартиру.
+// Площадь и цена должны быть известны и совпадать с допуском, а место -
+// совпадать по координатам в пределах MaxDistance или по нормализованному адресу
+func (f Fingerprint) Matches(other Fingerprint, opts Options) bool {
+	if f.Cost <= 0 || other.Cost <= 0 {
+		return false
+	}
+	diff := math.Abs(float64(f.Cost - other.Cost))
+	if diff > float64(max(f.Cost, other.Cost))*opts.PriceTolerancePercent/100 {
+		return false
+	}
+	return f.SameFlat(other, opts)
+}
+
+// SameFlat сообщает, описывают ли отпечатки одну квартиру без учета цены:
+// так находится, например, объявление, опубликованное заново по новой цене
+func (f Fingerprint) SameFlat(other Fingerprint, opts Options) bool {
+	if f.bucket() != other.bucket() {
+		return false
+	}
+	if f.Floors > 0 && other.Floors > 0 && f.Floors != other.Floors {
+		return false
+	}
+
+	if f.Sq <= 0 || other.Sq <= 0 {
+		return false
+	}
+	if math.Abs(f.Sq-other.Sq) > max(opts.SqTolerance, math.Max(f.Sq, other.Sq)*opts.SqTolerancePercent/100) {
+		return false
+	}
+
+	if f.hasCoordinates() && other.hasCoordinates() &&
+		geo.Distance(geo.Point{Lat: f.Lat, Lng: f.Lng}, geo.Point{Lat: other.Lat, Lng: other.Lng}) <= opts.MaxDistance {
+		return true
+	}
+	return f.Address != "" && f.Address == other.Address
+}
+
+func (f Fingerprint) hasCoordinates() bool {
+	return f.Lat != 0 && f.Lng != 0
+}
+
+// addressWords сокращения, к которым приводятся слова адреса
+var addressWords = map[string]string{
+	"улица":      "ул",
+	"проспект":   "пр",
+	"пр-т":       "пр",
+	"пр-кт":      "пр",
+	"просп":      "пр",
+	"переулок":   "пер",
+	"бульвар":    "б-р",
+	"бул":        "б-р",
+	"шоссе":      "ш",
+	"площадь":    "пл",
+	"набережная": "наб",
+	"проезд":     "пр-д",
+	"микрорайон": "мкр",
+	"мкрн":       "мкр",
+	"дом":        "д",
+	"корпус":     "к",
+	"корп":       "к",
+	"строение":   "с",
+	"стр":        "с",
+	"город":      "г",
+}
+
+// NormalizeAddress приводит адрес к виду, в котором написание разных площадок
+// совпадает: нижний регистр, ё→е, без пунктуации и типов объектов («ул», «пр-т»).
+// Площадки по-разному указывают город и район перед улицей, поэтому от адреса
+// остаются улица и номер дома: «Москва, ул. Ленина, д. 5, корп. 2» → «ленина 5к2»
+func NormalizeAddress(address string) string {
+	address = strings.ReplaceAll(strings.ToLower(address), "ё", "е")
+
+	words := strings.FieldsFunc(address, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '-' && r != '/'
+	})
+
+	result := make([]string, 0, len(words))
+	for _, word := range words {
+		if short, ok := addressWords[word]; ok {
+			word = short
+		}
+		// Порядковые числительные пишут то до, то после названия улицы
+		if objectTypes[word] || unicode.IsDigit([]rune(word)[0]) && !isHouseNumber(word) {
+			continue
+		}
+		result = append(result, word)
+	}
+
+	// Номер дома - первое число после слова. Корпус и строение склеиваются
+	// с номером, чтобы «5 к 2» и «5к2» совпадали
+	for i := 1; i < len(result); i++ {
+		if isHouseNumber(result[i]) && !isHouseNumber(result[i-1]) {
+			return result[i-1] + " " + strings.Join(result[i:], "")
+		}
+	}
+	return strings.Join(result, " ")
+}
+
+// isHouseNumber отличает номер дома («5», «12а», «7/2») от порядкового
+// числительного в названии улицы («1-я», «2-й»)
+func isHouseNumber(word string) bool {
+	runes := []rune(word)
+	if !unicode.IsDigit(runes[0]) {
+		return false
+	}
+	for i, r := range runes {
+		if r == '-' && i+1 < len(runes) && !unicode.IsDigit(runes[i+1]) {
+			return false
+		}
+	}
+	return true
+}
+
+// objectTypes типы объектов адреса, которые не участвуют в сравнении
+var objectTypes = map[string]bool{
+	"ул": true, "пр": true, "пер": true, "б-р": true, "ш": true, "пл": true,
+	"наб": true, "пр-д": true, "мкр": true, "д": true, "г": true,
+}
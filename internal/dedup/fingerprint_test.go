@@ -0,0 +1,119 @@
+package dedup
+
+import (
+	"testing"
+
+	"github.com/RedNessen/inpars-telegram-bot/internal/inpars"
+)
+
+func TestNormalizeAddress(t *testing.T) {
+	tests := []struct {
+		address string
+		want    string
+	}{
+		{"Москва, ул. Ленина, д. 5, корп. 2", "ленина 5к2"},
+		{"ул Ленина 5к2", "ленина 5к2"},
+		{"Ленина улица, дом 5 корпус 2", "ленина 5к2"},
+		{"г. Москва, Ленинский проспект, 12а", "ленинский 12а"},
+		{"Ленинский пр-т, 12А", "ленинский 12а"},
+		{"Санкт-Петербург, Невский просп., 7/2", "невский 7/2"},
+		{"3-я Парковая улица, 10", "парковая 10"},
+		{"ул. 3-я Парковая, д. 10", "парковая 10"},
+		{"Щёлковское шоссе, 5 стр. 1", "щелковское 5с1"},
+		{"Тверская", "тверская"},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			if got := NormalizeAddress(tt.address); got != tt.want {
+				t.Errorf("NormalizeAddress(%q) = %q, want %q", tt.address, got, tt.want)
+			}
+		})
+	}
+}
+
+// testFlat объявление, с копиями которого сравниваются варианты в тестах
+var testFlat = inpars.Estate{
+	ID:      1,
+	TypeAd:  1,
+	Rooms:   2,
+	Floor:   5,
+	Floors:  9,
+	Sq:      54,
+	Cost:    50000,
+	Address: "Москва, ул. Ленина, д. 5",
+	Lat:     55.7558,
+	Lng:     37.6173,
+}
+
+func TestMatches(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(e *inpars.Estate)
+		want   bool
+	}{
+		{"та же квартира", func(e *inpars.Estate) {}, true},
+		{"цена в пределах 3%", func(e *inpars.Estate) { e.Cost = 51000 }, true},
+		{"цена дороже на 10%", func(e *inpars.Estate) { e.Cost = 55000 }, false},
+		{"цена не указана", func(e *inpars.Estate) { e.Cost = 0 }, false},
+		{"площадь в пределах 1 м²", func(e *inpars.Estate) { e.Sq = 54.9 }, true},
+		{"площадь больше на 5 м²", func(e *inpars.Estate) { e.Sq = 59 }, false},
+		{"площадь не указана", func(e *inpars.Estate) { e.Sq = 0 }, false},
+		{"другой этаж", func(e *inpars.Estate) { e.Floor = 6 }, false},
+		{"другое количество комнат", func(e *inpars.Estate) { e.Rooms = 3 }, false},
+		{"продажа вместо аренды", func(e *inpars.Estate) { e.TypeAd = 2 }, false},
+		{"другая этажность", func(e *inpars.Estate) { e.Floors = 12 }, false},
+		{"этажность не указана", func(e *inpars.Estate) { e.Floors = 0 }, true},
+		{"координаты в 100 м, другой адрес", func(e *inpars.Estate) {
+			e.Lat += 0.0009
+			e.Address = "Тверская 1"
+		}, true},
+		{"координаты в 1 км, другой адрес", func(e *inpars.Estate) {
+			e.Lat += 0.009
+			e.Address = "Тверская 1"
+		}, false},
+		{"координаты в 1 км, тот же адрес в другом написании", func(e *inpars.Estate) {
+			e.Lat += 0.009
+			e.Address = "ул Ленина, дом 5"
+		}, true},
+		{"нет координат, тот же адрес", func(e *inpars.Estate) {
+			e.Lat, e.Lng = 0, 0
+			e.Address = "Ленина улица 5"
+		}, true},
+		{"нет координат, другой дом", func(e *inpars.Estate) {
+			e.Lat, e.Lng = 0, 0
+			e.Address = "ул. Ленина, д. 7"
+		}, false},
+	}
+
+	opts := DefaultOptions()
+	base := FingerprintOf(&testFlat)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			other := testFlat
+			other.ID = 2
+			tt.modify(&other)
+			fp := FingerprintOf(&other)
+			if got := base.Matches(fp, opts); got != tt.want {
+				t.Errorf("Matches = %v, want %v", got, tt.want)
+			}
+			if got := fp.Matches(base, opts); got != tt.want {
+				t.Errorf("Matches is not symmetric: reverse = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSameFlatIgnoresPrice(t *testing.T) {
+	other := testFlat
+	other.Cost = 40000
+
+	opts := DefaultOptions()
+	if FingerprintOf(&testFlat).Matches(FingerprintOf(&other), opts) {
+		t.Errorf("Matches with 20%% price difference = true, want false")
+	}
+	if !FingerprintOf(&testFlat).SameFlat(FingerprintOf(&other), opts) {
+		t.Errorf("SameFlat with 20%% price difference = false, want true")
+	}
+}
@@ -0,0 +1,168 @@
+package dedup
+
+import (
+	"sync"
+	"time"
+
+	"github.com/RedNessen/inpars-telegram-bot/internal/inpars"
+)
+
+// Options допуски, с которыми объявления считаются копиями
+type Options struct {
+	PriceTolerancePercent float64       // Допустимая разница цены в процентах
+	SqTolerance           float64       // Допустимая разница площади в м²
+	SqTolerancePercent    float64       // Допустимая разница площади в процентах (берется большее)
+	MaxDistance           float64       // Максимальное расстояние между координатами в метрах
+	Window                time.Duration // Сколько помнить объявления для поиска копий
+}
+
+// DefaultOptions возвращает допуски по умолчанию
+func DefaultOptions() Options {
+	return Options{
+		PriceTolerancePercent: 3,
+		SqTolerance:           1,
+		SqTolerancePercent:    3,
+		MaxDistance:           150,
+		Window:                72 * time.Hour,
+	}
+}
+
+// Source копия объявления на одной из площадок
+type Source struct {
+	EstateID int
+	Source   string
+	URL      string
+	Cost     int
+}
+
+// Card отправленная в чат карточка группы
+type Card struct {
+	MessageID  int
+	SubName    string
+	Caption    bool // Карточка - подпись к альбому фотографий, а не отдельное сообщение
+	KeyboardID int  // Сообщение с кнопками под альбомом, 0 - кнопки у самой карточки
+}
+
+// Group копии одной квартиры. Карточка строится по первому объявлению группы
+// и перечисляет ссылки на все источники
+type Group struct {
+	Estate  inpars.Estate
+	Sources []Source
+	Cards   map[int64]Card // Отправленные карточки по чатам
+
+	fingerprint Fingerprint
+	seen        time.Time
+}
+
+// IDs возвращает ID всех объявлений группы
+func (g *Group) IDs() []int {
+	ids := make([]int, len(g.Sources))
+	for i, source := range g.Sources {
+		ids[i] = source.EstateID
+	}
+	return ids
+}
+
+// Index находит копии объявлений среди недавно полученных
+type Index struct {
+	mu      sync.Mutex
+	opts    Options
+	byID    map[int]*Group
+	buckets map[bucketKey][]*Group
+}
+
+// NewIndex создает индекс дубликатов
+func NewIndex(opts Options) *Index {
+	return &Index{
+		opts:    opts,
+		byID:    make(map[int]*Group),
+		buckets: make(map[bucketKey][]*Group),
+	}
+}
+
+// Add добавляет объявление в индекс и возвращает его группу.
+// duplicate равен true, если объявление оказалось копией уже известного
+func (x *Index) Add(estate *inpars.Estate) (group *Group, duplicate bool) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	now := time.Now()
+	if group, ok := x.byID[estate.ID]; ok {
+		group.seen = now
+		return group, len(group.Sources) > 1
+	}
+
+	fp := FingerprintOf(estate)
+	source := Source{EstateID: estate.ID, Source: estate.Source, URL: estate.URL, Cost: estate.Cost}
+
+	// Поиск копий отключен: каждое объявление - отдельная группа
+	if x.opts.Window <= 0 {
+		return &Group{Estate: *estate, Sources: []Source{source}, Cards: make(map[int64]Card), fingerprint: fp, seen: now}, false
+	}
+
+	key := fp.bucket()
+	for _, candidate := range x.buckets[key] {
+		if now.Sub(candidate.seen) > x.opts.Window || !candidate.fingerprint.Matches(fp, x.opts) {
+			continue
+		}
+		candidate.Sources = append(candidate.Sources, source)
+		candidate.seen = now
+		x.byID[estate.ID] = candidate
+		return candidate, true
+	}
+
+	group = &Group{
+		Estate:      *estate,
+		Sources:     []Source{source},
+		Cards:       make(map[int64]Card),
+		fingerprint: fp,
+		seen:        now,
+	}
+	x.byID[estate.ID] = group
+	x.buckets[key] = append(x.buckets[key], group)
+	return group, false
+}
+
+// Get возвращает группу, в которую входит объявление
+func (x *Index) Get(estateID int) (*Group, bool) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	group, ok := x.byID[estateID]
+	return group, ok
+}
+
+// Prune удаляет группы, к которым не добавлялись копии дольше окна поиска
+func (x *Index) Prune() {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	now := time.Now()
+	for key, groups := range x.buckets {
+		kept := groups[:0]
+		for _, group := range groups {
+			if now.Sub(group.seen) <= x.opts.Window {
+				kept = append(kept, group)
+				continue
+			}
+			for _, source := range group.Sources {
+				delete(x.byID, source.EstateID)
+			}
+		}
+		if len(kept) == 0 {
+			delete(x.buckets, key)
+		} else {
+			x.buckets[key] = kept
+		}
+	}
+}
+
+// Len возвращает количество групп в индексе
+func (x *Index) Len() int {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	n := 0
+	for _, groups := range x.buckets {
+		n += len(groups)
+	}
+	return n
+}
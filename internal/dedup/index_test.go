@@ -0,0 +1,88 @@
+package dedup
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIndexAdd(t *testing.T) {
+	x := NewIndex(DefaultOptions())
+
+	avito := testFlat
+	avito.ID, avito.Source = 1, "avito.ru"
+	cian := testFlat
+	cian.ID, cian.Source, cian.Cost = 2, "cian.ru", 50500
+	other := testFlat
+	other.ID, other.Floor = 3, 7
+
+	group, duplicate := x.Add(&avito)
+	if duplicate {
+		t.Fatalf("Add(first) duplicate = true")
+	}
+	copyGroup, duplicate := x.Add(&cian)
+	if !duplicate || copyGroup != group {
+		t.Fatalf("Add(copy) = %p, %v, want group %p and duplicate", copyGroup, duplicate, group)
+	}
+	if _, duplicate := x.Add(&other); duplicate {
+		t.Errorf("Add(other floor) duplicate = true")
+	}
+
+	if got, want := group.IDs(), []int{1, 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("group IDs = %v, want %v", got, want)
+	}
+	if group.Estate.ID != 1 {
+		t.Errorf("group card estate = %d, want the first listing", group.Estate.ID)
+	}
+	if got, ok := x.Get(2); !ok || got != group {
+		t.Errorf("Get(copy) = %p, %v, want group %p", got, ok, group)
+	}
+	if x.Len() != 2 {
+		t.Errorf("Len = %d, want 2", x.Len())
+	}
+
+	// Повторное добавление известного объявления не создает новый источник
+	again, duplicate := x.Add(&cian)
+	if again != group || !duplicate || len(group.Sources) != 2 {
+		t.Errorf("Add(known) = %p, %v with %d sources, want the same group with 2 sources", again, duplicate, len(group.Sources))
+	}
+}
+
+func TestIndexDisabled(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Window = 0
+	x := NewIndex(opts)
+
+	copyFlat := testFlat
+	copyFlat.ID = 2
+
+	first, _ := x.Add(&testFlat)
+	second, duplicate := x.Add(&copyFlat)
+	if duplicate || first == second {
+		t.Errorf("Add with disabled dedup grouped copies")
+	}
+	if x.Len() != 0 {
+		t.Errorf("Len with disabled dedup = %d, want 0", x.Len())
+	}
+}
+
+func TestIndexPrune(t *testing.T) {
+	x := NewIndex(DefaultOptions())
+	x.Add(&testFlat)
+
+	// Устаревшая группа удаляется вместе со всеми ID
+	x.byID[testFlat.ID].seen = x.byID[testFlat.ID].seen.Add(-2 * x.opts.Window)
+	x.Prune()
+
+	if x.Len() != 0 {
+		t.Errorf("Len after prune = %d, want 0", x.Len())
+	}
+	if _, ok := x.Get(testFlat.ID); ok {
+		t.Errorf("Get after prune found the listing")
+	}
+
+	copyFlat := testFlat
+	copyFlat.ID = 2
+	if _, duplicate := x.Add(&copyFlat); duplicate {
+		t.Errorf("Add after prune matched a pruned listing")
+	}
+}
@@ -0,0 +1,119 @@
+// Package geo содержит геометрию для фильтрации объявлений по местоположению:
+// круги, полигоны из GeoJSON и сеточный пространственный индекс
+package geo
+
+import "math"
+
+// earthRadius средний радиус Земли в метрах
+const earthRadius = 6371000.0
+
+// Point точка на карте
+type Point struct {
+	Lat float64 `json:"lat"`
+	Lng float64 `json:"lng"`
+}
+
+// IsZero возвращает true, если координаты не указаны
+func (p Point) IsZero() bool {
+	return p.Lat == 0 && p.Lng == 0
+}
+
+// Distance возвращает расстояние между точками по поверхности Земли в метрах
+func Distance(a, b Point) float64 {
+	φ1 := a.Lat * math.Pi / 180
+	φ2 := b.Lat * math.Pi / 180
+	dφ := (b.Lat - a.Lat) * math.Pi / 180
+	dλ := (b.Lng - a.Lng) * math.Pi / 180
+
+	h := math.Sin(dφ/2)*math.Sin(dφ/2) + math.Cos(φ1)*math.Cos(φ2)*math.Sin(dλ/2)*math.Sin(dλ/2)
+	return 2 * earthRadius * math.Atan2(math.Sqrt(h), math.Sqrt(1-h))
+}
+
+// Bounds прямоугольник, описанный вокруг фигуры
+type Bounds struct {
+	Min Point
+	Max Point
+}
+
+// Contains проверяет, попадает ли точка в прямоугольник
+func (b Bounds) Contains(p Point) bool {
+	return p.Lat >= b.Min.Lat && p.Lat <= b.Max.Lat && p.Lng >= b.Min.Lng && p.Lng <= b.Max.Lng
+}
+
+// Shape фигура на карте
+type Shape interface {
+	Contains(p Point) bool
+	Bounds() Bounds
+}
+
+// Circle круг вокруг точки
+type Circle struct {
+	Center Point   `json:"center"`
+	Radius float64 `json:"radius"` // Радиус в метрах
+}
+
+// Contains проверяет, находится ли точка внутри круга
+func (c Circle) Contains(p Point) bool {
+	return Distance(c.Center, p) <= c.Radius
+}
+
+// Bounds возвращает описанный вокруг круга прямоугольник
+func (c Circle) Bounds() Bounds {
+	dLat := c.Radius / earthRadius * 180 / math.Pi
+	cos := math.Cos(c.Center.Lat * math.Pi / 180)
+	dLng := 180.0
+	if cos > 1e-9 {
+		dLng = min(dLat/cos, 180)
+	}
+	return Bounds{
+		Min: Point{Lat: c.Center.Lat - dLat, Lng: c.Center.Lng - dLng},
+		Max: Point{Lat: c.Center.Lat + dLat, Lng: c.Center.Lng + dLng},
+	}
+}
+
+// Polygon многоугольник: внешний контур и, возможно, вырезанные из него области
+type Polygon struct {
+	Rings [][]Point `json:"rings"` // Первый контур внешний, остальные - дыры
+}
+
+// Contains проверяет, находится ли точка внутри внешнего контура и вне дыр
+func (pg Polygon) Contains(p Point) bool {
+	if len(pg.Rings) == 0 || !ringContains(pg.Rings[0], p) {
+		return false
+	}
+	for _, hole := range pg.Rings[1:] {
+		if ringContains(hole, p) {
+			return false
+		}
+	}
+	return true
+}
+
+// Bounds возвращает описанный вокруг внешнего контура прямоугольник
+func (pg Polygon) Bounds() Bounds {
+	if len(pg.Rings) == 0 || len(pg.Rings[0]) == 0 {
+		return Bounds{}
+	}
+	b := Bounds{Min: pg.Rings[0][0], Max: pg.Rings[0][0]}
+	for _, p := range pg.Rings[0][1:] {
+		b.Min.Lat = min(b.Min.Lat, p.Lat)
+		b.Min.Lng = min(b.Min.Lng, p.Lng)
+		b.Max.Lat = max(b.Max.Lat, p.Lat)
+		b.Max.Lng = max(b.Max.Lng, p.Lng)
+	}
+	return b
+}
+
+// ringContains проверяет попадание точки в контур методом трассировки луча.
+// На масштабе района плоское приближение в градусах достаточно точно
+func ringContains(ring []Point, p Point) bool {
+	inside := false
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		a, b := ring[i], ring[j]
+		if (a.Lat > p.Lat) != (b.Lat > p.Lat) &&
+			p.Lng < (b.Lng-a.Lng)*(p.Lat-a.Lat)/(b.Lat-a.Lat)+a.Lng {
+			inside = !inside
+		}
+	}
+	return inside
+}
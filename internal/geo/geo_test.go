@@ -0,0 +1,187 @@
+package geo
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+var (
+	moscow = Point{Lat: 55.7558, Lng: 37.6173}
+	spb    = Point{Lat: 59.9343, Lng: 30.3351}
+)
+
+func TestDistance(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b Point
+		want float64 // Метры
+		tol  float64
+	}{
+		{"одна точка", moscow, moscow, 0, 0},
+		{"градус долготы на экваторе", Point{0, 0}, Point{0, 1}, 111195, 1},
+		{"градус широты", Point{10, 20}, Point{11, 20}, 111195, 1},
+		{"от полюса до полюса", Point{90, 0}, Point{-90, 0}, math.Pi * earthRadius, 1},
+		{"через антимеридиан", Point{0, 179.5}, Point{0, -179.5}, 111195, 1},
+		{"Москва - Петербург", moscow, spb, 633000, 1000},
+		{"100 м по широте", moscow, Point{moscow.Lat + 0.0009, moscow.Lng}, 100, 0.5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Distance(tt.a, tt.b)
+			if math.Abs(got-tt.want) > tt.tol {
+				t.Errorf("Distance = %.1f, want %.1f ± %.1f", got, tt.want, tt.tol)
+			}
+			if back := Distance(tt.b, tt.a); math.Abs(back-got) > 1e-6 {
+				t.Errorf("Distance is not symmetric: %.3f and %.3f", got, back)
+			}
+		})
+	}
+}
+
+func TestCircle(t *testing.T) {
+	c := Circle{Center: moscow, Radius: 1000}
+
+	tests := []struct {
+		name string
+		p    Point
+		want bool
+	}{
+		{"центр", moscow, true},
+		{"900 м к северу", Point{moscow.Lat + 0.0081, moscow.Lng}, true},
+		{"1100 м к северу", Point{moscow.Lat + 0.0099, moscow.Lng}, false},
+		{"900 м к востоку", Point{moscow.Lat, moscow.Lng + 0.0144}, true},
+		{"1100 м к востоку", Point{moscow.Lat, moscow.Lng + 0.0176}, false},
+	}
+
+	b := c.Bounds()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := c.Contains(tt.p); got != tt.want {
+				t.Errorf("Contains = %v, want %v (distance %.0f m)", got, tt.want, Distance(moscow, tt.p))
+			}
+			// Описанный прямоугольник содержит все точки круга
+			if tt.want && !b.Contains(tt.p) {
+				t.Errorf("Bounds %+v do not contain %+v", b, tt.p)
+			}
+		})
+	}
+}
+
+// square квадрат 0..10 градусов с квадратной дырой 4..6
+var square = Polygon{Rings: [][]Point{
+	{{0, 0}, {0, 10}, {10, 10}, {10, 0}, {0, 0}},
+	{{4, 4}, {4, 6}, {6, 6}, {6, 4}, {4, 4}},
+}}
+
+// lShape невыпуклый многоугольник в форме буквы Г
+var lShape = Polygon{Rings: [][]Point{
+	{{0, 0}, {0, 10}, {2, 10}, {2, 2}, {10, 2}, {10, 0}, {0, 0}},
+}}
+
+func TestPolygonContains(t *testing.T) {
+	tests := []struct {
+		name    string
+		polygon Polygon
+		p       Point
+		want    bool
+	}{
+		{"внутри", square, Point{2, 2}, true},
+		{"в дыре", square, Point{5, 5}, false},
+		{"между дырой и краем", square, Point{5, 8}, true},
+		{"снаружи", square, Point{11, 5}, false},
+		{"снаружи на уровне вершины", square, Point{10, 12}, false},
+		{"в полке буквы Г", lShape, Point{1, 8}, true},
+		{"в основании буквы Г", lShape, Point{8, 1}, true},
+		{"во внутреннем углу буквы Г", lShape, Point{5, 5}, false},
+		{"пустой полигон", Polygon{}, Point{1, 1}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.polygon.Contains(tt.p); got != tt.want {
+				t.Errorf("Contains(%+v) = %v, want %v", tt.p, got, tt.want)
+			}
+		})
+	}
+
+	if got, want := lShape.Bounds(), (Bounds{Min: Point{0, 0}, Max: Point{10, 10}}); got != want {
+		t.Errorf("Bounds = %+v, want %+v", got, want)
+	}
+}
+
+func TestParseGeoJSON(t *testing.T) {
+	const feature = `{"type": "Feature", "properties": {"name": "район"},
+		"geometry": {"type": "Polygon", "coordinates": [[[37.0, 55.0], [38.0, 55.0], [38.0, 56.0], [37.0, 55.0]]]}}`
+
+	polygons, err := ParseGeoJSON([]byte(feature))
+	if err != nil {
+		t.Fatalf("ParseGeoJSON(Feature) error: %v", err)
+	}
+	// В GeoJSON сначала долгота, затем широта
+	want := []Polygon{{Rings: [][]Point{{{55, 37}, {55, 38}, {56, 38}, {55, 37}}}}}
+	if !reflect.DeepEqual(polygons, want) {
+		t.Errorf("ParseGeoJSON(Feature) = %+v, want %+v", polygons, want)
+	}
+
+	const collection = `{"type": "FeatureCollection", "features": [
+		{"type": "Feature", "geometry": {"type": "Point", "coordinates": [37.0, 55.0]}},
+		{"type": "Feature", "geometry": {"type": "MultiPolygon", "coordinates": [
+			[[[0, 0], [1, 0], [1, 1], [0, 0]]],
+			[[[5, 5], [6, 5], [6, 6], [5, 5]]]
+		]}},
+		{"type": "Feature", "geometry": null}
+	]}`
+	polygons, err = ParseGeoJSON([]byte(collection))
+	if err != nil {
+		t.Fatalf("ParseGeoJSON(FeatureCollection) error: %v", err)
+	}
+	if len(polygons) != 2 {
+		t.Errorf("ParseGeoJSON(FeatureCollection) = %d polygons, want 2", len(polygons))
+	}
+
+	for _, data := range []string{
+		`not json`,
+		`{"type": "Point", "coordinates": [37.0, 55.0]}`,
+		`{"type": "Polygon", "coordinates": [[[0, 0], [1, 0], [0, 0]]]}`,
+		`{"type": "Polygon", "coordinates": [[[0], [1, 0], [1, 1], [0, 0]]]}`,
+		`{"type": "Polygon", "coordinates": []}`,
+	} {
+		if _, err := ParseGeoJSON([]byte(data)); err == nil {
+			t.Errorf("ParseGeoJSON(%s) succeeded, want error", data)
+		}
+	}
+}
+
+func TestIndex(t *testing.T) {
+	x := NewIndex()
+	x.Insert("center", Circle{Center: moscow, Radius: 1000})
+	x.Insert("north", Circle{Center: Point{moscow.Lat + 0.05, moscow.Lng}, Radius: 500})
+	x.Insert("north", Circle{Center: Point{moscow.Lat - 0.05, moscow.Lng}, Radius: 500})
+	// Крупная фигура хранится вне сетки и проверяется для каждой точки
+	x.Insert("region", Polygon{Rings: [][]Point{{{50, 30}, {50, 45}, {60, 45}, {60, 30}, {50, 30}}}})
+
+	tests := []struct {
+		name string
+		p    Point
+		want map[string]bool
+	}{
+		{"центр", moscow, map[string]bool{"center": true, "region": true}},
+		{"вторая фигура ключа", Point{moscow.Lat - 0.05, moscow.Lng}, map[string]bool{"north": true, "region": true}},
+		{"только регион", Point{moscow.Lat + 0.02, moscow.Lng}, map[string]bool{"region": true}},
+		{"вне всех фигур", Point{61, 30.3}, map[string]bool{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := x.Query(tt.p); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Query = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if x.Len() != 4 {
+		t.Errorf("Len = %d, want 4", x.Len())
+	}
+}
@@ -0,0 +1,107 @@
+package geo
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// geoJSON общий вид объектов GeoJSON, которые нужны для разбора полигонов
+type geoJSON struct {
+	Type        string          `json:"type"`
+	Coordinates json.RawMessage `json:"coordinates"`
+	Geometry    *geoJSON        `json:"geometry"`
+	Features    []geoJSON       `json:"features"`
+	Geometries  []geoJSON       `json:"geometries"`
+}
+
+// ParseGeoJSON извлекает полигоны из GeoJSON: Polygon, MultiPolygon, Feature,
+// FeatureCollection или GeometryCollection. Остальные геометрии пропускаются
+func ParseGeoJSON(data []byte) ([]Polygon, error) {
+	var obj geoJSON
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return nil, fmt.Errorf("некорректный GeoJSON: %w", err)
+	}
+
+	polygons, err := collectPolygons(&obj)
+	if err != nil {
+		return nil, err
+	}
+	if len(polygons) == 0 {
+		return nil, fmt.Errorf("в GeoJSON нет полигонов")
+	}
+	return polygons, nil
+}
+
+func collectPolygons(obj *geoJSON) ([]Polygon, error) {
+	switch obj.Type {
+	case "Polygon":
+		var coords [][][]float64
+		if err := json.Unmarshal(obj.Coordinates, &coords); err != nil {
+			return nil, fmt.Errorf("некорректные координаты полигона: %w", err)
+		}
+		polygon, err := polygonOf(coords)
+		if err != nil {
+			return nil, err
+		}
+		return []Polygon{polygon}, nil
+
+	case "MultiPolygon":
+		var coords [][][][]float64
+		if err := json.Unmarshal(obj.Coordinates, &coords); err != nil {
+			return nil, fmt.Errorf("некорректные координаты мультиполигона: %w", err)
+		}
+		var result []Polygon
+		for _, c := range coords {
+			polygon, err := polygonOf(c)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, polygon)
+		}
+		return result, nil
+
+	case "Feature":
+		if obj.Geometry == nil {
+			return nil, nil
+		}
+		return collectPolygons(obj.Geometry)
+
+	case "FeatureCollection", "GeometryCollection":
+		items := obj.Features
+		if obj.Type == "GeometryCollection" {
+			items = obj.Geometries
+		}
+		var result []Polygon
+		for i := range items {
+			polygons, err := collectPolygons(&items[i])
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, polygons...)
+		}
+		return result, nil
+	}
+	return nil, nil
+}
+
+// polygonOf переводит контуры GeoJSON ([долгота, широта]) в Polygon
+func polygonOf(coords [][][]float64) (Polygon, error) {
+	var polygon Polygon
+	for _, ring := range coords {
+		if len(ring) < 4 {
+			return Polygon{}, fmt.Errorf("контур полигона должен содержать не меньше 4 точек")
+		}
+		points := make([]Point, 0, len(ring))
+		for _, c := range ring {
+			if len(c) < 2 {
+				return Polygon{}, fmt.Errorf("точка должна содержать долготу и широту")
+			}
+			points = append(points, Point{Lat: c[1], Lng: c[0]})
+		}
+		polygon.Rings = append(polygon.Rings, points)
+	}
+	if len(polygon.Rings) == 0 {
+		return Polygon{}, fmt.Errorf("пустой полигон")
+	}
+	return polygon, nil
+}
@@ -0,0 +1,87 @@
+package geo
+
+import (
+	"math"
+	"sync"
+)
+
+// cellSize размер ячейки сетки в градусах (около 1 км по широте)
+const cellSize = 0.01
+
+// maxCells ограничивает количество ячеек для одной фигуры: фигуры крупнее
+// (целая область) хранятся в общем списке и проверяются для каждой точки
+const maxCells = 10000
+
+type cell struct {
+	lat, lng int
+}
+
+func cellOf(p Point) cell {
+	return cell{lat: int(math.Floor(p.Lat / cellSize)), lng: int(math.Floor(p.Lng / cellSize))}
+}
+
+type entry struct {
+	key   string
+	shape Shape
+}
+
+// Index сеточный пространственный индекс фигур. Каждая фигура регистрируется
+// в ячейках, которые пересекает описанный вокруг нее прямоугольник, поэтому
+// поиск проверяет точно только фигуры из ячейки точки
+type Index struct {
+	mu    sync.RWMutex
+	cells map[cell][]entry
+	large []entry
+	size  int
+}
+
+// NewIndex создает пустой индекс
+func NewIndex() *Index {
+	return &Index{cells: make(map[cell][]entry)}
+}
+
+// Insert добавляет фигуру под ключом key. У одного ключа может быть несколько фигур
+func (x *Index) Insert(key string, shape Shape) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	x.size++
+	b := shape.Bounds()
+	lo, hi := cellOf(b.Min), cellOf(b.Max)
+	if (hi.lat-lo.lat+1)*(hi.lng-lo.lng+1) > maxCells {
+		x.large = append(x.large, entry{key: key, shape: shape})
+		return
+	}
+	for lat := lo.lat; lat <= hi.lat; lat++ {
+		for lng := lo.lng; lng <= hi.lng; lng++ {
+			c := cell{lat: lat, lng: lng}
+			x.cells[c] = append(x.cells[c], entry{key: key, shape: shape})
+		}
+	}
+}
+
+// Query возвращает ключи фигур, содержащих точку
+func (x *Index) Query(p Point) map[string]bool {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	result := make(map[string]bool)
+	for _, e := range x.cells[cellOf(p)] {
+		if !result[e.key] && e.shape.Contains(p) {
+			result[e.key] = true
+		}
+	}
+	for _, e := range x.large {
+		if !result[e.key] && e.shape.Contains(p) {
+			result[e.key] = true
+		}
+	}
+	return result
+}
+
+// Len возвращает количество фигур в индексе
+func (x *Index) Len() int {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	return x.size
+}
@@ -1,10 +1,13 @@
 package inpars
 
 import (
+	"bytes"
+	"context"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"net/url"
 	"strconv"
@@ -12,26 +15,70 @@ import (
 )
 
 const (
-	BaseURL     = "https://inpars.ru/api/v2"
+	BaseURL      = "https://inpars.ru/api/v2"
 	DefaultLimit = 50
 )
 
+// DefaultExpand дополнительные поля, которые запрашиваются для карточки объявления
+var DefaultExpand = []string{
+	"region", "city", "metro", "category",
+	"material", "rentTime", "rooms", "rentTerms", "history",
+	"phoneProtected", "isApartments", "house",
+}
+
+// taskRateLimit лимит запросов к /estate/task в минуту. Задачи не учитываются
+// в общем лимите, но у эндпоинта есть собственное ограничение
+const taskRateLimit = 10
+
 // Client представляет клиент для работы с API InPars
 type Client struct {
 	httpClient *http.Client
 	token      string
 	baseURL    string
+	limiter    *rateLimiter
+	taskLimit  *rateLimiter // Отдельный лимит для /estate/task
+	maxRetries int
+}
+
+// Option настраивает клиент API
+type Option func(*Client)
+
+// WithRateLimit задает ограничение частоты запросов: requests запросов за period
+func WithRateLimit(requests int, period time.Duration) Option {
+	return func(c *Client) {
+		c.limiter = newRateLimiter(requests, period)
+	}
+}
+
+// WithMaxRetries задает количество повторов при 429, 5xx и сетевых ошибках
+func WithMaxRetries(n int) Option {
+	return func(c *Client) {
+		c.maxRetries = max(n, 0)
+	}
 }
 
 // NewClient создает новый клиент API
-func NewClient(token string) *Client {
-	return &Client{
+func NewClient(token string, opts ...Option) *Client {
+	c := &Client{
 		httpClient: &http.Client{
 			Timeout: 30 * time.Second,
 		},
-		token:   token,
-		baseURL: BaseURL,
+		token:      token,
+		baseURL:    BaseURL,
+		limiter:    newRateLimiter(DefaultRateLimit, DefaultRatePeriod),
+		taskLimit:  newRateLimiter(taskRateLimit, time.Minute),
+		maxRetries: DefaultMaxRetries,
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// RateBudget возвращает последнее известное состояние лимита запросов,
+// чтобы вызывающий код мог планировать запросы
+func (c *Client) RateBudget() RateBudget {
+	return c.limiter.snapshot()
 }
 
 // getAuthHeader возвращает заголовок авторизации для Basic Auth
@@ -42,57 +89,140 @@ func (c *Client) getAuthHeader() string {
 	return "Basic " + encoded
 }
 
-// doRequest выполняет HTTP запрос с авторизацией
-func (c *Client) doRequest(method, endpoint string, params url.Values) ([]byte, error) {
+// doRequest выполняет HTTP запрос с авторизацией. Если payload не nil, он
+// отправляется в теле запроса в формате JSON.
+// Запросы выравниваются по лимиту частоты, а ответы 429, 5xx и сетевые
+// ошибки повторяются с экспоненциальной задержкой
+func (c *Client) doRequest(ctx context.Context, method, endpoint string, params url.Values, payload []byte) ([]byte, error) {
 	reqURL := c.baseURL + endpoint
 	if len(params) > 0 {
 		reqURL += "?" + params.Encode()
 	}
 
-	req, err := http.NewRequest(method, reqURL, nil)
+	var lastErr error
+	for attempt := 0; attempt <= c.maxRetries; attempt++ {
+		if attempt > 0 {
+			delay := backoff(attempt - 1)
+			log.Printf("Retrying %s %s in %s (attempt %d/%d): %v", method, endpoint, delay, attempt, c.maxRetries, lastErr)
+			if err := sleep(ctx, delay); err != nil {
+				return nil, err
+			}
+		}
+
+		limiter := c.limiterFor(endpoint)
+		if err := sleep(ctx, limiter.reserve()); err != nil {
+			return nil, err
+		}
+
+		body, retry, err := c.doOnce(ctx, method, reqURL, payload, limiter)
+		if err == nil {
+			return body, nil
+		}
+		if !retry || ctx.Err() != nil {
+			return nil, err
+		}
+		lastErr = err
+	}
+
+	return nil, lastErr
+}
+
+// limiterFor возвращает лимит, по которому выравниваются запросы к endpoint
+func (c *Client) limiterFor(endpoint string) *rateLimiter {
+	// Запросы задач не учитываются в общем лимите частоты
+	if endpoint == "/estate/task" {
+		return c.taskLimit
+	}
+	return c.limiter
+}
+
+// doOnce выполняет одну попытку запроса. retry сообщает, имеет ли смысл повторить запрос.
+// Заголовки лимита из ответа синхронизируют limiter эндпоинта
+func (c *Client) doOnce(ctx context.Context, method, reqURL string, payload []byte, limiter *rateLimiter) (body []byte, retry bool, err error) {
+	var reqBody io.Reader
+	if payload != nil {
+		reqBody = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, reqURL, reqBody)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, false, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	req.Header.Set("Accept", "application/json")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
 	req.Header.Set("Authorization", c.getAuthHeader())
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to execute request: %w", err)
+		return nil, true, fmt.Errorf("failed to execute request: %w", err)
 	}
 	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	body, err = io.ReadAll(resp.Body)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response: %w", err)
+		return nil, true, fmt.Errorf("failed to read response: %w", err)
+	}
+
+	// Синхронизируем лимит по заголовкам, а если их нет - по полю meta
+	if !limiter.updateFromHeaders(resp.Header) {
+		var envelope struct {
+			Meta Meta `json:"meta"`
+		}
+		if json.Unmarshal(body, &envelope) == nil && envelope.Meta.RateLimit > 0 {
+			limiter.update(envelope.Meta.RateLimit, envelope.Meta.RateRemaining, envelope.Meta.RateReset)
+		}
+	}
+
+	if resp.StatusCode == http.StatusOK {
+		return body, false, nil
+	}
+
+	apiErr := &APIError{Status: resp.StatusCode}
+	json.Unmarshal(body, apiErr)
+	if apiErr.Status == 0 {
+		apiErr.Status = resp.StatusCode
 	}
 
 	// Проверка на ошибки rate limiting
 	if resp.StatusCode == http.StatusTooManyRequests {
-		var apiErr APIError
-		if err := json.Unmarshal(body, &apiErr); err == nil {
-			return nil, fmt.Errorf("rate limit exceeded: %s", apiErr.Message)
-		}
-		return nil, fmt.Errorf("rate limit exceeded (429)")
+		reset, _ := strconv.Atoi(resp.Header.Get("X-Rate-Limit-Reset"))
+		limiter.block(time.Duration(max(reset, 1)) * time.Second)
+		return nil, true, apiErr
 	}
 
-	if resp.StatusCode != http.StatusOK {
-		var apiErr APIError
-		if err := json.Unmarshal(body, &apiErr); err == nil {
-			return nil, fmt.Errorf("API error (%d): %s", apiErr.Status, apiErr.Message)
-		}
-		return nil, fmt.Errorf("API error: status code %d", resp.StatusCode)
+	return nil, resp.StatusCode >= http.StatusInternalServerError, apiErr
+}
+
+// sleep ждет d или отмены контекста
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
 	}
 
-	return body, nil
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
 }
 
 // GetEstateList получает список объявлений
 func (c *Client) GetEstateList(params *EstateListParams) (*EstateListResponse, error) {
+	return c.GetEstateListContext(context.Background(), params)
+}
+
+// GetEstateListContext получает список объявлений с учетом контекста
+func (c *Client) GetEstateListContext(ctx context.Context, params *EstateListParams) (*EstateListResponse, error) {
 	urlParams := params.ToURLValues()
 
-	body, err := c.doRequest("GET", "/estate", urlParams)
+	body, err := c.doRequest(ctx, "GET", "/estate", urlParams, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -105,11 +235,23 @@ func (c *Client) GetEstateList(params *EstateListParams) (*EstateListResponse, e
 	return &response, nil
 }
 
-// GetEstate получает информацию об одном объявлении
-func (c *Client) GetEstate(id int) (*EstateResponse, error) {
+// GetEstate получает информацию об одном объявлении.
+// В expand можно передать дополнительные поля, например "history"
+func (c *Client) GetEstate(id int, expand ...string) (*EstateResponse, error) {
+	return c.GetEstateContext(context.Background(), id, expand...)
+}
+
+// GetEstateContext получает информацию об одном объявлении с учетом контекста
+func (c *Client) GetEstateContext(ctx context.Context, id int, expand ...string) (*EstateResponse, error) {
 	endpoint := fmt.Sprintf("/estate/%d", id)
 
-	body, err := c.doRequest("GET", endpoint, nil)
+	var params url.Values
+	if len(expand) > 0 {
+		params = url.Values{}
+		params.Set("expand", stringSliceToString(expand))
+	}
+
+	body, err := c.doRequest(ctx, "GET", endpoint, params, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -124,7 +266,12 @@ func (c *Client) GetEstate(id int) (*EstateResponse, error) {
 
 // GetRegions получает список регионов
 func (c *Client) GetRegions() (*RegionListResponse, error) {
-	body, err := c.doRequest("GET", "/region", nil)
+	return c.GetRegionsContext(context.Background())
+}
+
+// GetRegionsContext получает список регионов с учетом контекста
+func (c *Client) GetRegionsContext(ctx context.Context) (*RegionListResponse, error) {
+	body, err := c.doRequest(ctx, "GET", "/region", nil, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -137,31 +284,151 @@ func (c *Client) GetRegions() (*RegionListResponse, error) {
 	return &response, nil
 }
 
+// GetCities получает список городов. При regionID > 0 список фильтруется по региону
+func (c *Client) GetCities(regionID int) (*CityListResponse, error) {
+	return c.GetCitiesContext(context.Background(), regionID)
+}
+
+// GetCitiesContext получает список городов с учетом контекста
+func (c *Client) GetCitiesContext(ctx context.Context, regionID int) (*CityListResponse, error) {
+	params := url.Values{}
+	if regionID > 0 {
+		params.Set("regionId", strconv.Itoa(regionID))
+	}
+
+	body, err := c.doRequest(ctx, "GET", "/city", params, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var response CityListResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return &response, nil
+}
+
+// GetMetro получает список станций метро. При regionID > 0 или cityID > 0 список фильтруется по региону или городу
+func (c *Client) GetMetro(regionID, cityID int) (*MetroListResponse, error) {
+	return c.GetMetroContext(context.Background(), regionID, cityID)
+}
+
+// GetMetroContext получает список станций метро с учетом контекста
+func (c *Client) GetMetroContext(ctx context.Context, regionID, cityID int) (*MetroListResponse, error) {
+	params := url.Values{}
+	if regionID > 0 {
+		params.Set("regionId", strconv.Itoa(regionID))
+	}
+	if cityID > 0 {
+		params.Set("cityId", strconv.Itoa(cityID))
+	}
+
+	body, err := c.doRequest(ctx, "GET", "/metro", params, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var response MetroListResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return &response, nil
+}
+
+// GetSections получает список разделов недвижимости
+func (c *Client) GetSections() (*SectionListResponse, error) {
+	return c.GetSectionsContext(context.Background())
+}
+
+// GetSectionsContext получает список разделов недвижимости с учетом контекста
+func (c *Client) GetSectionsContext(ctx context.Context) (*SectionListResponse, error) {
+	body, err := c.doRequest(ctx, "GET", "/estate/section", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var response SectionListResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return &response, nil
+}
+
+// GetCategories получает список категорий недвижимости. При sectionID > 0 список фильтруется по разделу
+func (c *Client) GetCategories(sectionID int) (*CategoryListResponse, error) {
+	return c.GetCategoriesContext(context.Background(), sectionID)
+}
+
+// GetCategoriesContext получает список категорий недвижимости с учетом контекста
+func (c *Client) GetCategoriesContext(ctx context.Context, sectionID int) (*CategoryListResponse, error) {
+	params := url.Values{}
+	if sectionID > 0 {
+		params.Set("sectionId", strconv.Itoa(sectionID))
+	}
+
+	body, err := c.doRequest(ctx, "GET", "/estate/category", params, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var response CategoryListResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return &response, nil
+}
+
+// GetUserSubscriptions получает список активных подписок аккаунта InPars
+func (c *Client) GetUserSubscriptions() (*UserSubscriptionListResponse, error) {
+	return c.GetUserSubscriptionsContext(context.Background())
+}
+
+// GetUserSubscriptionsContext получает список активных подписок аккаунта InPars с учетом контекста
+func (c *Client) GetUserSubscriptionsContext(ctx context.Context) (*UserSubscriptionListResponse, error) {
+	body, err := c.doRequest(ctx, "GET", "/user/subscribe", nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var response UserSubscriptionListResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return &response, nil
+}
+
 // EstateListParams параметры для получения списка объявлений
 type EstateListParams struct {
-	SortBy      string   // updated_desc, updated_asc, created_desc, created_asc, id_desc, id_asc
-	LastID      int      // ID последнего объявления для пагинации
-	TimeStart   int64    // UNIX timestamp начала выборки
-	TimeEnd     int64    // UNIX timestamp конца выборки
-	RegionID    []int    // ID регионов
-	CityID      []int    // ID городов
-	MetroID     []int    // ID станций метро
-	TypeAd      []int    // Тип: 1-сдам, 2-продам, 3-сниму, 4-куплю
-	SectionID   []int    // ID разделов
-	CategoryID  []int    // ID категорий
-	SellerType  []int    // Продавец: 1-собственник, 2-агент, 3-застройщик
-	WithPhoto   *int     // 0-без фото, 1-с фото
-	IsNew       *int     // 0-вторичка, 1-новостройка
-	CostMin     int      // Цена от
-	CostMax     int      // Цена до
-	FloorMin    int      // Этаж от
-	FloorMax    int      // Этаж до
-	SqMin       float64  // Площадь от
-	SqMax       float64  // Площадь до
-	SourceID    []int    // ID источников (1-avito, 2-cian, 5-youla и т.д.)
-	Fields      []string // Поля для возврата
-	Expand      []string // Дополнительные поля
-	Limit       int      // Лимит объектов (по умолчанию 500, макс 1000)
+	SortBy     string   // updated_desc, updated_asc, created_desc, created_asc, id_desc, id_asc
+	LastID     int      // ID последнего объявления для пагинации
+	TimeStart  int64    // UNIX timestamp начала выборки
+	TimeEnd    int64    // UNIX timestamp конца выборки
+	RegionID   []int    // ID регионов
+	CityID     []int    // ID городов
+	MetroID    []int    // ID станций метро
+	TypeAd     []int    // Тип: 1-сдам, 2-продам, 3-сниму, 4-куплю
+	SectionID  []int    // ID разделов
+	CategoryID []int    // ID категорий
+	SellerType []int    // Продавец: 1-собственник, 2-агент, 3-застройщик
+	WithPhoto  *int     // 0-без фото, 1-с фото
+	IsNew      *int     // 0-вторичка, 1-новостройка
+	CostMin    int      // Цена от
+	CostMax    int      // Цена до
+	FloorMin   int      // Этаж от
+	FloorMax   int      // Этаж до
+	SqMin      float64  // Площадь от
+	SqMax      float64  // Площадь до
+	SourceID   []int    // ID источников (1-avito, 2-cian, 5-youla и т.д.)
+	ParseID    []string // ID объявлений на источнике (не работает с тестовым токеном)
+	ByURL      string   // Поиск по ссылке на источник (не работает с тестовым токеном)
+	Fields     []string // Поля для возврата
+	Expand     []string // Дополнительные поля
+	Limit      int      // Лимит объектов (по умолчанию 500, макс 1000)
 }
 
 // ToURLValues преобразует параметры в url.Values
@@ -228,6 +495,12 @@ func (p *EstateListParams) ToURLValues() url.Values {
 	if len(p.SourceID) > 0 {
 		values.Set("sourceId", intsToString(p.SourceID))
 	}
+	if len(p.ParseID) > 0 {
+		values.Set("parseId", stringSliceToString(p.ParseID))
+	}
+	if p.ByURL != "" {
+		values.Set("byUrl", p.ByURL)
+	}
 	if len(p.Fields) > 0 {
 		values.Set("fields", stringSliceToString(p.Fields))
 	}
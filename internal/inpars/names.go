@@ -0,0 +1,167 @@
+package inpars
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Оценки совпадения названия, от лучшей к худшей
+const (
+	scoreExact      = 100 // Название совпадает
+	scorePrefix     = 80  // Название начинается с запроса
+	scoreWordPrefix = 60  // Слово названия начинается с запроса
+	scoreContains   = 40  // Запрос входит в название
+	scoreTypo       = 20  // Название или его слово отличается опечаткой
+)
+
+// nameAliases разговорные и сокращенные названия регионов и городов
+var nameAliases = map[string]string{
+	"питер":         "санкт петербург",
+	"спб":           "санкт петербург",
+	"петербург":     "санкт петербург",
+	"мск":           "москва",
+	"мо":            "московская область",
+	"подмосковье":   "московская область",
+	"ло":            "ленинградская область",
+	"екб":           "екатеринбург",
+	"ебург":         "екатеринбург",
+	"нск":           "новосибирск",
+	"новосиб":       "новосибирск",
+	"нн":            "нижний новгород",
+	"нижний":        "нижний новгород",
+	"ростов":        "ростов на дону",
+	"краснодарский": "краснодарский край",
+}
+
+// nameNoise слова, которые не входят в название: «г. Москва», «м. Сокол»
+var nameNoise = map[string]bool{"г": true, "город": true, "м": true, "метро": true, "ст": true, "станция": true}
+
+// latinToCyrillic транслитерация латиницы, сначала сочетания букв
+var latinToCyrillic = []struct{ latin, cyrillic string }{
+	{"shch", "щ"}, {"sch", "щ"}, {"zh", "ж"}, {"kh", "х"}, {"ts", "ц"}, {"ch", "ч"}, {"sh", "ш"},
+	{"yo", "е"}, {"yu", "ю"}, {"ya", "я"}, {"ye", "е"}, {"iy", "ий"}, {"yy", "ый"},
+	{"a", "а"}, {"b", "б"}, {"c", "ц"}, {"d", "д"}, {"e", "е"}, {"f", "ф"}, {"g", "г"},
+	{"h", "х"}, {"i", "и"}, {"j", "й"}, {"k", "к"}, {"l", "л"}, {"m", "м"}, {"n", "н"},
+	{"o", "о"}, {"p", "п"}, {"q", "к"}, {"r", "р"}, {"s", "с"}, {"t", "т"}, {"u", "у"},
+	{"v", "в"}, {"w", "в"}, {"x", "кс"}, {"y", "ы"}, {"z", "з"}, {"'", "ь"},
+}
+
+// NormalizeName приводит название к виду для сравнения: нижний регистр,
+// ё как е, латиница транслитерируется в кириллицу, дефисы и точки заменяются
+// пробелами, служебные слова («г.», «м.») отбрасываются, известные сокращения
+// раскрываются: «Piter» - «санкт петербург», «ВДНХ» - «вднх»
+func NormalizeName(name string) string {
+	name = strings.ReplaceAll(strings.ToLower(name), "ё", "е")
+	name = transliterate(name)
+
+	fields := strings.FieldsFunc(name, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	words := fields[:0]
+	for _, word := range fields {
+		if !nameNoise[word] {
+			words = append(words, word)
+		}
+	}
+
+	result := strings.Join(words, " ")
+	if alias, ok := nameAliases[result]; ok {
+		return alias
+	}
+	return result
+}
+
+// transliterate заменяет латинские буквы кириллическими
+func transliterate(text string) string {
+	if !strings.ContainsFunc(text, func(r rune) bool { return r >= 'a' && r <= 'z' }) {
+		return text
+	}
+
+	var sb strings.Builder
+	for i := 0; i < len(text); {
+		matched := false
+		for _, pair := range latinToCyrillic {
+			if strings.HasPrefix(text[i:], pair.latin) {
+				sb.WriteString(pair.cyrillic)
+				i += len(pair.latin)
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			sb.WriteByte(text[i])
+			i++
+		}
+	}
+	return sb.String()
+}
+
+// NameScore оценивает, насколько название подходит под запрос: 0 - не
+// подходит, больше - лучше. Сравнение не учитывает регистр, ё/е, знаки
+// препинания и транслитерацию («sokol»), раскрывает сокращения («питер»,
+// «спб») и допускает опечатки в длинных словах
+func NameScore(title, name string) int {
+	return nameScore(NormalizeName(title), NormalizeName(name))
+}
+
+// nameScore сравнивает нормализованные название и запрос
+func nameScore(title, query string) int {
+	switch {
+	case query == "" || title == "":
+		return 0
+	case title == query:
+		return scoreExact
+	case strings.HasPrefix(title, query):
+		return scorePrefix
+	case strings.Contains(" "+title, " "+query):
+		return scoreWordPrefix
+	case strings.Contains(title, query):
+		return scoreContains
+	}
+
+	if typos := allowedTypos(query); typos > 0 {
+		if editDistance(title, query) <= typos {
+			return scoreTypo
+		}
+		for _, word := range strings.Fields(title) {
+			if editDistance(word, query) <= typos {
+				return scoreTypo
+			}
+		}
+	}
+	return 0
+}
+
+// allowedTypos сколько опечаток допускается в запросе: в коротких
+// запросах опечатка делает совпадение случайным
+func allowedTypos(query string) int {
+	switch n := len([]rune(query)); {
+	case n >= 9:
+		return 2
+	case n >= 5:
+		return 1
+	}
+	return 0
+}
+
+// editDistance расстояние Левенштейна между строками в символах
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
@@ -0,0 +1,166 @@
+package inpars
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestNormalizeName(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"Москва", "москва"},
+		{"Щёлково", "щелково"},
+		{"Санкт-Петербург", "санкт петербург"},
+		{"Ростов-на-Дону", "ростов на дону"},
+		// Служебные слова отбрасываются
+		{"г. Москва", "москва"},
+		{"м. Ясенево", "ясенево"},
+		{"станция метро Сокол", "сокол"},
+		// Сокращения раскрываются
+		{"питер", "санкт петербург"},
+		{"СПб", "санкт петербург"},
+		{"Подмосковье", "московская область"},
+		{"ВДНХ", "вднх"},
+		// Латиница транслитерируется
+		{"Piter", "санкт петербург"},
+		{"sokol", "сокол"},
+		{"Shchukinskaya", "щукинская"},
+		{"  ", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := NormalizeName(tt.name); got != tt.want {
+				t.Errorf("NormalizeName(%q) = %q, want %q", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTransliterate(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"moskva", "москва"},
+		{"zhulebino", "жулебино"},
+		{"kievskaya", "киевская"},
+		{"yasenevo", "ясенево"},
+		{"chertanovo", "чертаново"},
+		{"сокол", "сокол"},
+		{"1-я линия", "1-я линия"},
+	}
+	for _, tt := range tests {
+		if got := transliterate(tt.text); got != tt.want {
+			t.Errorf("transliterate(%q) = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"", "", 0},
+		{"сокол", "", 5},
+		{"", "сокол", 5},
+		{"сокол", "сокол", 0},
+		{"сокол", "сакол", 1},
+		{"москва", "мосва", 1},
+		{"петербург", "питербурх", 2},
+		{"kitten", "sitting", 3},
+	}
+	for _, tt := range tests {
+		if got := editDistance(tt.a, tt.b); got != tt.want {
+			t.Errorf("editDistance(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestAllowedTypos(t *testing.T) {
+	tests := []struct {
+		query string
+		want  int
+	}{
+		{"сок", 0},
+		{"вднх", 0},
+		{"сокол", 1},
+		{"ясенево", 1},
+		{"петербург", 2},
+	}
+	for _, tt := range tests {
+		if got := allowedTypos(tt.query); got != tt.want {
+			t.Errorf("allowedTypos(%q) = %d, want %d", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestNameScore(t *testing.T) {
+	tests := []struct {
+		title string
+		name  string
+		want  int
+	}{
+		{"Москва", "москва", scoreExact},
+		{"Санкт-Петербург", "питер", scoreExact},
+		{"Санкт-Петербург", "спб", scoreExact},
+		{"ВДНХ", "вднх", scoreExact},
+		{"Щёлковская", "Schelkovskaya", scoreExact},
+		{"Московская область", "моск", scorePrefix},
+		{"Нижний Новгород", "новгород", scoreWordPrefix},
+		{"Екатеринбург", "ринбург", scoreContains},
+		{"Сокол", "сакол", scoreTypo},
+		{"Петербург", "питербурх", scoreTypo},
+		// В коротком запросе опечатки не допускаются
+		{"Сокол", "сак", 0},
+		{"Москва", "", 0},
+		{"Москва", "Казань", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.title+"/"+tt.name, func(t *testing.T) {
+			if got := NameScore(tt.title, tt.name); got != tt.want {
+				t.Errorf("NameScore(%q, %q) = %d, want %d", tt.title, tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBestMatches(t *testing.T) {
+	regions := []Region{
+		{ID: 77, Title: "Москва"},
+		{ID: 78, Title: "Санкт-Петербург"},
+		{ID: 50, Title: "Московская область"},
+		{ID: 47, Title: "Ленинградская область"},
+	}
+	title := func(r Region) string { return r.Title }
+
+	tests := []struct {
+		name string
+		want []int
+	}{
+		// Точное совпадение лучше совпадения по началу
+		{"москва", []int{77}},
+		{"мск", []int{77}},
+		{"питер", []int{78}},
+		{"Sankt-Peterburg", []int{78}},
+		// Одинаково подходящие упорядочены по названию
+		{"моск", []int{77, 50}},
+		{"область", []int{47, 50}},
+		{"масква", []int{77}},
+		{"Казань", nil},
+		{"г.", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []int
+			for _, region := range bestMatches(regions, title, tt.name) {
+				got = append(got, region.ID)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("bestMatches(%q) = %v, want %v", tt.name, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,146 @@
+package inpars
+
+import (
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Значения по умолчанию для ограничения частоты запросов (10 запросов в минуту)
+const (
+	DefaultRateLimit  = 10
+	DefaultRatePeriod = time.Minute
+	DefaultMaxRetries = 3
+
+	retryBaseDelay = time.Second
+	retryMaxDelay  = 30 * time.Second
+)
+
+// RateBudget текущее состояние лимита запросов к API
+type RateBudget struct {
+	Limit     int       // Максимальное количество запросов (X-Rate-Limit-Limit)
+	Remaining int       // Оставшееся количество запросов (X-Rate-Limit-Remaining)
+	Reset     time.Time // Момент полного восстановления лимита (X-Rate-Limit-Reset)
+	Updated   time.Time // Время последнего ответа сервера с информацией о лимите
+}
+
+// Known проверяет, приходила ли от сервера информация о лимите
+func (b RateBudget) Known() bool {
+	return !b.Updated.IsZero()
+}
+
+// rateLimiter token bucket, синхронизируемый с лимитами из ответов сервера
+type rateLimiter struct {
+	mu           sync.Mutex
+	capacity     float64
+	tokens       float64
+	interval     time.Duration // Время восстановления одного запроса
+	last         time.Time
+	blockedUntil time.Time // Сервер сообщил, что лимит исчерпан до этого момента
+	budget       RateBudget
+}
+
+// newRateLimiter создает bucket на requests запросов за period
+func newRateLimiter(requests int, period time.Duration) *rateLimiter {
+	if requests <= 0 {
+		requests = DefaultRateLimit
+	}
+	if period <= 0 {
+		period = DefaultRatePeriod
+	}
+	return &rateLimiter{
+		capacity: float64(requests),
+		tokens:   float64(requests),
+		interval: period / time.Duration(requests),
+		last:     time.Now(),
+	}
+}
+
+// reserve забирает токен и возвращает, сколько нужно подождать перед запросом
+func (l *rateLimiter) reserve() time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.refill(now)
+
+	var wait time.Duration
+	if now.Before(l.blockedUntil) {
+		wait = l.blockedUntil.Sub(now)
+	}
+
+	l.tokens--
+	if l.tokens < 0 {
+		wait = max(wait, time.Duration(-l.tokens*float64(l.interval)))
+	}
+	return wait
+}
+
+// refill пополняет bucket пропорционально прошедшему времени
+func (l *rateLimiter) refill(now time.Time) {
+	elapsed := now.Sub(l.last)
+	l.last = now
+	l.tokens = min(l.capacity, l.tokens+float64(elapsed)/float64(l.interval))
+}
+
+// update синхронизирует bucket с лимитом, который сообщил сервер
+func (l *rateLimiter) update(limit, remaining, reset int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.refill(now)
+
+	l.budget = RateBudget{
+		Limit:     limit,
+		Remaining: remaining,
+		Reset:     now.Add(time.Duration(reset) * time.Second),
+		Updated:   now,
+	}
+
+	if float64(remaining) < l.tokens {
+		l.tokens = float64(remaining)
+	}
+	if remaining <= 0 {
+		l.block(time.Duration(reset) * time.Second)
+	}
+}
+
+// block запрещает запросы на время d (например, после ответа 429)
+func (l *rateLimiter) block(d time.Duration) {
+	until := time.Now().Add(d)
+	if until.After(l.blockedUntil) {
+		l.blockedUntil = until
+	}
+}
+
+// snapshot возвращает текущее состояние лимита
+func (l *rateLimiter) snapshot() RateBudget {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.budget
+}
+
+// updateFromHeaders читает заголовки X-Rate-Limit-*. Возвращает false, если их нет в ответе
+func (l *rateLimiter) updateFromHeaders(h http.Header) bool {
+	limit, errLimit := strconv.Atoi(h.Get("X-Rate-Limit-Limit"))
+	remaining, errRemaining := strconv.Atoi(h.Get("X-Rate-Limit-Remaining"))
+	if errLimit != nil || errRemaining != nil {
+		return false
+	}
+	reset, _ := strconv.Atoi(h.Get("X-Rate-Limit-Reset"))
+	l.update(limit, remaining, reset)
+	return true
+}
+
+// backoff возвращает задержку перед повтором с номером attempt (начиная с 0):
+// экспоненциальный рост со случайным разбросом в пределах [d/2, d]
+func backoff(attempt int) time.Duration {
+	d := retryBaseDelay << attempt
+	if d > retryMaxDelay || d <= 0 {
+		d = retryMaxDelay
+	}
+	return d/2 + rand.N(d/2+1)
+}
@@ -0,0 +1,245 @@
+package inpars
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Reference кэш справочников регионов, городов и станций метро. Справочники
+// меняются редко, а каждый запрос расходует лимит API, поэтому списки
+// загружаются по первому обращению и хранятся в файле до истечения ttl.
+// Если обновить устаревший список не удалось, возвращается сохраненный.
+// Список загружается без блокировки остальных, одновременные обращения
+// к одному списку ждут одной загрузки
+type Reference struct {
+	client *Client
+	path   string        // Файл кэша, пустая строка - только в памяти
+	ttl    time.Duration // Срок хранения списка, 0 - бессрочно
+
+	mu      sync.Mutex
+	data    referenceData
+	loading map[string]*referenceLoad // Загружаемые списки по имени
+}
+
+// referenceLoad загрузка списка, которую ждут другие обращения
+type referenceLoad struct {
+	done chan struct{}
+	err  error
+}
+
+// cachedList загруженный список и время загрузки
+type cachedList[T any] struct {
+	Items  []T       `json:"items"`
+	Loaded time.Time `json:"loaded"`
+}
+
+// referenceData содержимое файла кэша
+type referenceData struct {
+	Regions cachedList[Region]           `json:"regions"`
+	Cities  map[int]cachedList[City]     `json:"cities"` // По ID региона
+	Metro   map[string]cachedList[Metro] `json:"metro"`  // По "регион/город"
+}
+
+// NewReference создает кэш справочников и загружает сохраненные списки из path
+func NewReference(client *Client, path string, ttl time.Duration) *Reference {
+	r := &Reference{
+		client:  client,
+		path:    path,
+		ttl:     ttl,
+		loading: make(map[string]*referenceLoad),
+		data: referenceData{
+			Cities: make(map[int]cachedList[City]),
+			Metro:  make(map[string]cachedList[Metro]),
+		},
+	}
+	if path == "" {
+		return r
+	}
+
+	body, err := os.ReadFile(path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Printf("Failed to read reference cache %s: %v", path, err)
+		}
+		return r
+	}
+	if err := json.Unmarshal(body, &r.data); err != nil {
+		log.Printf("Failed to parse reference cache %s: %v", path, err)
+		return r
+	}
+	if r.data.Cities == nil {
+		r.data.Cities = make(map[int]cachedList[City])
+	}
+	if r.data.Metro == nil {
+		r.data.Metro = make(map[string]cachedList[Metro])
+	}
+	return r
+}
+
+// Regions возвращает список регионов
+func (r *Reference) Regions(ctx context.Context) ([]Region, error) {
+	return refreshList(ctx, r, "regions", func() cachedList[Region] { return r.data.Regions }, func() ([]Region, error) {
+		resp, err := r.client.GetRegionsContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return resp.Data, nil
+	}, func(list cachedList[Region]) { r.data.Regions = list })
+}
+
+// Cities возвращает список городов региона
+func (r *Reference) Cities(ctx context.Context, regionID int) ([]City, error) {
+	return refreshList(ctx, r, "cities of region "+strconv.Itoa(regionID), func() cachedList[City] { return r.data.Cities[regionID] }, func() ([]City, error) {
+		resp, err := r.client.GetCitiesContext(ctx, regionID)
+		if err != nil {
+			return nil, err
+		}
+		return resp.Data, nil
+	}, func(list cachedList[City]) { r.data.Cities[regionID] = list })
+}
+
+// Metro возвращает список станций метро региона и города
+func (r *Reference) Metro(ctx context.Context, regionID, cityID int) ([]Metro, error) {
+	key := fmt.Sprintf("%d/%d", regionID, cityID)
+	return refreshList(ctx, r, "metro of "+key, func() cachedList[Metro] { return r.data.Metro[key] }, func() ([]Metro, error) {
+		resp, err := r.client.GetMetroContext(ctx, regionID, cityID)
+		if err != nil {
+			return nil, err
+		}
+		return resp.Data, nil
+	}, func(list cachedList[Metro]) { r.data.Metro[key] = list })
+}
+
+// FindRegions находит регионы по названию (см. NameScore)
+func (r *Reference) FindRegions(ctx context.Context, name string) ([]Region, error) {
+	regions, err := r.Regions(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return bestMatches(regions, func(region Region) string { return region.Title }, name), nil
+}
+
+// FindCities находит города региона по названию (см. NameScore)
+func (r *Reference) FindCities(ctx context.Context, regionID int, name string) ([]City, error) {
+	cities, err := r.Cities(ctx, regionID)
+	if err != nil {
+		return nil, err
+	}
+	return bestMatches(cities, func(city City) string { return city.Title }, name), nil
+}
+
+// FindMetro находит станции метро региона и города по названию (см. NameScore)
+func (r *Reference) FindMetro(ctx context.Context, regionID, cityID int, name string) ([]Metro, error) {
+	stations, err := r.Metro(ctx, regionID, cityID)
+	if err != nil {
+		return nil, err
+	}
+	return bestMatches(stations, func(station Metro) string { return station.Title }, name), nil
+}
+
+// refreshList возвращает список name, загружая его заново, если он устарел.
+// get и put читают и заменяют список под r.mu, fetch загружает его без
+// блокировки. Если список уже загружается, обращение ждет этой загрузки
+func refreshList[T any](ctx context.Context, r *Reference, name string, get func() cachedList[T], fetch func() ([]T, error), put func(cachedList[T])) ([]T, error) {
+	r.mu.Lock()
+	list := get()
+	if !list.Loaded.IsZero() && (r.ttl <= 0 || time.Since(list.Loaded) < r.ttl) {
+		r.mu.Unlock()
+		return list.Items, nil
+	}
+
+	if load, ok := r.loading[name]; ok {
+		r.mu.Unlock()
+		select {
+		case <-load.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		if list := get(); !list.Loaded.IsZero() {
+			return list.Items, nil
+		}
+		return nil, load.err
+	}
+
+	load := &referenceLoad{done: make(chan struct{})}
+	r.loading[name] = load
+	r.mu.Unlock()
+	defer close(load.done)
+
+	items, err := fetch()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.loading, name)
+	load.err = err
+	if err != nil {
+		if !list.Loaded.IsZero() {
+			log.Printf("Failed to refresh %s, using cached list from %s: %v", name, list.Loaded.Format(time.RFC3339), err)
+			return list.Items, nil
+		}
+		return nil, err
+	}
+
+	put(cachedList[T]{Items: items, Loaded: time.Now()})
+	log.Printf("Loaded %d %s", len(items), name)
+	if err := r.save(); err != nil {
+		log.Printf("Failed to save reference cache %s: %v", r.path, err)
+	}
+	return items, nil
+}
+
+// save записывает кэш во временный файл и переименовывает его, чтобы
+// прерванная запись не испортила сохраненные списки. Вызывается под r.mu
+func (r *Reference) save() error {
+	if r.path == "" {
+		return nil
+	}
+
+	body, err := json.Marshal(&r.data)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), 0o755); err != nil {
+		return err
+	}
+	tmp := r.path + ".tmp"
+	if err := os.WriteFile(tmp, body, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, r.path)
+}
+
+// bestMatches возвращает элементы с наибольшей оценкой совпадения названия,
+// отсортированные по названию
+func bestMatches[T any](items []T, title func(T) string, name string) []T {
+	query := NormalizeName(name)
+	if query == "" {
+		return nil
+	}
+
+	best := 0
+	var result []T
+	for _, item := range items {
+		score := nameScore(NormalizeName(title(item)), query)
+		switch {
+		case score == 0 || score < best:
+		case score > best:
+			best, result = score, []T{item}
+		default:
+			result = append(result, item)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool { return title(result[i]) < title(result[j]) })
+	return result
+}
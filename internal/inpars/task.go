@@ -0,0 +1,143 @@
+package inpars
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// MaxTaskURLs максимальное количество ссылок в одном запросе на обновление
+const MaxTaskURLs = 10
+
+// Статусы задачи на добавление/обновление объявления
+const (
+	TaskStatusError      = "error"
+	TaskStatusCreated    = "created"
+	TaskStatusInProgress = "inprogress"
+	TaskStatusFailed     = "failed"
+	TaskStatusCompleted  = "completed"
+)
+
+// Task задача на добавление или обновление объявления по ссылке
+type Task struct {
+	ID      int    `json:"id"`
+	URL     string `json:"url"`
+	IsExist bool   `json:"isExist"` // Объявление уже есть в базе InPars
+	Status  string `json:"status"`
+	Message string `json:"message"`
+	Created string `json:"created"`
+	Updated string `json:"updated"`
+}
+
+// TaskListResponse ответ на запрос создания задач.
+// Meta содержит квоту обновлений (UpdateLimit/UpdateRemaining)
+type TaskListResponse struct {
+	Data []Task `json:"data"`
+	Meta Meta   `json:"meta"`
+}
+
+// IsFinished проверяет, завершена ли задача (успешно или с ошибкой)
+func (t *Task) IsFinished() bool {
+	switch t.Status {
+	case TaskStatusCompleted, TaskStatusFailed, TaskStatusError:
+		return true
+	default:
+		return false
+	}
+}
+
+// GetTaskStatusName возвращает текстовое название статуса задачи
+func GetTaskStatusName(status string) string {
+	switch status {
+	case TaskStatusCreated:
+		return "В очереди"
+	case TaskStatusInProgress:
+		return "Выполняется"
+	case TaskStatusCompleted:
+		return "Выполнена"
+	case TaskStatusFailed:
+		return "Не выполнена"
+	case TaskStatusError:
+		return "Ошибка"
+	default:
+		return "Неизвестно"
+	}
+}
+
+// CreateTasks отправляет ссылки на добавление или обновление объявлений
+func (c *Client) CreateTasks(urls []string) (*TaskListResponse, error) {
+	return c.CreateTasksContext(context.Background(), urls)
+}
+
+// CreateTasksContext отправляет ссылки на добавление или обновление объявлений с учетом контекста.
+//
+// Повторная отправка ссылки в течение 24 часов не создает новую задачу,
+// а возвращает статус существующей, поэтому метод используется и для
+// проверки статуса. На запрос не действует общее ограничение частоты
+func (c *Client) CreateTasksContext(ctx context.Context, urls []string) (*TaskListResponse, error) {
+	if len(urls) == 0 {
+		return nil, fmt.Errorf("no urls to refresh")
+	}
+	if len(urls) > MaxTaskURLs {
+		return nil, fmt.Errorf("too many urls: %d (max %d)", len(urls), MaxTaskURLs)
+	}
+
+	payload, err := json.Marshal(struct {
+		URLs []string `json:"urls"`
+	}{URLs: urls})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode request: %w", err)
+	}
+
+	body, err := c.doRequest(ctx, "POST", "/estate/task", nil, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	var response TaskListResponse
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("failed to parse response: %w", err)
+	}
+
+	return &response, nil
+}
+
+// WaitTask периодически проверяет задачу по ссылке, пока она не завершится
+// или не будет отменен контекст. Возвращает последнее состояние задачи
+func (c *Client) WaitTask(ctx context.Context, url string, interval time.Duration) (*Task, error) {
+	for {
+		resp, err := c.CreateTasksContext(ctx, []string{url})
+		if err != nil {
+			return nil, err
+		}
+		if len(resp.Data) == 0 {
+			return nil, fmt.Errorf("empty task response for %s", url)
+		}
+
+		task := &resp.Data[0]
+		if task.IsFinished() {
+			return task, nil
+		}
+
+		if err := sleep(ctx, interval); err != nil {
+			return task, err
+		}
+	}
+}
+
+// FindEstateByURL ищет объявление по ссылке на источник (параметр byUrl)
+func (c *Client) FindEstateByURL(ctx context.Context, url string) (*Estate, error) {
+	resp, err := c.GetEstateListContext(ctx, &EstateListParams{
+		ByURL:  url,
+		Limit:  1,
+		Expand: append([]string(nil), DefaultExpand...),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.Data) == 0 {
+		return nil, fmt.Errorf("estate not found by url %s", url)
+	}
+	return &resp.Data[0], nil
+}
@@ -1,6 +1,13 @@
 package inpars
 
-import "time"
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
 
 // APIError представляет ошибку API
 type APIError struct {
@@ -10,6 +17,26 @@ type APIError struct {
 	Status  int    `json:"status"`
 }
 
+// Error реализует интерфейс error
+func (e *APIError) Error() string {
+	if e.Status == 429 {
+		if e.Message != "" {
+			return fmt.Sprintf("rate limit exceeded: %s", e.Message)
+		}
+		return "rate limit exceeded (429)"
+	}
+	if e.Message != "" {
+		return fmt.Sprintf("API error (%d): %s", e.Status, e.Message)
+	}
+	return fmt.Sprintf("API error: status code %d", e.Status)
+}
+
+// IsNotFound проверяет, что API ответил 404: объявление удалено или снято с публикации
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.Status == 404
+}
+
 // Meta содержит метаданные ответа
 type Meta struct {
 	Limit           int `json:"limit,omitempty"`
@@ -23,68 +50,101 @@ type Meta struct {
 
 // Estate представляет объявление о недвижимости
 type Estate struct {
-	ID         int      `json:"id"`
-	RegionID   int      `json:"regionId"`
-	CityID     int      `json:"cityId"`
-	MetroID    int      `json:"metroId,omitempty"`
-	TypeAd     int      `json:"typeAd"`     // 1-сдам, 2-продам, 3-сниму, 4-куплю
-	SectionID  int      `json:"sectionId"` // ID раздела недвижимости
-	CategoryID int      `json:"categoryId"`
-	Title      string   `json:"title"`
-	Address    string   `json:"address"`
-	Floor      int      `json:"floor,omitempty"`
-	Floors     int      `json:"floors,omitempty"`
-	Sq         float64  `json:"sq,omitempty"`         // Площадь
-	SqLand     float64  `json:"sqLand,omitempty"`     // Площадь участка
-	SqLiving   float64  `json:"sqLiving,omitempty"`   // Жилая площадь
-	SqKitchen  float64  `json:"sqKitchen,omitempty"`  // Площадь кухни
-	Cost       int      `json:"cost"`                 // Стоимость
-	Text       string   `json:"text"`                 // Описание
-	Images     []string `json:"images"`               // Ссылки на фото
-	Lat        float64  `json:"lat"`                  // Широта
-	Lng        float64  `json:"lng"`                  // Долгота
-	Name       string   `json:"name"`                 // Имя продавца
-	Phones     []int64  `json:"phones"`               // Телефоны
-	URL        string   `json:"url"`                  // Ссылка на источник
-	Agent      int      `json:"agent"`                // 0-собственник, 1-агент, 2-застройщик
-	Source     string   `json:"source"`               // Название источника
-	SourceID   int      `json:"sourceId"`             // ID источника
-	Created    string   `json:"created"`              // Дата создания
-	Updated    string   `json:"updated"`              // Дата обновления
+	ID         int        `json:"id"`
+	RegionID   int        `json:"regionId"`
+	CityID     int        `json:"cityId"`
+	MetroID    int        `json:"metroId,omitempty"`
+	TypeAd     int        `json:"typeAd"`    // 1-сдам, 2-продам, 3-сниму, 4-куплю
+	SectionID  int        `json:"sectionId"` // ID раздела недвижимости
+	CategoryID int        `json:"categoryId"`
+	Title      string     `json:"title"`
+	Address    string     `json:"address"`
+	Floor      int        `json:"floor,omitempty"`
+	Floors     int        `json:"floors,omitempty"`
+	Sq         float64    `json:"sq,omitempty"`        // Площадь
+	SqLand     float64    `json:"sqLand,omitempty"`    // Площадь участка
+	SqLiving   float64    `json:"sqLiving,omitempty"`  // Жилая площадь
+	SqKitchen  float64    `json:"sqKitchen,omitempty"` // Площадь кухни
+	Cost       int        `json:"cost"`                // Стоимость
+	Text       string     `json:"text"`                // Описание
+	Images     []string   `json:"images"`              // Ссылки на фото
+	Lat        Coordinate `json:"lat"`                 // Широта
+	Lng        Coordinate `json:"lng"`                 // Долгота
+	Name       string     `json:"name"`                // Имя продавца
+	Phones     []int64    `json:"phones"`              // Телефоны
+	URL        string     `json:"url"`                 // Ссылка на источник
+	Agent      int        `json:"agent"`               // 0-собственник, 1-агент, 2-застройщик
+	Source     string     `json:"source"`              // Название источника
+	SourceID   int        `json:"sourceId"`            // ID источника
+	Created    string     `json:"created"`             // Дата создания
+	Updated    string     `json:"updated"`             // Дата обновления
 
 	// Дополнительные поля (требуют expand параметра)
-	Region         string      `json:"region,omitempty"`
-	City           string      `json:"city,omitempty"`
-	Type           string      `json:"type,omitempty"`
-	Section        string      `json:"section,omitempty"`
-	Category       string      `json:"category,omitempty"`
-	Metro          string      `json:"metro,omitempty"`
-	Material       string      `json:"material,omitempty"`
-	RentTime       int         `json:"rentTime,omitempty"`       // 0-не указан, 1-длительно, 2-посуточно
-	IsNew          bool        `json:"isNew,omitempty"`          // Новостройка
-	Rooms          int         `json:"rooms,omitempty"`          // Количество комнат
-	PhoneProtected bool        `json:"phoneProtected,omitempty"` // Подменный номер
-	ParseID        string      `json:"parseId,omitempty"`        // ID на источнике
-	IsApartments   bool        `json:"isApartments,omitempty"`   // Апартаменты
-	RentTerms      *RentTerms  `json:"rentTerms,omitempty"`      // Условия аренды
-	House          *House      `json:"house,omitempty"`          // Информация о доме
+	Region         string     `json:"region,omitempty"`
+	City           string     `json:"city,omitempty"`
+	Type           string     `json:"type,omitempty"`
+	Section        string     `json:"section,omitempty"`
+	Category       string     `json:"category,omitempty"`
+	Metro          string     `json:"metro,omitempty"`
+	Material       string     `json:"material,omitempty"`
+	RentTime       int        `json:"rentTime,omitempty"`       // 0-не указан, 1-длительно, 2-посуточно
+	IsNew          bool       `json:"isNew,omitempty"`          // Новостройка
+	Rooms          int        `json:"rooms,omitempty"`          // Количество комнат
+	PhoneProtected bool       `json:"phoneProtected,omitempty"` // Подменный номер
+	ParseID        string     `json:"parseId,omitempty"`        // ID на источнике
+	IsApartments   bool       `json:"isApartments,omitempty"`   // Апартаменты
+	RentTerms      *RentTerms `json:"rentTerms,omitempty"`      // Условия аренды
+	House          *House     `json:"house,omitempty"`          // Информация о доме
+	History        []History  `json:"history,omitempty"`        // История изменений
+}
+
+// Coordinate координата объявления. API возвращает широту и долготу строкой
+// ("55.823014"), но в сохраненных данных допускается и число
+type Coordinate float64
+
+// UnmarshalJSON разбирает координату из строки или числа
+func (c *Coordinate) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	if s == "" || s == "null" {
+		*c = 0
+		return nil
+	}
+	value, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return fmt.Errorf("invalid coordinate %s: %w", data, err)
+	}
+	*c = Coordinate(value)
+	return nil
+}
+
+// History запись истории изменений объявления
+type History struct {
+	Date           string  `json:"date"`                     // Дата изменения
+	Cost           int     `json:"cost"`                     // Стоимость
+	Phones         []int64 `json:"phones,omitempty"`         // Телефоны
+	PhoneProtected bool    `json:"phoneProtected,omitempty"` // Подменный номер
+}
+
+// GetDate возвращает время изменения
+func (h *History) GetDate() (time.Time, error) {
+	return time.Parse(time.RFC3339, h.Date)
 }
 
 // RentTerms условия аренды
 type RentTerms struct {
-	Commission       int `json:"commission,omitempty"`       // Комиссия
-	CommissionType   int `json:"commissionType,omitempty"`   // 1-процент, 2-фикс.сумма
-	Deposit          int `json:"deposit,omitempty"`          // Залог
-	Utilities        int `json:"utilities,omitempty"`        // 1-арендатор, 2-включено
-	UtilitiesMeters  int `json:"utilitiesMeters,omitempty"`  // Счетчики
-	UtilitiesPrice   int `json:"utilitiesPrice,omitempty"`   // Стоимость ЖКУ
+	Commission      int `json:"commission,omitempty"`      // Комиссия
+	CommissionType  int `json:"commissionType,omitempty"`  // 1-процент, 2-фикс.сумма
+	Deposit         int `json:"deposit,omitempty"`         // Залог
+	Utilities       int `json:"utilities,omitempty"`       // 1-арендатор, 2-включено
+	UtilitiesMeters int `json:"utilitiesMeters,omitempty"` // Счетчики
+	UtilitiesPrice  int `json:"utilitiesPrice,omitempty"`  // Стоимость ЖКУ
 }
 
 // House информация о доме
 type House struct {
-	BuildYear       int `json:"buildYear,omitempty"`
-	CargoLifts      int `json:"cargoLifts,omitempty"`
-	PassengerLifts  int `json:"passengerLifts,omitempty"`
+	BuildYear      int `json:"buildYear,omitempty"`
+	CargoLifts     int `json:"cargoLifts,omitempty"`
+	PassengerLifts int `json:"passengerLifts,omitempty"`
 }
 
 // EstateListResponse ответ на запрос списка объявлений
@@ -124,6 +184,68 @@ type CityListResponse struct {
 	Meta Meta   `json:"meta"`
 }
 
+// Metro представляет станцию метро
+type Metro struct {
+	ID       int    `json:"id"`
+	Title    string `json:"title"`
+	RegionID int    `json:"regionId"`
+	CityID   int    `json:"cityId"`
+}
+
+// MetroListResponse ответ на запрос списка станций метро
+type MetroListResponse struct {
+	Data []Metro `json:"data"`
+	Meta Meta    `json:"meta"`
+}
+
+// Section представляет раздел недвижимости
+type Section struct {
+	ID     int    `json:"id"`
+	TypeID int    `json:"typeId"` // 1-аренда, 2-продажа (не путать с typeAd)
+	Title  string `json:"title"`
+}
+
+// SectionListResponse ответ на запрос списка разделов
+type SectionListResponse struct {
+	Data []Section `json:"data"`
+	Meta Meta      `json:"meta"`
+}
+
+// Category представляет категорию недвижимости
+type Category struct {
+	ID        int    `json:"id"`
+	Title     string `json:"title"`
+	TypeID    int    `json:"typeId"` // 1-аренда, 2-продажа (не путать с typeAd)
+	SectionID int    `json:"sectionId"`
+}
+
+// CategoryListResponse ответ на запрос списка категорий
+type CategoryListResponse struct {
+	Data []Category `json:"data"`
+	Meta Meta       `json:"meta"`
+}
+
+// UserSubscription активная подписка аккаунта InPars
+type UserSubscription struct {
+	RegionID  int    `json:"regionId"`
+	TypeID    int    `json:"typeId"`    // 1-аренда, 2-продажа
+	StartTime string `json:"startTime"` // Дата начала действия подписки
+	EndTime   string `json:"endTime"`   // Дата окончания действия подписки
+	Subscribe string `json:"subscribe"` // Наименование подписки
+	API       bool   `json:"api"`       // true - подписка на API, false - на сайт
+}
+
+// UserSubscriptionListResponse ответ на запрос списка подписок аккаунта
+type UserSubscriptionListResponse struct {
+	Data []UserSubscription `json:"data"`
+	Meta Meta               `json:"meta"`
+}
+
+// GetEndTime возвращает время окончания подписки
+func (s *UserSubscription) GetEndTime() (time.Time, error) {
+	return time.Parse(time.RFC3339, s.EndTime)
+}
+
 // GetTypeAdName возвращает текстовое название типа объявления
 func GetTypeAdName(typeAd int) string {
 	switch typeAd {
@@ -164,6 +286,40 @@ func (e *Estate) GetUpdatedTime() (time.Time, error) {
 	return time.Parse(time.RFC3339, e.Updated)
 }
 
+// SortedHistory возвращает историю изменений, отсортированную от старых к новым
+func (e *Estate) SortedHistory() []History {
+	history := append([]History(nil), e.History...)
+	sort.SliceStable(history, func(i, j int) bool {
+		ti, _ := history[i].GetDate()
+		tj, _ := history[j].GetDate()
+		return ti.Before(tj)
+	})
+	return history
+}
+
+// PreviousCost возвращает предыдущую цену из истории, отличную от текущей.
+// ok = false, если цена не менялась или история не запрошена
+func (e *Estate) PreviousCost() (cost int, ok bool) {
+	history := e.SortedHistory()
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Cost > 0 && history[i].Cost != e.Cost {
+			return history[i].Cost, true
+		}
+	}
+	return 0, false
+}
+
+// ListedSince возвращает момент первого появления объявления:
+// самую раннюю дату в истории, а если истории нет - дату создания
+func (e *Estate) ListedSince() (time.Time, error) {
+	if history := e.SortedHistory(); len(history) > 0 {
+		if t, err := history[0].GetDate(); err == nil {
+			return t, nil
+		}
+	}
+	return e.GetCreatedTime()
+}
+
 // FormatCost форматирует стоимость в читаемый вид
 func (e *Estate) FormatCost() string {
 	if e.Cost == 0 {
@@ -0,0 +1,133 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/RedNessen/inpars-telegram-bot/internal/geo"
+	"github.com/RedNessen/inpars-telegram-bot/internal/inpars"
+	"github.com/RedNessen/inpars-telegram-bot/internal/places"
+	"github.com/RedNessen/inpars-telegram-bot/internal/subscription"
+)
+
+// catchUpMaxPages ограничивает количество запросов при догрузке пропущенных объявлений
+const catchUpMaxPages = 20
+
+// missedEstate объявление, пропущенное чатом за время простоя бота
+type missedEstate struct {
+	estate  inpars.Estate
+	subName string
+}
+
+// catchUpDigest пропущенные объявления, собранные по всем запросам при запуске
+type catchUpDigest struct {
+	missed     map[int64][]missedEstate // Пропущенные объявления по чатам
+	incomplete map[int64]bool           // Чаты, для которых догрузка прошла не полностью
+}
+
+func newCatchUpDigest() *catchUpDigest {
+	return &catchUpDigest{
+		missed:     make(map[int64][]missedEstate),
+		incomplete: make(map[int64]bool),
+	}
+}
+
+// markIncomplete отмечает, что чаты подписок запроса получили не все пропущенное
+func (d *catchUpDigest) markIncomplete(query *subscription.Query) {
+	for _, sub := range query.Subscriptions {
+		d.incomplete[sub.ChatID] = true
+	}
+}
+
+// catchUp догружает объявления, опубликованные пока бот был остановлен.
+//
+// Запрос постранично проходит коллекцию от сохраненного курсора подписок до
+// текущего момента и складывает найденное в digest. Подписки без курсора
+// к этому моменту уже инициализированы последними объявлениями, поэтому
+// нулевой курсор означает, что догружать нечего
+func (m *Monitor) catchUp(ctx context.Context, query *subscription.Query, digest *catchUpDigest) error {
+	params := m.buildParams(query)
+	params.Limit = m.config.MaxListings
+	params.LastID = m.queryCursor(query)
+
+	if params.LastID == 0 {
+		return nil
+	}
+
+	maxID := params.LastID
+	var seen []int
+
+	result, err := m.walkPages(ctx, params, catchUpMaxPages, func(page []inpars.Estate) {
+		for i := range page {
+			estate := &page[i]
+			if estate.ID > maxID {
+				maxID = estate.ID
+			}
+
+			if m.seenIDs[estate.ID] {
+				continue
+			}
+			m.seenIDs[estate.ID] = true
+			seen = append(seen, estate.ID)
+
+			for _, match := range m.matchSubscriptions(query, estate) {
+				digest.missed[match.ChatID] = append(digest.missed[match.ChatID], missedEstate{estate: *estate, subName: match.Name})
+			}
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("failed to get missed listings: %w", err)
+	}
+
+	if !result.Complete {
+		log.Printf("Catch-up stopped after %d pages, the rest will arrive with regular polling", result.Pages)
+		digest.markIncomplete(query)
+	}
+
+	m.advanceCursors(query, maxID)
+	if maxID > m.lastUpdateID {
+		m.lastUpdateID = maxID
+	}
+	m.saveProgress(query, seen)
+
+	return nil
+}
+
+// deliverMissed отправляет каждому чату одну сводку по всем его подпискам
+// и самые свежие пропущенные объявления
+func (m *Monitor) deliverMissed(digest *catchUpDigest) {
+	for chatID, items := range digest.missed {
+		// Объявления разных запросов идут по возрастанию ID, самые свежие - в конце
+		sort.SliceStable(items, func(i, j int) bool {
+			return items[i].estate.ID < items[j].estate.ID
+		})
+
+		shown := items
+		if len(shown) > m.config.CatchUpMax {
+			shown = shown[len(shown)-max(m.config.CatchUpMax, 0):]
+		}
+
+		log.Printf("Chat %d missed %d listings while offline, sending %d", chatID, len(items), len(shown))
+
+		if chatPlaces := m.places.ForChat(chatID); chatPlaces.SortByDistance {
+			places.SortByNearest(chatPlaces, shown, func(item missedEstate) geo.Point {
+				return subscription.PointOf(&item.estate)
+			})
+		}
+
+		complete := !digest.incomplete[chatID]
+		if err := m.bot.SendCatchUpSummary(chatID, len(items), len(shown), m.offlineSince, complete); err != nil {
+			log.Printf("Failed to send catch-up summary: %v", err)
+			continue
+		}
+
+		for _, item := range shown {
+			group, _ := m.duplicates.Add(&item.estate)
+			if m.sendToChat(chatID, group, &item.estate, item.subName) {
+				m.track(&item.estate, map[int64]string{chatID: item.subName})
+			}
+		}
+	}
+}
@@ -1,44 +1,101 @@
 package monitor
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"time"
 
 	"github.com/RedNessen/inpars-telegram-bot/internal/config"
+	"github.com/RedNessen/inpars-telegram-bot/internal/dedup"
+	"github.com/RedNessen/inpars-telegram-bot/internal/geo"
 	"github.com/RedNessen/inpars-telegram-bot/internal/inpars"
+	"github.com/RedNessen/inpars-telegram-bot/internal/places"
+	"github.com/RedNessen/inpars-telegram-bot/internal/storage"
+	"github.com/RedNessen/inpars-telegram-bot/internal/subscription"
 	"github.com/RedNessen/inpars-telegram-bot/internal/telegram"
 )
 
 // Monitor отслеживает новые объявления и отправляет уведомления
 type Monitor struct {
-	client       *inpars.Client
-	bot          *telegram.Bot
-	config       *config.Config
-	lastUpdateID int                // ID последнего обработанного объявления
-	seenIDs      map[int]bool       // Множество уже обработанных ID
-	lastUpdate   time.Time          // Время последнего обновления
+	client         *inpars.Client
+	bot            *telegram.Bot
+	subs           *subscription.Manager
+	places         *places.Manager
+	store          storage.Storage
+	config         *config.Config
+	cursors        map[string]int // Последний обработанный ID для каждой подписки
+	lastUpdateID   int            // ID последнего обработанного объявления
+	seenIDs        map[int]bool   // Множество уже обработанных ID
+	lastUpdate     time.Time      // Время последнего обновления
+	offlineSince   time.Time      // Время последней проверки перед перезапуском
+	lastUpdatePoll time.Time      // Время последней проверки изменений объявлений
+	duplicates     *dedup.Index   // Копии объявлений с разных площадок
+	dedupOpts      dedup.Options  // Допуски сравнения копий, в том числе со скрытыми квартирами
+	areas          *geo.Index     // Ограничения подписок по местоположению
 }
 
 // NewMonitor создает новый монитор
-func NewMonitor(client *inpars.Client, bot *telegram.Bot, cfg *config.Config) *Monitor {
+func NewMonitor(client *inpars.Client, bot *telegram.Bot, subs *subscription.Manager, chatPlaces *places.Manager, store storage.Storage, cfg *config.Config) *Monitor {
 	return &Monitor{
 		client:     client,
 		bot:        bot,
+		subs:       subs,
+		places:     chatPlaces,
+		store:      store,
 		config:     cfg,
+		cursors:    make(map[string]int),
 		seenIDs:    make(map[int]bool),
 		lastUpdate: time.Now(),
+		duplicates: dedup.NewIndex(dedupOptions(cfg)),
+		dedupOpts:  dedupOptions(cfg),
+		areas:      geo.NewIndex(),
 	}
 }
 
-// Start запускает мониторинг
-func (m *Monitor) Start() error {
+// dedupOptions возвращает допуски поиска копий из конфигурации
+func dedupOptions(cfg *config.Config) dedup.Options {
+	opts := dedup.DefaultOptions()
+	opts.Window = time.Duration(cfg.DedupWindowHours) * time.Hour
+	opts.PriceTolerancePercent = float64(cfg.DedupPriceTolerance)
+	opts.MaxDistance = float64(cfg.DedupMaxDistance)
+	return opts
+}
+
+// Start запускает мониторинг и работает до отмены контекста.
+// Текущая порция рассылки при отмене завершается, а прогресс сохраняется
+func (m *Monitor) Start(ctx context.Context) error {
 	log.Println("Starting monitoring service...")
 
-	// Инициализация: получаем последние объявления чтобы не отправлять старые при старте
-	if err := m.initializeLastSeen(); err != nil {
-		log.Printf("Warning: failed to initialize last seen: %v", err)
+	// Восстанавливаем курсоры подписок и обработанные объявления из хранилища
+	if err := m.restoreState(); err != nil {
+		return fmt.Errorf("failed to restore monitor state: %w", err)
+	}
+
+	// Инициализация: новые подписки начинают с последних объявлений, а для
+	// остальных догружаем объявления, опубликованные пока бот был остановлен
+	// Пропущенное собирается по всем запросам, чтобы каждый чат получил одну сводку
+	subs := m.activeSubscriptions()
+	m.indexAreas(subs)
+	digest := newCatchUpDigest()
+	for _, query := range subscription.Plan(subs) {
+		if ctx.Err() != nil {
+			break
+		}
+		if err := m.initializeLastSeen(ctx, query); err != nil {
+			log.Printf("Warning: failed to initialize last seen: %v", err)
+			continue
+		}
+		if err := m.catchUp(ctx, query, digest); err != nil {
+			log.Printf("Warning: failed to catch up missed listings: %v", err)
+			digest.markIncomplete(query)
+		}
+	}
+	m.deliverMissed(digest)
+	if ctx.Err() != nil {
+		return nil
 	}
+	m.savePollTime()
 
 	// Запускаем цикл мониторинга
 	ticker := time.NewTicker(time.Duration(m.config.PollInterval) * time.Second)
@@ -46,93 +103,201 @@ func (m *Monitor) Start() error {
 
 	log.Printf("Monitoring started with interval: %d seconds", m.config.PollInterval)
 
+	// Проверка изменений отправленных объявлений идет отдельным, более редким циклом
+	var updates <-chan time.Time
+	if m.config.UpdatePollInterval > 0 {
+		updateTicker := time.NewTicker(time.Duration(m.config.UpdatePollInterval) * time.Second)
+		defer updateTicker.Stop()
+		updates = updateTicker.C
+		log.Printf("Update notifications enabled with interval: %d seconds", m.config.UpdatePollInterval)
+	}
+
+	// Объявления из /watch запрашиваются по одному, поэтому проверяются реже
+	var watches <-chan time.Time
+	if m.config.WatchPollInterval > 0 {
+		watchTicker := time.NewTicker(time.Duration(m.config.WatchPollInterval) * time.Second)
+		defer watchTicker.Stop()
+		watches = watchTicker.C
+		log.Printf("Watched listings are checked every %d seconds", m.config.WatchPollInterval)
+	}
+
 	for {
 		select {
+		case <-ctx.Done():
+			log.Println("Monitoring stopped")
+			return nil
 		case <-ticker.C:
-			if err := m.checkForNewListings(); err != nil {
+			if err := m.checkForNewListings(ctx); err != nil {
 				log.Printf("Error checking for new listings: %v", err)
 			}
+		case <-updates:
+			if err := m.checkForUpdates(ctx); err != nil {
+				log.Printf("Error checking for updated listings: %v", err)
+			}
+		case <-watches:
+			if err := m.checkWatches(ctx); err != nil {
+				log.Printf("Error checking watched listings: %v", err)
+			}
 		}
 	}
 }
 
-// initializeLastSeen инициализирует список уже существующих объявлений
-func (m *Monitor) initializeLastSeen() error {
-	log.Println("Initializing last seen listings...")
+// restoreState загружает сохраненный прогресс мониторинга
+func (m *Monitor) restoreState() error {
+	cursors, err := m.store.Cursors()
+	if err != nil {
+		return err
+	}
+	for key, id := range cursors {
+		m.cursors[key] = id
+		if id > m.lastUpdateID {
+			m.lastUpdateID = id
+		}
+	}
 
-	params := m.buildParams()
-	params.Limit = m.config.MaxListings
-	params.SortBy = "id_desc" // Сортируем по ID в порядке убывания
+	seen, err := m.store.SeenIDs()
+	if err != nil {
+		return err
+	}
+	for _, id := range seen {
+		m.seenIDs[id] = true
+	}
 
-	resp, err := m.client.GetEstateList(params)
+	m.offlineSince, err = m.store.LastPoll()
 	if err != nil {
-		return fmt.Errorf("failed to get initial listings: %w", err)
+		return err
 	}
 
-	// Сохраняем ID существующих объявлений
-	for _, estate := range resp.Data {
-		m.seenIDs[estate.ID] = true
-		if estate.ID > m.lastUpdateID {
-			m.lastUpdateID = estate.ID
-		}
+	m.lastUpdatePoll, err = m.store.LastUpdatePoll()
+	if err != nil {
+		return err
 	}
 
-	log.Printf("Initialized with %d existing listings. Last ID: %d", len(resp.Data), m.lastUpdateID)
+	log.Printf("Restored %d subscription cursors and %d seen listings. Last ID: %d",
+		len(cursors), len(seen), m.lastUpdateID)
 	return nil
 }
 
-// checkForNewListings проверяет наличие новых объявлений
-func (m *Monitor) checkForNewListings() error {
-	// Проверяем, есть ли активные чаты
-	if !m.bot.HasActiveChats() {
-		log.Println("No active chats, skipping check...")
+// savePollTime запоминает время последней проверки
+func (m *Monitor) savePollTime() {
+	m.lastUpdate = time.Now()
+	if err := m.store.SaveLastPoll(m.lastUpdate); err != nil {
+		log.Printf("Failed to save poll time: %v", err)
+	}
+}
+
+// saveProgress сохраняет курсоры подписок запроса и новые обработанные объявления
+func (m *Monitor) saveProgress(query *subscription.Query, seen []int) {
+	cursors := make(map[string]int, len(query.Subscriptions))
+	for _, sub := range query.Subscriptions {
+		cursors[sub.Key()] = m.cursors[sub.Key()]
+	}
+
+	if err := m.store.SaveCursors(cursors); err != nil {
+		log.Printf("Failed to save cursors: %v", err)
+	}
+	if err := m.store.MarkSeen(seen); err != nil {
+		log.Printf("Failed to save seen listings: %v", err)
+	}
+}
+
+// activeSubscriptions возвращает подписки чатов, которые получают уведомления
+func (m *Monitor) activeSubscriptions() []*subscription.Subscription {
+	var active []*subscription.Subscription
+	for _, sub := range m.subs.All() {
+		if m.bot.IsChatActive(sub.ChatID) {
+			active = append(active, sub)
+		}
+	}
+	return active
+}
+
+// initializeLastSeen запоминает последние существующие объявления для подписок
+// запроса, у которых еще нет курсора, чтобы не отправлять старые объявления
+func (m *Monitor) initializeLastSeen(ctx context.Context, query *subscription.Query) error {
+	var pending []*subscription.Subscription
+	for _, sub := range query.Subscriptions {
+		if _, ok := m.cursors[sub.Key()]; !ok {
+			pending = append(pending, sub)
+		}
+	}
+	if len(pending) == 0 {
 		return nil
 	}
 
-	log.Println("Checking for new listings...")
+	log.Printf("Initializing last seen listings for %d subscriptions...", len(pending))
 
-	params := m.buildParams()
+	params := m.buildParams(query)
 	params.Limit = m.config.MaxListings
 	params.SortBy = "id_desc" // Сортируем по ID в порядке убывания
 
-	// Если есть последний ID, запрашиваем объявления с ID больше него
-	if m.lastUpdateID > 0 {
-		params.LastID = m.lastUpdateID
-		params.SortBy = "id_asc" // При использовании lastId используем сортировку по возрастанию
-	}
-
-	resp, err := m.client.GetEstateList(params)
+	resp, err := m.client.GetEstateListContext(ctx, params)
 	if err != nil {
-		return fmt.Errorf("failed to get estate list: %w", err)
+		return fmt.Errorf("failed to get initial listings: %w", err)
 	}
 
-	// Обрабатываем новые объявления
-	newCount := 0
+	// Сохраняем ID существующих объявлений. Если у части подписок запроса курсор
+	// уже есть, объявления не помечаются как обработанные: их еще ждут эти подписки
+	markSeen := len(pending) == len(query.Subscriptions)
+	lastID := 0
+	var seen []int
 	for _, estate := range resp.Data {
-		// Пропускаем уже обработанные объявления
-		if m.seenIDs[estate.ID] {
-			continue
+		if markSeen {
+			m.seenIDs[estate.ID] = true
+			seen = append(seen, estate.ID)
+		}
+		if estate.ID > lastID {
+			lastID = estate.ID
 		}
+	}
+	if lastID > m.lastUpdateID {
+		m.lastUpdateID = lastID
+	}
 
-		// Отмечаем как обработанное
-		m.seenIDs[estate.ID] = true
+	for _, sub := range pending {
+		m.cursors[sub.Key()] = lastID
+	}
+	m.saveProgress(query, seen)
 
-		// Обновляем последний ID
-		if estate.ID > m.lastUpdateID {
-			m.lastUpdateID = estate.ID
-		}
+	log.Printf("Initialized with %d existing listings. Last ID: %d", len(resp.Data), lastID)
+	return nil
+}
 
-		// Отправляем уведомление
-		if err := m.bot.SendEstate(&estate); err != nil {
-			log.Printf("Failed to send estate %d: %v", estate.ID, err)
-			continue
-		}
+// checkForNewListings проверяет наличие новых объявлений по всем подпискам
+func (m *Monitor) checkForNewListings(ctx context.Context) error {
+	subs := m.activeSubscriptions()
 
-		newCount++
-		log.Printf("Sent new listing: ID=%d, Title=%s", estate.ID, estate.Title)
+	// Проверяем, есть ли активные подписки
+	if len(subs) == 0 {
+		log.Println("No active subscriptions, skipping check...")
+		return nil
+	}
+
+	m.pruneCursors()
+	m.indexAreas(subs)
+
+	queries := subscription.Plan(subs)
+	log.Printf("Checking for new listings: %d subscriptions in %d queries...", len(subs), len(queries))
 
-		// Задержка между отправками, чтобы избежать флуда
-		time.Sleep(500 * time.Millisecond)
+	// Планируем запросы по оставшемуся лимиту: клиент сам дождется его
+	// восстановления, но цикл проверки при этом растянется
+	if budget := m.client.RateBudget(); budget.Known() && budget.Remaining < len(queries) {
+		log.Printf("Rate budget is low (%d requests left for %d queries), check will be paced until %s",
+			budget.Remaining, len(queries), budget.Reset.Format("15:04:05"))
+	}
+
+	for _, query := range queries {
+		// При остановке не начинаем новые запросы
+		if ctx.Err() != nil {
+			break
+		}
+		if err := m.initializeLastSeen(ctx, query); err != nil {
+			log.Printf("Warning: failed to initialize last seen: %v", err)
+			continue
+		}
+		if err := m.processQuery(ctx, query); err != nil {
+			log.Printf("Error processing query: %v", err)
+		}
 	}
 
 	// Очищаем старые записи из seenIDs для экономии памяти
@@ -140,61 +305,280 @@ func (m *Monitor) checkForNewListings() error {
 	if len(m.seenIDs) > 10000 {
 		m.cleanupSeenIDs()
 	}
+	m.duplicates.Prune()
+
+	m.savePollTime()
+	return nil
+}
+
+// processQuery выполняет объединенный запрос и рассылает объявления подходящим подпискам.
+// Если новых объявлений больше, чем помещается в одну страницу, запрос
+// продолжается постранично в рамках того же цикла проверки
+func (m *Monitor) processQuery(ctx context.Context, query *subscription.Query) error {
+	params := m.buildParams(query)
+	params.Limit = m.config.MaxListings
+
+	// Запрашиваем объявления с ID больше наименьшего курсора подписок запроса
+	params.LastID = m.queryCursor(query)
+
+	newCount := 0
+	maxID := params.LastID
+	result, err := m.walkPages(ctx, params, m.config.MaxPagesPerPoll, func(page []inpars.Estate) {
+		var seen []int
+		var fresh []*inpars.Estate
+		for i := range page {
+			estate := &page[i]
+			if estate.ID > maxID {
+				maxID = estate.ID
+			}
+
+			// Пропускаем уже обработанные объявления
+			if m.seenIDs[estate.ID] {
+				continue
+			}
+
+			// Отмечаем как обработанное
+			m.seenIDs[estate.ID] = true
+			seen = append(seen, estate.ID)
+
+			// Обновляем последний ID
+			if estate.ID > m.lastUpdateID {
+				m.lastUpdateID = estate.ID
+			}
+
+			fresh = append(fresh, estate)
+		}
+		newCount += m.deliver(query, fresh)
+
+		// Сдвигаем курсоры после каждой страницы, чтобы не потерять прогресс при сбое
+		m.advanceCursors(query, maxID)
+		m.saveProgress(query, seen)
+	})
+	if err != nil {
+		return err
+	}
+
+	if !result.Complete {
+		log.Printf("Stopped after %d pages, remaining listings will be fetched on the next tick", result.Pages)
+	}
 
 	if newCount > 0 {
-		log.Printf("Found and sent %d new listings. Last ID: %d", newCount, m.lastUpdateID)
+		log.Printf("Found and sent %d new listings in %d pages. Last ID: %d", newCount, result.Pages, maxID)
 	} else {
 		log.Println("No new listings found")
 	}
 
 	// Выводим информацию о rate limiting
-	if resp.Meta.RateRemaining > 0 {
-		log.Printf("Rate limit: %d/%d remaining, resets in %d seconds",
-			resp.Meta.RateRemaining, resp.Meta.RateLimit, resp.Meta.RateReset)
+	if budget := m.client.RateBudget(); budget.Known() {
+		log.Printf("Rate limit: %d/%d remaining, resets in %s",
+			budget.Remaining, budget.Limit, time.Until(budget.Reset).Round(time.Second))
 	}
 
-	m.lastUpdate = time.Now()
 	return nil
 }
 
-// buildParams создает параметры запроса на основе конфигурации
-func (m *Monitor) buildParams() *inpars.EstateListParams {
-	params := &inpars.EstateListParams{
-		TypeAd:     m.config.TypeAd,
-		SellerType: m.config.SellerTypes,
-		Expand: []string{
-			"region", "city", "metro", "category",
-			"material", "rentTime", "rooms", "rentTerms",
-		},
+// delivery объявление, которое нужно отправить в чат
+type delivery struct {
+	estate  *inpars.Estate
+	group   *dedup.Group
+	subName string
+}
+
+// deliver отправляет объявления каждому подходящему чату не более одного раза.
+// Чаты с сортировкой по расстоянию получают объявления от ближних к дальним.
+// Возвращает количество объявлений, отправленных хотя бы в один чат
+func (m *Monitor) deliver(query *subscription.Query, estates []*inpars.Estate) int {
+	byChat := make(map[int64][]delivery)
+	var chatOrder []int64
+	for _, estate := range estates {
+		group, _ := m.duplicates.Add(estate)
+		for _, match := range m.matchSubscriptions(query, estate) {
+			if _, ok := byChat[match.ChatID]; !ok {
+				chatOrder = append(chatOrder, match.ChatID)
+			}
+			byChat[match.ChatID] = append(byChat[match.ChatID], delivery{estate: estate, group: group, subName: match.Name})
+		}
+	}
+
+	delivered := make(map[int]map[int64]string)
+	for _, chatID := range chatOrder {
+		items := byChat[chatID]
+		m.sortByDistance(chatID, items)
+
+		// Копии из одной порции уже есть в карточке группы, отправленной первой
+		sent := make(map[*dedup.Group]bool)
+		for _, item := range items {
+			if !sent[item.group] {
+				if !m.sendToChat(chatID, item.group, item.estate, item.subName) {
+					continue
+				}
+				sent[item.group] = true
+			}
+			if delivered[item.estate.ID] == nil {
+				delivered[item.estate.ID] = make(map[int64]string)
+			}
+			delivered[item.estate.ID][chatID] = item.subName
+		}
 	}
 
-	// Регионы
-	if len(m.config.DefaultRegions) > 0 {
-		params.RegionID = m.config.DefaultRegions
+	count := 0
+	for _, estate := range estates {
+		chats := delivered[estate.ID]
+		if len(chats) == 0 {
+			continue
+		}
+		m.track(estate, chats)
+		count++
+		log.Printf("Sent new listing: ID=%d, Title=%s", estate.ID, estate.Title)
 	}
+	return count
+}
 
-	// Города
-	if len(m.config.DefaultCities) > 0 {
-		params.CityID = m.config.DefaultCities
+// sortByDistance упорядочивает объявления от ближних к дальним, если чат включил сортировку
+func (m *Monitor) sortByDistance(chatID int64, items []delivery) {
+	chatPlaces := m.places.ForChat(chatID)
+	if !chatPlaces.SortByDistance || len(chatPlaces.Places) == 0 || len(items) < 2 {
+		return
 	}
+	places.SortByNearest(chatPlaces, items, func(d delivery) geo.Point {
+		return subscription.PointOf(d.estate)
+	})
+}
 
-	// Фильтры по цене
-	if m.config.MinCost > 0 {
-		params.CostMin = m.config.MinCost
+// isHidden проверяет, скрыл ли чат объявление. Кроме ID объявлений сравниваются
+// отпечатки квартир без учета цены, чтобы не присылать повторные публикации
+// скрытой квартиры, вышедшие после того, как ее копии пропали из индекса
+func (m *Monitor) isHidden(chatID int64, estate *inpars.Estate, ids ...int) bool {
+	if m.bot.IsHidden(chatID, ids...) {
+		return true
 	}
-	if m.config.MaxCost > 0 {
-		params.CostMax = m.config.MaxCost
+	hidden := m.bot.HiddenFingerprints(chatID)
+	if len(hidden) == 0 {
+		return false
 	}
+	fp := dedup.FingerprintOf(estate)
+	for _, h := range hidden {
+		if fp.SameFlat(h, m.dedupOpts) {
+			return true
+		}
+	}
+	return false
+}
 
-	// Фильтры по этажам
-	if m.config.FloorMin > 0 {
-		params.FloorMin = m.config.FloorMin
+// sendToChat отправляет объявление в чат. Копия объявления, уже отправленного
+// в этот чат с другой площадки, не создает новую карточку: в отправленную
+// карточку добавляется ссылка на новый источник
+func (m *Monitor) sendToChat(chatID int64, group *dedup.Group, estate *inpars.Estate, subName string) bool {
+	// Чат скрыл это объявление, одну из его копий или ту же квартиру раньше
+	if m.isHidden(chatID, estate, group.IDs()...) {
+		log.Printf("Estate %d is hidden in chat %d, skipping", estate.ID, chatID)
+		return false
 	}
-	if m.config.FloorMax > 0 {
-		params.FloorMax = m.config.FloorMax
+
+	if card, ok := group.Cards[chatID]; ok {
+		card, err := m.bot.UpdateEstateGroup(chatID, card, group)
+		if err != nil {
+			log.Printf("Failed to add source of estate %d to card: %v", estate.ID, err)
+			return false
+		}
+		group.Cards[chatID] = card
+		log.Printf("Estate %d is a duplicate of %d, card in chat %d updated", estate.ID, group.Estate.ID, chatID)
+		return true
+	}
+
+	card, err := m.bot.SendEstateGroup(chatID, group, subName)
+	if err != nil {
+		log.Printf("Failed to send estate %d: %v", estate.ID, err)
+		return false
+	}
+	group.Cards[chatID] = card
+
+	// Задержка между отправками, чтобы избежать флуда
+	time.Sleep(500 * time.Millisecond)
+	return true
+}
+
+// advanceCursors сдвигает курсоры всех подписок запроса до lastID
+func (m *Monitor) advanceCursors(query *subscription.Query, lastID int) {
+	for _, sub := range query.Subscriptions {
+		if lastID > m.cursors[sub.Key()] {
+			m.cursors[sub.Key()] = lastID
+		}
+	}
+}
+
+// pruneCursors удаляет курсоры подписок, которых больше не существует,
+// чтобы пересозданная подписка с тем же именем начинала с текущего момента
+func (m *Monitor) pruneCursors() {
+	exists := make(map[string]bool)
+	for _, sub := range m.subs.All() {
+		exists[sub.Key()] = true
+	}
+	for key := range m.cursors {
+		if !exists[key] {
+			delete(m.cursors, key)
+		}
+	}
+}
+
+// matchSubscriptions возвращает подписки запроса, под которые подходит объявление,
+// не более одной на чат. Учитываются и ограничения расстояния от мест чата
+func (m *Monitor) matchSubscriptions(query *subscription.Query, estate *inpars.Estate) []*subscription.Subscription {
+	var matches []*subscription.Subscription
+	chats := make(map[int64]bool)
+	point := subscription.PointOf(estate)
+
+	// Подписки, в район которых попадает объявление, находим одним запросом к индексу
+	var inArea map[string]bool
+	if !point.IsZero() && m.areas.Len() > 0 {
+		inArea = m.areas.Query(point)
+	}
+
+	for _, sub := range query.Subscriptions {
+		if chats[sub.ChatID] || estate.ID <= m.cursors[sub.Key()] {
+			continue
+		}
+		if !sub.Area.IsEmpty() && !inArea[sub.Key()] || !sub.MatchesAttributes(estate) {
+			continue
+		}
+		if !m.places.ForChat(sub.ChatID).Allows(point) {
+			continue
+		}
+		chats[sub.ChatID] = true
+		matches = append(matches, sub)
+	}
+
+	return matches
+}
+
+// indexAreas строит пространственный индекс ограничений подписок по местоположению
+func (m *Monitor) indexAreas(subs []*subscription.Subscription) {
+	index := geo.NewIndex()
+	for _, sub := range subs {
+		for _, shape := range sub.Area.Shapes() {
+			index.Insert(sub.Key(), shape)
+		}
 	}
+	m.areas = index
+}
 
-	return params
+// queryCursor возвращает наименьший курсор среди подписок запроса
+func (m *Monitor) queryCursor(query *subscription.Query) int {
+	cursor := 0
+	for i, sub := range query.Subscriptions {
+		c := m.cursors[sub.Key()]
+		if i == 0 || c < cursor {
+			cursor = c
+		}
+	}
+	return cursor
+}
+
+// buildParams создает параметры запроса на основе объединенных фильтров подписок
+func (m *Monitor) buildParams(query *subscription.Query) *inpars.EstateListParams {
+	params := *query.Params
+	params.Expand = append([]string(nil), inpars.DefaultExpand...)
+	return &params
 }
 
 // cleanupSeenIDs очищает старые записи из seenIDs
@@ -212,6 +596,9 @@ func (m *Monitor) cleanupSeenIDs() {
 	}
 
 	m.seenIDs = newSeenIDs
+	if err := m.store.PruneSeen(minID); err != nil {
+		log.Printf("Failed to prune seen listings: %v", err)
+	}
 	log.Printf("Cleaned up seen IDs. Current count: %d", len(m.seenIDs))
 }
 
@@ -222,10 +609,14 @@ func (m *Monitor) GetStatus() string {
 			"Last Update: %s\n"+
 			"Last ID: %d\n"+
 			"Seen IDs: %d\n"+
-			"Active Chats: %d",
+			"Active Chats: %d\n"+
+			"Subscriptions: %d\n"+
+			"Duplicate Groups: %d",
 		m.lastUpdate.Format("2006-01-02 15:04:05"),
 		m.lastUpdateID,
 		len(m.seenIDs),
 		len(m.bot.GetActiveChatIDs()),
+		len(m.subs.All()),
+		m.duplicates.Len(),
 	)
 }
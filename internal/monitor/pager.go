@@ -0,0 +1,59 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/RedNessen/inpars-telegram-bot/internal/inpars"
+)
+
+// pageResult итог постраничного обхода
+type pageResult struct {
+	Pages    int         // Количество выполненных запросов
+	Complete bool        // Обход дошел до текущего момента (последняя страница неполная)
+	Meta     inpars.Meta // Метаданные последнего ответа
+}
+
+// walkPages постранично запрашивает объявления с ID больше params.LastID.
+//
+// Если страница заполнена целиком (len == Limit), запрашивается следующая с
+// lastId последнего полученного объявления, пока страницы не закончатся или
+// не будет достигнут maxPages. Частоту запросов выравнивает inpars.Client
+// по лимиту из ответов сервера, поэтому серия запросов не упирается в 429.
+// Обход прекращается между страницами при отмене контекста.
+// Ошибка возвращается только если не удалось получить первую страницу
+func (m *Monitor) walkPages(ctx context.Context, params *inpars.EstateListParams, maxPages int, handle func(page []inpars.Estate)) (pageResult, error) {
+	var result pageResult
+	params.SortBy = "id_asc" // При использовании lastId используем сортировку по возрастанию
+
+	for result.Pages < maxPages && ctx.Err() == nil {
+		resp, err := m.client.GetEstateListContext(ctx, params)
+		if err != nil {
+			if result.Pages == 0 {
+				return result, fmt.Errorf("failed to get estate list: %w", err)
+			}
+			log.Printf("Pagination interrupted on page %d: %v", result.Pages+1, err)
+			return result, nil
+		}
+		result.Pages++
+		result.Meta = resp.Meta
+
+		handle(resp.Data)
+
+		if len(resp.Data) < params.Limit || params.Limit == 0 {
+			result.Complete = true
+			return result, nil
+		}
+
+		// Следующая страница: объявления с ID больше последнего полученного
+		for _, estate := range resp.Data {
+			if estate.ID > params.LastID {
+				params.LastID = estate.ID
+			}
+		}
+		params.TimeStart = 0
+	}
+
+	return result, nil
+}
@@ -0,0 +1,268 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"github.com/RedNessen/inpars-telegram-bot/internal/inpars"
+	"github.com/RedNessen/inpars-telegram-bot/internal/storage"
+	"github.com/RedNessen/inpars-telegram-bot/internal/subscription"
+	"github.com/RedNessen/inpars-telegram-bot/internal/telegram"
+)
+
+// trackedTTL сколько хранить снимки отправленных объявлений для отслеживания изменений
+const trackedTTL = 30 * 24 * time.Hour
+
+// track сохраняет снимок объявления, отправленного в чаты, чтобы позже
+// сравнить его с обновленной версией
+func (m *Monitor) track(estate *inpars.Estate, chats map[int64]string) {
+	if len(chats) == 0 {
+		return
+	}
+
+	tracked := &storage.TrackedEstate{Estate: *estate, Chats: chats, Saved: time.Now()}
+	if existing, err := m.store.Tracked([]int{estate.ID}); err == nil {
+		if prev, ok := existing[estate.ID]; ok {
+			for chatID, name := range prev.Chats {
+				if _, ok := tracked.Chats[chatID]; !ok {
+					tracked.Chats[chatID] = name
+				}
+			}
+		}
+	}
+
+	if err := m.store.SaveTracked(tracked); err != nil {
+		log.Printf("Failed to save tracked estate %d: %v", estate.ID, err)
+	}
+}
+
+// checkForUpdates запрашивает объявления, обновленные с прошлой проверки
+// (sortBy=updated_desc, timeStart), и уведомляет чаты об изменениях
+// объявлений, которые им уже были отправлены.
+// Время проверки сдвигается, только если все запросы прошли до конца: иначе
+// следующая проверка повторит интервал, а уже разосланные изменения не
+// повторятся, потому что снимки объявлений к этому моменту обновлены
+func (m *Monitor) checkForUpdates(ctx context.Context) error {
+	subs := m.activeSubscriptions()
+	if len(subs) == 0 {
+		return nil
+	}
+
+	since := m.lastUpdatePoll
+	if since.IsZero() {
+		since = time.Now().Add(-time.Duration(m.config.UpdatePollInterval) * time.Second)
+	}
+	started := time.Now()
+
+	queries := subscription.Plan(subs)
+	log.Printf("Checking for updated listings since %s in %d queries...", since.Format("15:04:05"), len(queries))
+
+	notified := 0
+	complete := true
+	for _, query := range queries {
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		params := m.buildParams(query)
+		params.Limit = m.config.MaxListings
+		params.TimeStart = since.Unix()
+
+		// Граничные объявления соседних страниц повторяются, обрабатываем их один раз
+		processed := make(map[int]bool)
+		result, err := m.walkUpdated(ctx, params, m.config.MaxPagesPerPoll, func(page []inpars.Estate) {
+			var ids []int
+			for _, estate := range page {
+				if !processed[estate.ID] {
+					ids = append(ids, estate.ID)
+				}
+			}
+			if len(ids) == 0 {
+				return
+			}
+
+			tracked, err := m.store.Tracked(ids)
+			if err != nil {
+				log.Printf("Failed to load tracked estates: %v", err)
+				return
+			}
+
+			for i := range page {
+				estate := &page[i]
+				if processed[estate.ID] {
+					continue
+				}
+				processed[estate.ID] = true
+
+				if t, ok := tracked[estate.ID]; ok {
+					notified += m.notifyUpdate(t, estate)
+				}
+			}
+		})
+		if err != nil {
+			log.Printf("Error checking for updates: %v", err)
+			complete = false
+			continue
+		}
+		if !result.Complete {
+			log.Printf("Update check stopped after %d pages, older updates will be checked next time", result.Pages)
+			complete = false
+		}
+	}
+
+	if complete {
+		m.lastUpdatePoll = started
+		if err := m.store.SaveLastUpdatePoll(started); err != nil {
+			log.Printf("Failed to save update poll time: %v", err)
+		}
+	} else if m.lastUpdatePoll.IsZero() {
+		// Первая проверка не закончена: следующая начнет с того же момента
+		m.lastUpdatePoll = since
+	}
+	if err := m.store.PruneTracked(time.Now().Add(-trackedTTL)); err != nil {
+		log.Printf("Failed to prune tracked estates: %v", err)
+	}
+
+	if notified > 0 {
+		log.Printf("Sent %d update notifications", notified)
+	}
+	return nil
+}
+
+// notifyUpdate сравнивает объявление со снимком и уведомляет чаты, получившие его.
+// Снимок обновляется, только если уведомление получил хотя бы один чат, а цена
+// в нем меняется, только если о ней сообщили. Поэтому небольшие изменения цены
+// накапливаются, пока не превысят порог
+func (m *Monitor) notifyUpdate(tracked *storage.TrackedEstate, estate *inpars.Estate) int {
+	change := m.diffEstate(&tracked.Estate, estate)
+	if change.IsEmpty() {
+		return 0
+	}
+
+	sent := 0
+	for chatID, subName := range tracked.Chats {
+		// Не уведомляем чаты, которые отключили бота, удалили подписку или скрыли объявление
+		if _, ok := m.subs.Get(chatID, subName); !ok || !m.bot.IsChatActive(chatID) || m.isHidden(chatID, estate, estate.ID) {
+			continue
+		}
+		if err := m.bot.SendEstateUpdate(chatID, estate, change, subName); err != nil {
+			log.Printf("Failed to send update for estate %d: %v", estate.ID, err)
+			continue
+		}
+		sent++
+
+		// Задержка между отправками, чтобы избежать флуда
+		time.Sleep(500 * time.Millisecond)
+	}
+
+	log.Printf("Estate %d changed (cost %d -> %d, phones: %t, republished: %t), notified %d chats",
+		estate.ID, tracked.Estate.Cost, estate.Cost, change.PhonesChanged, change.Republished, sent)
+
+	if sent == 0 {
+		return 0
+	}
+
+	cost := tracked.Estate.Cost
+	tracked.Estate = *estate
+	if change.NewCost == 0 {
+		// О цене не сообщили: сравниваем следующую с прежней
+		tracked.Estate.Cost = cost
+	}
+	tracked.Saved = time.Now()
+	if err := m.store.SaveTracked(tracked); err != nil {
+		log.Printf("Failed to save tracked estate %d: %v", estate.ID, err)
+	}
+	return sent
+}
+
+// diffEstate находит изменения объявления, о которых стоит уведомить,
+// с учетом порогов изменения цены из конфигурации
+func (m *Monitor) diffEstate(prev, cur *inpars.Estate) telegram.EstateChange {
+	var change telegram.EstateChange
+
+	if prev.Cost > 0 && cur.Cost > 0 && cur.Cost != prev.Cost {
+		percent := float64(cur.Cost-prev.Cost) / float64(prev.Cost) * 100
+		drop := cur.Cost < prev.Cost && -percent >= float64(m.config.PriceDropMinPercent)
+		rise := cur.Cost > prev.Cost && m.config.PriceRiseMinPercent > 0 && percent >= float64(m.config.PriceRiseMinPercent)
+		if drop || rise {
+			change.OldCost = prev.Cost
+			change.NewCost = cur.Cost
+		}
+	}
+
+	if len(cur.Phones) > 0 && !samePhones(prev.Phones, cur.Phones) {
+		change.PhonesChanged = true
+	}
+
+	// Повторная публикация: у объявления сменилась дата создания
+	if prev.Created != "" && cur.Created != "" && prev.Created != cur.Created {
+		change.Republished = true
+	}
+
+	return change
+}
+
+// samePhones сравнивает наборы телефонов без учета порядка
+func samePhones(a, b []int64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a = slices.Clone(a)
+	b = slices.Clone(b)
+	slices.Sort(a)
+	slices.Sort(b)
+	return slices.Equal(a, b)
+}
+
+// walkUpdated постранично запрашивает объявления, обновленные после params.TimeStart,
+// от самых свежих к более старым.
+//
+// Для следующей страницы в timeEnd подставляется дата обновления самого старого
+// объявления текущей страницы, поэтому граничные объявления могут повторяться.
+// Ошибка возвращается только если не удалось получить первую страницу
+func (m *Monitor) walkUpdated(ctx context.Context, params *inpars.EstateListParams, maxPages int, handle func(page []inpars.Estate)) (pageResult, error) {
+	var result pageResult
+	params.SortBy = "updated_desc"
+
+	for result.Pages < maxPages && ctx.Err() == nil {
+		resp, err := m.client.GetEstateListContext(ctx, params)
+		if err != nil {
+			if result.Pages == 0 {
+				return result, fmt.Errorf("failed to get updated estates: %w", err)
+			}
+			log.Printf("Update pagination interrupted on page %d: %v", result.Pages+1, err)
+			return result, nil
+		}
+		result.Pages++
+		result.Meta = resp.Meta
+
+		handle(resp.Data)
+
+		if len(resp.Data) < params.Limit || params.Limit == 0 {
+			result.Complete = true
+			return result, nil
+		}
+
+		var oldest int64
+		for _, estate := range resp.Data {
+			updated, err := estate.GetUpdatedTime()
+			if err != nil {
+				continue
+			}
+			if oldest == 0 || updated.Unix() < oldest {
+				oldest = updated.Unix()
+			}
+		}
+
+		// Вся страница обновлена в одну секунду - сдвинуть окно не получится
+		if oldest == 0 || oldest == params.TimeEnd {
+			return result, nil
+		}
+		params.TimeEnd = oldest
+	}
+
+	return result, nil
+}
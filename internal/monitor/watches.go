@@ -0,0 +1,117 @@
+package monitor
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/RedNessen/inpars-telegram-bot/internal/inpars"
+	"github.com/RedNessen/inpars-telegram-bot/internal/storage"
+	"github.com/RedNessen/inpars-telegram-bot/internal/telegram"
+)
+
+// checkWatches запрашивает объявления, за которыми следят чаты (/watch),
+// и уведомляет об их изменениях. Каждое объявление запрашивается один раз,
+// сколько бы чатов за ним ни следило
+func (m *Monitor) checkWatches(ctx context.Context) error {
+	watches, err := m.store.Watches()
+	if err != nil {
+		return err
+	}
+
+	byEstate := make(map[int][]*storage.WatchedEstate)
+	for _, watch := range watches {
+		if m.bot.IsChatActive(watch.ChatID) {
+			byEstate[watch.Estate.ID] = append(byEstate[watch.Estate.ID], watch)
+		}
+	}
+	if len(byEstate) == 0 {
+		return nil
+	}
+
+	ids := make([]int, 0, len(byEstate))
+	for id := range byEstate {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	log.Printf("Checking %d watched listings...", len(ids))
+
+	notified := 0
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		// DefaultExpand включает history: без нее карточка не покажет прежнюю цену
+		resp, err := m.client.GetEstateContext(ctx, id, inpars.DefaultExpand...)
+		switch {
+		case inpars.IsNotFound(err):
+			for _, watch := range byEstate[id] {
+				if !watch.Removed && m.notifyWatch(watch, &watch.Estate, telegram.EstateChange{Removed: true}) {
+					notified++
+				}
+			}
+		case err != nil:
+			log.Printf("Failed to get watched estate %d: %v", id, err)
+		default:
+			for _, watch := range byEstate[id] {
+				change := diffWatched(&watch.Estate, &resp.Data)
+				change.Restored = watch.Removed
+				if !change.IsEmpty() && m.notifyWatch(watch, &resp.Data, change) {
+					notified++
+				}
+			}
+		}
+	}
+
+	if notified > 0 {
+		log.Printf("Sent %d watch notifications", notified)
+	}
+	return nil
+}
+
+// notifyWatch отправляет уведомление об изменении и обновляет снимок объявления.
+// Если отправить не удалось, снимок не меняется и изменение придет при следующей проверке
+func (m *Monitor) notifyWatch(watch *storage.WatchedEstate, estate *inpars.Estate, change telegram.EstateChange) bool {
+	if err := m.bot.SendWatchUpdate(watch.ChatID, estate, change); err != nil {
+		log.Printf("Failed to send watch update for estate %d: %v", estate.ID, err)
+		return false
+	}
+
+	watch.Estate = *estate
+	watch.Removed = change.Removed
+	if err := m.store.SaveWatch(watch); err != nil {
+		log.Printf("Failed to save watched estate %d: %v", estate.ID, err)
+	}
+
+	// Задержка между отправками, чтобы избежать флуда
+	time.Sleep(500 * time.Millisecond)
+	return true
+}
+
+// diffWatched находит изменения отслеживаемого объявления. В отличие от
+// рассылки по подпискам, пороги цены не применяются: о любом изменении
+// объявления, за которым следят явно, стоит узнать
+func diffWatched(prev, cur *inpars.Estate) telegram.EstateChange {
+	var change telegram.EstateChange
+
+	if prev.Cost > 0 && cur.Cost > 0 && cur.Cost != prev.Cost {
+		change.OldCost = prev.Cost
+		change.NewCost = cur.Cost
+	}
+	if len(cur.Phones) > 0 && !samePhones(prev.Phones, cur.Phones) {
+		change.PhonesChanged = true
+	}
+	// Площадки меняют переносы строк и пробелы без правки текста
+	if strings.Join(strings.Fields(prev.Text), " ") != strings.Join(strings.Fields(cur.Text), " ") {
+		change.TextChanged = true
+	}
+	if prev.Created != "" && cur.Created != "" && prev.Created != cur.Created {
+		change.Republished = true
+	}
+
+	return change
+}
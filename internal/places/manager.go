@@ -0,0 +1,79 @@
+package places
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrTooManyPlaces у чата больше MaxPerChat точек
+var ErrTooManyPlaces = errors.New("too many places")
+
+// Store постоянное хранилище точек интереса
+type Store interface {
+	SavePlaces(places *ChatPlaces) error
+	Places() ([]*ChatPlaces, error)
+}
+
+// Manager хранит точки интереса всех чатов
+type Manager struct {
+	mu     sync.RWMutex
+	store  Store
+	byChat map[int64]*ChatPlaces
+}
+
+// NewManager создает менеджер и загружает сохраненные точки из хранилища
+func NewManager(store Store) (*Manager, error) {
+	m := &Manager{
+		store:  store,
+		byChat: make(map[int64]*ChatPlaces),
+	}
+
+	all, err := store.Places()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load places: %w", err)
+	}
+	for _, places := range all {
+		m.byChat[places.ChatID] = places
+	}
+	return m, nil
+}
+
+// ForChat возвращает копию точек и настроек чата
+func (m *Manager) ForChat(chatID int64) *ChatPlaces {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if places, ok := m.byChat[chatID]; ok {
+		return places.Clone()
+	}
+	return &ChatPlaces{ChatID: chatID}
+}
+
+// Update изменяет точки чата и сохраняет результат. Если update возвращает
+// ошибку, изменения не применяются
+func (m *Manager) Update(chatID int64, update func(places *ChatPlaces) error) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	places := &ChatPlaces{ChatID: chatID}
+	if current, ok := m.byChat[chatID]; ok {
+		places = current.Clone()
+	}
+	if err := update(places); err != nil {
+		return err
+	}
+	if len(places.Places) > MaxPerChat {
+		return ErrTooManyPlaces
+	}
+
+	if err := m.store.SavePlaces(places); err != nil {
+		return fmt.Errorf("failed to save places: %w", err)
+	}
+	if places.IsEmpty() {
+		delete(m.byChat, chatID)
+	} else {
+		m.byChat[chatID] = places
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+// Package places хранит точки интереса чатов (работа, дом родителей) и
+// оценивает расстояние и время в пути от объявления до них
+package places
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/RedNessen/inpars-telegram-bot/internal/geo"
+)
+
+// MaxPerChat ограничение количества точек у одного чата
+const MaxPerChat = 10
+
+// Коэффициенты оценки времени в пути без обращения к картографическим сервисам:
+// реальный маршрут длиннее прямой примерно в 1.3-1.4 раза
+const (
+	walkDetour    = 1.3
+	walkSpeed     = 5.0 / 3.6 // 5 км/ч в м/с
+	driveDetour   = 1.4
+	driveSpeed    = 25.0 / 3.6      // Средняя скорость в городе с учетом светофоров и пробок
+	driveOverhead = 3 * time.Minute // Выезд и парковка
+)
+
+// Place точка интереса чата
+type Place struct {
+	Name        string    `json:"name"`
+	Point       geo.Point `json:"point"`
+	MaxDistance float64   `json:"max_distance,omitempty"` // Присылать только объявления не дальше, м (0 - без ограничения)
+}
+
+// ChatPlaces точки интереса чата и связанные с ними настройки
+type ChatPlaces struct {
+	ChatID         int64   `json:"chat_id"`
+	Places         []Place `json:"places"`
+	SortByDistance bool    `json:"sort_by_distance"` // Отправлять объявления порции от ближних к дальним
+}
+
+// IsEmpty возвращает true, если у чата нет точек и настроек
+func (c *ChatPlaces) IsEmpty() bool {
+	return len(c.Places) == 0 && !c.SortByDistance
+}
+
+// Clone возвращает независимую копию
+func (c *ChatPlaces) Clone() *ChatPlaces {
+	clone := *c
+	clone.Places = append([]Place(nil), c.Places...)
+	return &clone
+}
+
+// Find возвращает индекс точки по имени или -1
+func (c *ChatPlaces) Find(name string) int {
+	for i, p := range c.Places {
+		if p.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Allows проверяет ограничения по расстоянию: если у точек чата задано
+// максимальное расстояние, объявление должно быть не дальше хотя бы от одной из них.
+// Объявления без координат такое ограничение не проходят
+func (c *ChatPlaces) Allows(p geo.Point) bool {
+	limited := false
+	for _, place := range c.Places {
+		if place.MaxDistance <= 0 {
+			continue
+		}
+		limited = true
+		if !p.IsZero() && geo.Distance(place.Point, p) <= place.MaxDistance {
+			return true
+		}
+	}
+	return !limited
+}
+
+// Distance расстояние от объявления до точки интереса
+type Distance struct {
+	Place  Place
+	Meters float64       // Расстояние по прямой
+	Walk   time.Duration // Оценка времени пешком
+	Drive  time.Duration // Оценка времени на машине
+}
+
+// Distances возвращает расстояния до всех точек чата в порядке их добавления
+func (c *ChatPlaces) Distances(p geo.Point) []Distance {
+	if p.IsZero() {
+		return nil
+	}
+	result := make([]Distance, 0, len(c.Places))
+	for _, place := range c.Places {
+		result = append(result, Estimate(place, p))
+	}
+	return result
+}
+
+// Nearest возвращает расстояние до ближайшей точки чата (+Inf, если точек
+// нет или у объявления нет координат)
+func (c *ChatPlaces) Nearest(p geo.Point) float64 {
+	nearest := math.Inf(1)
+	if p.IsZero() {
+		return nearest
+	}
+	for _, place := range c.Places {
+		nearest = math.Min(nearest, geo.Distance(place.Point, p))
+	}
+	return nearest
+}
+
+// Estimate оценивает расстояние и время в пути от точки p до места
+func Estimate(place Place, p geo.Point) Distance {
+	meters := geo.Distance(place.Point, p)
+	return Distance{
+		Place:  place,
+		Meters: meters,
+		Walk:   roundMinutes(meters * walkDetour / walkSpeed),
+		Drive:  roundMinutes(meters*driveDetour/driveSpeed) + driveOverhead,
+	}
+}
+
+func roundMinutes(seconds float64) time.Duration {
+	return time.Duration(math.Ceil(seconds/60)) * time.Minute
+}
+
+// SortByNearest упорядочивает элементы по расстоянию до ближайшей точки чата.
+// Элементы без координат остаются в конце в исходном порядке
+func SortByNearest[T any](c *ChatPlaces, items []T, point func(T) geo.Point) {
+	type keyed struct {
+		item     T
+		distance float64
+	}
+	sorted := make([]keyed, len(items))
+	for i, item := range items {
+		sorted[i] = keyed{item: item, distance: c.Nearest(point(item))}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].distance < sorted[j].distance })
+	for i := range sorted {
+		items[i] = sorted[i].item
+	}
+}
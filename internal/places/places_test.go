@@ -0,0 +1,120 @@
+package places
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/RedNessen/inpars-telegram-bot/internal/geo"
+)
+
+var (
+	office = Place{Name: "офис", Point: geo.Point{Lat: 55.7558, Lng: 37.6173}}
+	home   = Place{Name: "родители", Point: geo.Point{Lat: 55.8558, Lng: 37.6173}}
+)
+
+// north возвращает точку в meters метрах к северу от офиса
+func north(meters float64) geo.Point {
+	return geo.Point{Lat: office.Point.Lat + meters/111195, Lng: office.Point.Lng}
+}
+
+func TestEstimate(t *testing.T) {
+	tests := []struct {
+		meters float64
+		walk   time.Duration
+		drive  time.Duration
+	}{
+		// 1 км: пешком 1300 м со скоростью 5 км/ч, на машине 1400 м со скоростью 25 км/ч и 3 минуты на выезд
+		{1000, 16 * time.Minute, 7 * time.Minute},
+		{5000, 78 * time.Minute, 20 * time.Minute},
+		{0, 0, 3 * time.Minute},
+	}
+
+	for _, tt := range tests {
+		d := Estimate(office, north(tt.meters))
+		if math.Abs(d.Meters-tt.meters) > 1 {
+			t.Errorf("Estimate(%.0f m) meters = %.1f", tt.meters, d.Meters)
+		}
+		if d.Walk != tt.walk || d.Drive != tt.drive {
+			t.Errorf("Estimate(%.0f m) = walk %s, drive %s, want %s, %s", tt.meters, d.Walk, d.Drive, tt.walk, tt.drive)
+		}
+	}
+}
+
+func TestAllows(t *testing.T) {
+	limitedOffice := office
+	limitedOffice.MaxDistance = 2000
+
+	tests := []struct {
+		name   string
+		places []Place
+		p      geo.Point
+		want   bool
+	}{
+		{"нет точек", nil, north(50000), true},
+		{"точки без ограничения", []Place{office, home}, north(50000), true},
+		{"ближе ограничения", []Place{limitedOffice}, north(1500), true},
+		{"дальше ограничения", []Place{limitedOffice}, north(2500), false},
+		{"достаточно одной точки с ограничением", []Place{limitedOffice, home}, north(2500), false},
+		{"без координат при ограничении", []Place{limitedOffice}, geo.Point{}, false},
+		{"без координат без ограничения", []Place{office}, geo.Point{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &ChatPlaces{Places: tt.places}
+			if got := c.Allows(tt.p); got != tt.want {
+				t.Errorf("Allows = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDistancesAndNearest(t *testing.T) {
+	c := &ChatPlaces{Places: []Place{office, home}}
+
+	distances := c.Distances(north(1000))
+	if len(distances) != 2 || distances[0].Place.Name != "офис" || distances[1].Place.Name != "родители" {
+		t.Fatalf("Distances = %+v, want office and home in order", distances)
+	}
+	if c.Distances(geo.Point{}) != nil {
+		t.Errorf("Distances without coordinates is not empty")
+	}
+
+	// До родителей около 11,1 км, до офиса 10 км
+	if got := c.Nearest(north(10000)); math.Abs(got-1120) > 5 {
+		t.Errorf("Nearest = %.0f, want about 1120", got)
+	}
+	if !math.IsInf(c.Nearest(geo.Point{}), 1) {
+		t.Errorf("Nearest without coordinates is not +Inf")
+	}
+	if !math.IsInf((&ChatPlaces{}).Nearest(north(100)), 1) {
+		t.Errorf("Nearest without places is not +Inf")
+	}
+}
+
+func TestSortByNearest(t *testing.T) {
+	type item struct {
+		name  string
+		point geo.Point
+	}
+	items := []item{
+		{"без координат 1", geo.Point{}},
+		{"5 км", north(5000)},
+		{"у родителей", home.Point},
+		{"без координат 2", geo.Point{}},
+		{"1 км", north(1000)},
+	}
+
+	SortByNearest(&ChatPlaces{Places: []Place{office, home}}, items, func(i item) geo.Point { return i.point })
+
+	var got []string
+	for _, i := range items {
+		got = append(got, i.name)
+	}
+	want := []string{"у родителей", "1 км", "5 км", "без координат 1", "без координат 2"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("SortByNearest = %v, want %v", got, want)
+	}
+}
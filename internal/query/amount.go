@@ -0,0 +1,299 @@
+package query
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// ParseBudget разбирает бюджет: "60000", "30000-50000", "до 60 тыс", "от 5 до 8 млн".
+// Одно число - верхняя граница
+func ParseBudget(text string) (int, int, error) {
+	text = strings.ToLower(strings.TrimSpace(text))
+	text = strings.NewReplacer("₽", "", "руб.", "", "руб", "", "р.", "", "–", "-", "—", "-").Replace(text)
+
+	var minStr, maxStr string
+	switch {
+	case strings.HasPrefix(text, "от"):
+		minStr, maxStr, _ = strings.Cut(strings.TrimPrefix(text, "от"), "до")
+	case strings.HasPrefix(text, "до"):
+		maxStr = strings.TrimPrefix(text, "до")
+	case strings.Contains(text, "-"):
+		minStr, maxStr, _ = strings.Cut(text, "-")
+	default:
+		maxStr = text
+	}
+
+	min, minUnit, errMin := parseAmount(minStr)
+	max, maxUnit, errMax := parseAmount(maxStr)
+	if errMin != nil || errMax != nil || min == 0 && max == 0 {
+		return 0, 0, fmt.Errorf("Не удалось разобрать бюджет. Примеры: 60000, 30000-50000, до 60 тыс, от 5 до 8 млн")
+	}
+	// В «от 5 до 8 млн» множитель относится к обеим границам, но не в «от 5000000 до 8 млн»
+	if minUnit == 1 && (min <= max || min < 1000) {
+		minUnit = maxUnit
+	}
+	low, high := int(math.Round(min*minUnit)), int(math.Round(max*maxUnit))
+	if high > 0 && low > high {
+		return 0, 0, fmt.Errorf("Нижняя граница бюджета больше верхней")
+	}
+	return low, high, nil
+}
+
+// parseAmount разбирает сумму с необязательным множителем: "60000", "60 тыс", "60к", "5.5 млн"
+func parseAmount(text string) (value, unit float64, err error) {
+	text = strings.ReplaceAll(strings.ReplaceAll(text, " ", ""), ",", ".")
+	if text == "" {
+		return 0, 1, nil
+	}
+
+	unit = 1
+	for _, suffix := range []struct {
+		text  string
+		value float64
+	}{{"тыс.", 1e3}, {"тыс", 1e3}, {"млн", 1e6}, {"к", 1e3}, {"k", 1e3}, {"т", 1e3}, {"м", 1e6}} {
+		if strings.HasSuffix(text, suffix.text) {
+			text = strings.TrimSuffix(text, suffix.text)
+			unit = suffix.value
+			break
+		}
+	}
+
+	value, err = strconv.ParseFloat(text, 64)
+	if err != nil || value < 0 {
+		return 0, 0, fmt.Errorf("invalid amount %q", text)
+	}
+	return value, unit, nil
+}
+
+// amountKind к чему относится число фразы
+type amountKind int
+
+const (
+	kindNone  amountKind = iota // Единица не указана
+	kindMoney                   // Цена
+	kindArea                    // Площадь, м²
+	kindFloor                   // Этаж
+)
+
+// amount число фразы с множителем и единицей
+type amount struct {
+	value float64
+	unit  float64 // Множитель цены: 1, 1000, 1000000
+	kind  amountKind
+}
+
+// units единицы после числа. Слово, оканчивающееся на «*», задает начало слова
+var units = []struct {
+	pattern string
+	unit    float64
+	kind    amountKind
+}{
+	{"тыс", 1e3, kindMoney}, {"тысяч*", 1e3, kindMoney}, {"т", 1e3, kindMoney},
+	{"к", 1e3, kindMoney}, {"k", 1e3, kindMoney}, {"кк", 1e6, kindMoney},
+	{"млн", 1e6, kindMoney}, {"миллион*", 1e6, kindMoney}, {"лям*", 1e6, kindMoney},
+	{"р", 1, kindMoney}, {"руб*", 1, kindMoney}, {"₽", 1, kindMoney},
+	{"м2", 1, kindArea}, {"м²", 1, kindArea}, {"кв", 1, kindArea}, {"квм", 1, kindArea},
+	{"кв.м", 1, kindArea}, {"метр", 1, kindArea}, {"метра", 1, kindArea}, {"метров", 1, kindArea},
+	{"квадрат*", 1, kindArea},
+	{"этаж*", 1, kindFloor},
+}
+
+func unitOf(word string) (float64, amountKind, bool) {
+	for _, u := range units {
+		if wordMatches(word, u.pattern) {
+			return u.unit, u.kind, true
+		}
+	}
+	return 0, kindNone, false
+}
+
+// isCurrency возвращает true для «р», «руб», «рублей» и «₽» после множителя: «55 тыс. руб»
+func isCurrency(word string) bool {
+	unit, kind, ok := unitOf(word)
+	return ok && kind == kindMoney && unit == 1
+}
+
+// readAmount читает число, начинающееся со слова i: «55», «55 000», «5,5 млн»,
+// «55тыс», «55 тыс. руб», «40 м2». Возвращает количество прочитанных слов
+func readAmount(words []string, i int) (amount, int, bool) {
+	if i >= len(words) {
+		return amount{}, 0, false
+	}
+	numStr, suffix := cutNumber(words[i])
+	if numStr == "" {
+		return amount{}, 0, false
+	}
+	value, err := strconv.ParseFloat(strings.ReplaceAll(numStr, ",", "."), 64)
+	if err != nil {
+		return amount{}, 0, false
+	}
+	n := 1
+
+	// Разряды через пробел: «55 000», «1 500 000»
+	for suffix == "" && !strings.ContainsAny(numStr, ".,") && i+n < len(words) {
+		group := words[i+n]
+		if len(group) != 3 || strings.Trim(group, "0123456789") != "" {
+			break
+		}
+		next, _ := strconv.Atoi(group)
+		value = value*1000 + float64(next)
+		n++
+	}
+
+	a := amount{value: value, unit: 1}
+	if suffix != "" {
+		unit, kind, ok := unitOf(suffix)
+		if !ok {
+			return amount{}, 0, false
+		}
+		a.unit, a.kind = unit, kind
+	} else if i+n < len(words) {
+		if unit, kind, ok := unitOf(words[i+n]); ok {
+			a.unit, a.kind = unit, kind
+			n++
+		}
+	}
+	if a.kind == kindMoney && a.unit > 1 && i+n < len(words) && isCurrency(words[i+n]) {
+		n++
+	}
+	return a, n, true
+}
+
+// cutNumber отделяет число в начале слова от остатка: «55тыс» - «55» и «тыс»
+func cutNumber(word string) (string, string) {
+	end := 0
+	for end < len(word) {
+		c := word[end]
+		if c >= '0' && c <= '9' {
+			end++
+			continue
+		}
+		if (c == ',' || c == '.') && end > 0 && end+1 < len(word) && word[end+1] >= '0' && word[end+1] <= '9' {
+			end++
+			continue
+		}
+		break
+	}
+	return word[:end], word[end:]
+}
+
+// bound граница, которую задает слово перед числом
+type bound int
+
+const (
+	boundNone bound = iota
+	boundMax
+	boundMin
+)
+
+// leads слова перед числом
+var leads = []struct {
+	words []string
+	bound bound
+}{
+	{split("не дороже"), boundMax}, {split("не более"), boundMax}, {split("не больше"), boundMax},
+	{split("не выше"), boundMax}, {split("не дешевле"), boundMin}, {split("не менее"), boundMin},
+	{split("не меньше"), boundMin},
+	{split("до"), boundMax}, {split("за"), boundMax}, {split("дешевле"), boundMax},
+	{split("максимум"), boundMax}, {split("макс"), boundMax},
+	{split("от"), boundMin}, {split("дороже"), boundMin}, {split("минимум"), boundMin},
+	{split("больше"), boundMin}, {split("более"), boundMin},
+	{split("выше"), boundMin}, {split("ниже"), boundMax},
+}
+
+// matchRange распознает цену, площадь или этаж: «до 55 тыс», «от 30 до 50 тыс»,
+// «30-50 тыс», «от 40 м2», «выше 3 этажа», «40000»
+func matchRange(words []string, i int) (int, action) {
+	lead, n := boundNone, 0
+	for _, l := range leads {
+		if i+len(l.words) <= len(words) && slices.Equal(words[i:i+len(l.words)], l.words) {
+			lead, n = l.bound, len(l.words)
+			break
+		}
+	}
+
+	var low, high amount
+	switch lead {
+	case boundMax:
+		a, m, ok := readAmount(words, i+n)
+		if !ok {
+			return 0, nil
+		}
+		high, n = a, n+m
+	case boundMin:
+		a, m, ok := readAmount(words, i+n)
+		if !ok {
+			return 0, nil
+		}
+		low, n = a, n+m
+		if i+n < len(words) && words[i+n] == "до" {
+			if a, m, ok := readAmount(words, i+n+1); ok {
+				high, n = a, n+1+m
+			}
+		}
+	default:
+		// «30-50 тыс»: число до дефиса - нижняя граница, остаток читается как число
+		if numStr, rest := cutNumber(words[i]); numStr != "" && strings.HasPrefix(rest, "-") {
+			value, err := strconv.ParseFloat(strings.ReplaceAll(numStr, ",", "."), 64)
+			tail := append([]string{rest[1:]}, words[i+1:]...)
+			a, m, ok := readAmount(tail, 0)
+			if err != nil || !ok {
+				return 0, nil
+			}
+			low, high, n = amount{value: value, unit: 1}, a, m
+			break
+		}
+		a, m, ok := readAmount(words, i)
+		// Одиночное небольшое число без единицы - не цена: «5 минут»
+		if !ok || a.kind == kindNone && a.value < 1000 {
+			return 0, nil
+		}
+		switch a.kind {
+		case kindArea:
+			low = a
+		case kindFloor:
+			low, high = a, a
+		default:
+			high = a
+		}
+		n = m
+	}
+
+	// В «от 30 до 50 тыс» и «от 8 до 5 млн» множитель и единица относятся
+	// к обеим границам, но не в «от 30000 до 50 тыс»
+	if low.value > 0 && high.value > 0 && low.kind == kindNone && (low.value <= high.value || low.value < 1000) {
+		low.unit, low.kind = high.unit, high.kind
+	}
+
+	kind := high.kind
+	if kind == kindNone {
+		kind = low.kind
+	}
+	return n, func(p *parser) error {
+		switch kind {
+		case kindArea:
+			p.q.Params.SqMin, p.q.Params.SqMax = low.value, high.value
+			return nil
+		case kindFloor:
+			p.q.Params.FloorMin, p.q.Params.FloorMax = int(low.value), int(high.value)
+			return nil
+		}
+		p.q.Params.CostMin, p.q.Params.CostMax = low.money(), high.money()
+		if p.q.Params.CostMax > 0 && p.q.Params.CostMin > p.q.Params.CostMax {
+			return fmt.Errorf("Нижняя граница цены больше верхней")
+		}
+		return nil
+	}
+}
+
+// money возвращает сумму в рублях. Число меньше 1000 без множителя
+// считается в тысячах: «до 55» - до 55 000 ₽
+func (a amount) money() int {
+	if a.unit == 1 && a.kind == kindNone && a.value < 1000 {
+		return int(math.Round(a.value * 1000))
+	}
+	return int(math.Round(a.value * a.unit))
+}
@@ -0,0 +1,324 @@
+// Package query разбирает поисковые фразы на русском языке вида
+// «двушка у метро Тульская до 55 тыс без комиссии от собственника»
+// в параметры запроса к API InPars и фильтры подписки
+package query
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/RedNessen/inpars-telegram-bot/internal/inpars"
+	"github.com/RedNessen/inpars-telegram-bot/internal/subscription"
+	"github.com/RedNessen/inpars-telegram-bot/internal/textmatch"
+)
+
+// Query фильтры, разобранные из фразы
+type Query struct {
+	Params   inpars.EstateListParams // Тип объявления, продавец, цена, площадь, этаж и метро
+	Filters  subscription.Filters    // Комнаты, срок аренды, комиссия, залог и апартаменты
+	Stations []string                // Станции метро в том виде, в котором они названы во фразе
+}
+
+// MetroFinder находит ID станций метро по названию или его началу.
+// Пустой результат без ошибки означает, что станция не найдена
+type MetroFinder func(ctx context.Context, name string) ([]int, error)
+
+// Parse разбирает фразу. Распознаются:
+//
+//	комнаты:   студия, однушка, двухкомнатная, 2к, 2-х комн, 1-2к
+//	цена:      до 55 тыс, от 30 до 50 тыс, 40000, за 5.5 млн, 30-50к
+//	площадь:   от 40 м2, 30-45 кв.м
+//	этаж:      не первый этаж, выше 3 этажа, от 2 до 9 этажа
+//	метро:     метро Тульская, у м. Сокол, метро Сокол или Аэропорт
+//	продавец:  от собственника, без посредников, от агента, от застройщика
+//	сделка:    снять, аренда, посуточно, надолго, купить
+//	прочее:    без комиссии, без залога, без апартаментов
+//
+// Сумма меньше 1000 без множителя считается в тысячах: «до 55» - до 55 000 ₽.
+// Слова, не распознанные ни одним правилом, ищутся среди станций метро
+// («двушка Бутово»), остальные возвращаются ошибкой. Если findMetro равен nil,
+// станции только перечисляются в Stations
+func Parse(ctx context.Context, text string, findMetro MetroFinder) (*Query, error) {
+	p := &parser{ctx: ctx, words: tokenize(text), findMetro: findMetro, q: &Query{}}
+	if err := p.parse(); err != nil {
+		return nil, err
+	}
+	return p.q, nil
+}
+
+// Apply переносит разобранные фильтры в подписку. Фильтры, которые во фразе
+// не упоминаются, остаются как есть. Сделка задает и срок аренды:
+// «снять» снимает ограничение «посуточно» исходной подписки
+func (q *Query) Apply(sub *subscription.Subscription) {
+	params := &q.Params
+	if len(params.TypeAd) > 0 {
+		sub.TypeAd = append([]int(nil), params.TypeAd...)
+		sub.Filters.RentTime = q.Filters.RentTime
+	}
+	if len(params.SellerType) > 0 {
+		sub.SellerTypes = append([]int(nil), params.SellerType...)
+	}
+	if len(params.MetroID) > 0 {
+		sub.MetroIDs = append([]int(nil), params.MetroID...)
+	}
+	if params.CostMin > 0 || params.CostMax > 0 {
+		sub.CostMin, sub.CostMax = params.CostMin, params.CostMax
+	}
+	if params.SqMin > 0 || params.SqMax > 0 {
+		sub.SqMin, sub.SqMax = params.SqMin, params.SqMax
+	}
+	if params.FloorMin > 0 || params.FloorMax > 0 {
+		sub.FloorMin, sub.FloorMax = params.FloorMin, params.FloorMax
+	}
+
+	filters := q.Filters.Clone()
+	if len(filters.Rooms) > 0 {
+		sub.Filters.Rooms = filters.Rooms
+	}
+	if filters.CommissionMax != nil {
+		sub.Filters.CommissionMax = filters.CommissionMax
+	}
+	if filters.DepositMax != nil {
+		sub.Filters.DepositMax = filters.DepositMax
+	}
+	if filters.Apartments != nil {
+		sub.Filters.Apartments = filters.Apartments
+	}
+}
+
+// parser состояние разбора фразы
+type parser struct {
+	ctx       context.Context
+	words     []string
+	findMetro MetroFinder
+	q         *Query
+	unknown   []string // Подряд идущие нераспознанные слова
+}
+
+// action применяет распознанную конструкцию к запросу
+type action func(p *parser) error
+
+func (p *parser) parse() error {
+	for i := 0; i < len(p.words); {
+		n, apply := p.match(i)
+		if n == 0 {
+			p.unknown = append(p.unknown, p.words[i])
+			i++
+			continue
+		}
+
+		if err := p.flushUnknown(); err != nil {
+			return err
+		}
+		if apply != nil {
+			if err := apply(p); err != nil {
+				return err
+			}
+		}
+		i += n
+	}
+	return p.flushUnknown()
+}
+
+// match распознает конструкцию, которая начинается со слова i. Возвращает
+// количество ее слов (0 - не распознана) и действие; у служебных слов действия нет
+func (p *parser) match(i int) (int, action) {
+	if n, apply := matchPhrase(p.words, i); n > 0 {
+		return n, apply
+	}
+	if n, rooms := matchRooms(p.words, i); n > 0 {
+		return n, func(p *parser) error {
+			p.q.Filters.Rooms = appendUnique(p.q.Filters.Rooms, rooms...)
+			return nil
+		}
+	}
+	if n, apply := matchRange(p.words, i); n > 0 {
+		return n, apply
+	}
+	if n, stations := p.matchMetro(i); n > 0 {
+		return n, func(p *parser) error {
+			for _, name := range stations {
+				if err := p.addStation(name, true); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+	}
+	if isFiller(p.words, i) {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// matchMetro распознает «метро НАЗВАНИЕ [, НАЗВАНИЕ | или НАЗВАНИЕ]».
+// Название продолжается до следующей распознанной конструкции
+func (p *parser) matchMetro(i int) (int, []string) {
+	if !metroWords[p.words[i]] {
+		return 0, nil
+	}
+
+	var stations, name []string
+	j := i + 1
+	for ; j < len(p.words); j++ {
+		word := p.words[j]
+		if separators[word] && len(name) > 0 && j+1 < len(p.words) {
+			if n, _ := p.match(j + 1); n == 0 {
+				stations = append(stations, strings.Join(name, " "))
+				name = nil
+				continue
+			}
+		}
+		if n, _ := p.match(j); n > 0 {
+			break
+		}
+		name = append(name, word)
+	}
+	if len(name) > 0 {
+		stations = append(stations, strings.Join(name, " "))
+	}
+	if len(stations) == 0 {
+		return 0, nil
+	}
+	return j - i, stations
+}
+
+// flushUnknown ищет нераспознанные слова среди станций метро
+func (p *parser) flushUnknown() error {
+	if len(p.unknown) == 0 {
+		return nil
+	}
+	name := strings.Join(p.unknown, " ")
+	p.unknown = nil
+
+	// Короткие слова совпадают с началом слишком многих станций
+	if p.findMetro != nil && utf8.RuneCountInString(name) >= 3 {
+		if err := p.addStation(name, false); err == nil {
+			return nil
+		} else if !isNotFound(err) {
+			return err
+		}
+	}
+	return fmt.Errorf("Не понял «%s»", name)
+}
+
+// stationNotFoundError станция метро не найдена
+type stationNotFoundError struct {
+	name string
+}
+
+func (e *stationNotFoundError) Error() string {
+	return fmt.Sprintf("Станция метро «%s» не найдена", e.name)
+}
+
+func isNotFound(err error) bool {
+	_, ok := err.(*stationNotFoundError)
+	return ok
+}
+
+// addStation находит станцию по названию и добавляет ее в запрос. Если
+// название не найдено, оно ищется еще раз без окончаний: «на Соколе», «у Тульской».
+// explicit - станция названа после слова «метро» и попадает в Stations без поиска
+func (p *parser) addStation(name string, explicit bool) error {
+	if p.findMetro == nil {
+		if explicit {
+			p.q.Stations = append(p.q.Stations, name)
+		}
+		return nil
+	}
+
+	ids, err := p.findMetro(p.ctx, name)
+	if err == nil && len(ids) == 0 {
+		if stem := stemName(name); stem != name {
+			ids, err = p.findMetro(p.ctx, stem)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if len(ids) == 0 {
+		return &stationNotFoundError{name: name}
+	}
+
+	p.q.Stations = append(p.q.Stations, name)
+	p.q.Params.MetroID = appendUnique(p.q.Params.MetroID, ids...)
+	return nil
+}
+
+// stemName отбрасывает окончания слов названия
+func stemName(name string) string {
+	words := strings.Fields(name)
+	for i, word := range words {
+		words[i] = textmatch.Stem(word)
+	}
+	return strings.Join(words, " ")
+}
+
+// tokenize приводит фразу к нижнему регистру и разбивает на слова. Запятая,
+// точка с запятой и косая черта становятся отдельным словом «,», если это
+// не десятичный разделитель; точка в конце слова отбрасывается («тыс.», «м.»)
+func tokenize(text string) []string {
+	text = strings.NewReplacer("–", "-", "—", "-", "«", " ", "»", " ", "\"", " ").Replace(textmatch.Fold(text))
+	runes := []rune(text)
+
+	var words []string
+	var word []rune
+	flush := func() {
+		w := strings.TrimRight(string(word), ".")
+		word = word[:0]
+		// «м.сокол» - сокращение «метро» без пробела
+		if rest, ok := strings.CutPrefix(w, "м."); ok && rest != "" && !strings.HasPrefix(rest, "кв") {
+			words = append(words, "м")
+			w = rest
+		}
+		if w != "" {
+			words = append(words, w)
+		}
+	}
+
+	for i, r := range runes {
+		switch {
+		case unicode.IsSpace(r), r == '!', r == '?', r == '(', r == ')':
+			flush()
+		case r == ',' || r == ';' || r == '/':
+			decimal := r == ',' && i > 0 && i+1 < len(runes) && unicode.IsDigit(runes[i-1]) && unicode.IsDigit(runes[i+1])
+			if decimal {
+				word = append(word, r)
+				continue
+			}
+			flush()
+			words = append(words, ",")
+		default:
+			word = append(word, r)
+		}
+	}
+	flush()
+	return words
+}
+
+func appendUnique(values []int, add ...int) []int {
+	for _, v := range add {
+		found := false
+		for _, existing := range values {
+			if existing == v {
+				found = true
+				break
+			}
+		}
+		if !found {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+func intPtr(v int) *int {
+	return &v
+}
+
+func boolPtr(v bool) *bool {
+	return &v
+}
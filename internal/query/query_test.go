@@ -0,0 +1,289 @@
+package query
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/RedNessen/inpars-telegram-bot/internal/inpars"
+	"github.com/RedNessen/inpars-telegram-bot/internal/subscription"
+)
+
+// testStations станции для поиска метро в тестах
+var testStations = map[string]int{
+	"тульская":      1,
+	"сокол":         2,
+	"аэропорт":      3,
+	"бутово":        4,
+	"парк культуры": 5,
+	"речной вокзал": 6,
+}
+
+// fakeMetro находит станции по точному названию или его началу, как GET /metro
+func fakeMetro(_ context.Context, name string) ([]int, error) {
+	if id, ok := testStations[name]; ok {
+		return []int{id}, nil
+	}
+	var ids []int
+	for station, id := range testStations {
+		if strings.HasPrefix(station, name) {
+			ids = append(ids, id)
+		}
+	}
+	return ids, nil
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		text string
+		want Query
+	}{
+		// Комнаты
+		{"студия", Query{Filters: subscription.Filters{Rooms: []int{0}}}},
+		{"однушка", Query{Filters: subscription.Filters{Rooms: []int{1}}}},
+		{"двушка", Query{Filters: subscription.Filters{Rooms: []int{2}}}},
+		{"трешка", Query{Filters: subscription.Filters{Rooms: []int{3}}}},
+		{"двухкомнатная", Query{Filters: subscription.Filters{Rooms: []int{2}}}},
+		{"двух комнатная", Query{Filters: subscription.Filters{Rooms: []int{2}}}},
+		{"2к", Query{Filters: subscription.Filters{Rooms: []int{2}}}},
+		{"2 к", Query{Filters: subscription.Filters{Rooms: []int{2}}}},
+		{"2-х комн", Query{Filters: subscription.Filters{Rooms: []int{2}}}},
+		{"2х комнатная", Query{Filters: subscription.Filters{Rooms: []int{2}}}},
+		{"1-2к", Query{Filters: subscription.Filters{Rooms: []int{1, 2}}}},
+		{"1,2 комнатные", Query{Filters: subscription.Filters{Rooms: []int{1, 2}}}},
+		{"студия или однушка", Query{Filters: subscription.Filters{Rooms: []int{0, 1}}}},
+
+		// Цена
+		{"до 55 тыс", Query{Params: inpars.EstateListParams{CostMax: 55000}}},
+		{"до 55 тыс. руб", Query{Params: inpars.EstateListParams{CostMax: 55000}}},
+		{"до 55тыс", Query{Params: inpars.EstateListParams{CostMax: 55000}}},
+		{"до 55", Query{Params: inpars.EstateListParams{CostMax: 55000}}},
+		{"до 55 000", Query{Params: inpars.EstateListParams{CostMax: 55000}}},
+		{"до 55000 р", Query{Params: inpars.EstateListParams{CostMax: 55000}}},
+		{"40000", Query{Params: inpars.EstateListParams{CostMax: 40000}}},
+		{"от 30 до 50 тыс", Query{Params: inpars.EstateListParams{CostMin: 30000, CostMax: 50000}}},
+		{"от 30000 до 50 тыс", Query{Params: inpars.EstateListParams{CostMin: 30000, CostMax: 50000}}},
+		{"от 30 тыс", Query{Params: inpars.EstateListParams{CostMin: 30000}}},
+		{"за 5.5 млн", Query{Params: inpars.EstateListParams{CostMax: 5500000}}},
+		{"за 5,5 млн", Query{Params: inpars.EstateListParams{CostMax: 5500000}}},
+		{"от 5 до 8 млн", Query{Params: inpars.EstateListParams{CostMin: 5000000, CostMax: 8000000}}},
+		{"30-50к", Query{Params: inpars.EstateListParams{CostMin: 30000, CostMax: 50000}}},
+		{"30-50 тыс", Query{Params: inpars.EstateListParams{CostMin: 30000, CostMax: 50000}}},
+		{"не дороже 60к", Query{Params: inpars.EstateListParams{CostMax: 60000}}},
+		{"дешевле 3 млн", Query{Params: inpars.EstateListParams{CostMax: 3000000}}},
+
+		// Площадь
+		{"от 40 м2", Query{Params: inpars.EstateListParams{SqMin: 40}}},
+		{"30-45 кв.м", Query{Params: inpars.EstateListParams{SqMin: 30, SqMax: 45}}},
+		{"от 30 до 45 метров", Query{Params: inpars.EstateListParams{SqMin: 30, SqMax: 45}}},
+		{"40 квадратов", Query{Params: inpars.EstateListParams{SqMin: 40}}},
+
+		// Этаж
+		{"не первый этаж", Query{Params: inpars.EstateListParams{FloorMin: 2}}},
+		{"не первый", Query{Params: inpars.EstateListParams{FloorMin: 2}}},
+		{"выше 3 этажа", Query{Params: inpars.EstateListParams{FloorMin: 3}}},
+		{"от 2 этажа", Query{Params: inpars.EstateListParams{FloorMin: 2}}},
+		{"от 2 до 9 этажа", Query{Params: inpars.EstateListParams{FloorMin: 2, FloorMax: 9}}},
+
+		// Метро
+		{"метро Тульская", Query{Params: inpars.EstateListParams{MetroID: []int{1}}, Stations: []string{"тульская"}}},
+		{"у м. Сокол", Query{Params: inpars.EstateListParams{MetroID: []int{2}}, Stations: []string{"сокол"}}},
+		{"м.сокол", Query{Params: inpars.EstateListParams{MetroID: []int{2}}, Stations: []string{"сокол"}}},
+		{"метро Сокол или Аэропорт", Query{Params: inpars.EstateListParams{MetroID: []int{2, 3}}, Stations: []string{"сокол", "аэропорт"}}},
+		{"метро Сокол, Аэропорт", Query{Params: inpars.EstateListParams{MetroID: []int{2, 3}}, Stations: []string{"сокол", "аэропорт"}}},
+		{"метро парк культуры", Query{Params: inpars.EstateListParams{MetroID: []int{5}}, Stations: []string{"парк культуры"}}},
+		{"на Соколе", Query{Params: inpars.EstateListParams{MetroID: []int{2}}, Stations: []string{"соколе"}}},
+		{"у Тульской", Query{Params: inpars.EstateListParams{MetroID: []int{1}}, Stations: []string{"тульской"}}},
+		{"речной", Query{Params: inpars.EstateListParams{MetroID: []int{6}}, Stations: []string{"речной"}}},
+		{"1к Бутово 40000", Query{
+			Params:   inpars.EstateListParams{CostMax: 40000, MetroID: []int{4}},
+			Filters:  subscription.Filters{Rooms: []int{1}},
+			Stations: []string{"бутово"},
+		}},
+
+		// Продавец
+		{"от собственника", Query{Params: inpars.EstateListParams{SellerType: []int{1}}}},
+		{"без посредников", Query{Params: inpars.EstateListParams{SellerType: []int{1}}}},
+		{"от хозяина", Query{Params: inpars.EstateListParams{SellerType: []int{1}}}},
+		{"от агента", Query{Params: inpars.EstateListParams{SellerType: []int{2}}}},
+		{"от застройщика", Query{Params: inpars.EstateListParams{SellerType: []int{3}}}},
+		{"собственник или агент", Query{Params: inpars.EstateListParams{SellerType: []int{1, 2}}}},
+
+		// Сделка
+		{"снять", Query{Params: inpars.EstateListParams{TypeAd: []int{1}}}},
+		{"аренда", Query{Params: inpars.EstateListParams{TypeAd: []int{1}}}},
+		{"посуточно", Query{Params: inpars.EstateListParams{TypeAd: []int{1}}, Filters: subscription.Filters{RentTime: 2}}},
+		{"надолго", Query{Params: inpars.EstateListParams{TypeAd: []int{1}}, Filters: subscription.Filters{RentTime: 1}}},
+		{"на длительный срок", Query{Params: inpars.EstateListParams{TypeAd: []int{1}}, Filters: subscription.Filters{RentTime: 1}}},
+		{"снять посуточно", Query{Params: inpars.EstateListParams{TypeAd: []int{1}}, Filters: subscription.Filters{RentTime: 2}}},
+		{"посуточно снять", Query{Params: inpars.EstateListParams{TypeAd: []int{1}}, Filters: subscription.Filters{RentTime: 2}}},
+		{"купить", Query{Params: inpars.EstateListParams{TypeAd: []int{2}}}},
+
+		// Прочее
+		{"без комиссии", Query{Filters: subscription.Filters{CommissionMax: intPtr(0)}}},
+		{"без залога", Query{Filters: subscription.Filters{DepositMax: intPtr(0)}}},
+		{"без депозита", Query{Filters: subscription.Filters{DepositMax: intPtr(0)}}},
+		{"без апартаментов", Query{Filters: subscription.Filters{Apartments: boolPtr(false)}}},
+		{"апартаменты", Query{Filters: subscription.Filters{Apartments: boolPtr(true)}}},
+
+		// Служебные слова и полные фразы
+		{"", Query{}},
+		{"ищу квартиру", Query{}},
+		{"двушка у метро Тульская до 55 тыс без комиссии от собственника", Query{
+			Params:   inpars.EstateListParams{CostMax: 55000, MetroID: []int{1}, SellerType: []int{1}},
+			Filters:  subscription.Filters{Rooms: []int{2}, CommissionMax: intPtr(0)},
+			Stations: []string{"тульская"},
+		}},
+		{"Снять студию на Соколе от 30 до 45 тыс, не первый этаж", Query{
+			Params:   inpars.EstateListParams{TypeAd: []int{1}, CostMin: 30000, CostMax: 45000, FloorMin: 2, MetroID: []int{2}},
+			Filters:  subscription.Filters{Rooms: []int{0}},
+			Stations: []string{"соколе"},
+		}},
+		{"1-2к м. Тульская посуточно", Query{
+			Params:   inpars.EstateListParams{TypeAd: []int{1}, MetroID: []int{1}},
+			Filters:  subscription.Filters{Rooms: []int{1, 2}, RentTime: 2},
+			Stations: []string{"тульская"},
+		}},
+		{"купить трешку от 60 м2 за 12 млн", Query{
+			Params:  inpars.EstateListParams{TypeAd: []int{2}, CostMax: 12000000, SqMin: 60},
+			Filters: subscription.Filters{Rooms: []int{3}},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			got, err := Parse(context.Background(), tt.text, fakeMetro)
+			if err != nil {
+				t.Fatalf("Parse(%q) error: %v", tt.text, err)
+			}
+			if !reflect.DeepEqual(*got, tt.want) {
+				t.Errorf("Parse(%q) =\n%+v\nwant\n%+v", tt.text, *got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{"двушка абырвалг", "Не понял «абырвалг»"},
+		{"двушка ы", "Не понял «ы»"},
+		{"от 50 до 30 тыс", "Нижняя граница цены больше верхней"},
+		{"от 50000 до 30000", "Нижняя граница цены больше верхней"},
+		{"от 8 до 5 млн", "Нижняя граница цены больше верхней"},
+		{"метро Абырвалг", "Станция метро «абырвалг» не найдена"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			_, err := Parse(context.Background(), tt.text, fakeMetro)
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("Parse(%q) error = %v, want %q", tt.text, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseMetroFinder(t *testing.T) {
+	// Без поиска станции только перечисляются, а неизвестные слова - ошибка
+	got, err := Parse(context.Background(), "метро Сокол до 60 тыс", nil)
+	if err != nil {
+		t.Fatalf("Parse without finder: %v", err)
+	}
+	if !reflect.DeepEqual(got.Stations, []string{"сокол"}) || got.Params.MetroID != nil || got.Params.CostMax != 60000 {
+		t.Errorf("Parse without finder = %+v", *got)
+	}
+	if _, err := Parse(context.Background(), "двушка Бутово", nil); err == nil || err.Error() != "Не понял «бутово»" {
+		t.Errorf("Parse without finder error = %v", err)
+	}
+
+	// Ошибка поиска возвращается как есть
+	errAPI := errors.New("api unavailable")
+	failing := func(context.Context, string) ([]int, error) { return nil, errAPI }
+	if _, err := Parse(context.Background(), "метро Сокол", failing); !errors.Is(err, errAPI) {
+		t.Errorf("Parse with failing finder error = %v, want %v", err, errAPI)
+	}
+}
+
+func TestApply(t *testing.T) {
+	q, err := Parse(context.Background(), "снять двушку до 55 тыс", fakeMetro)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sub := &subscription.Subscription{
+		RegionIDs:   []int{77},
+		TypeAd:      []int{1},
+		SellerTypes: []int{1},
+		CostMin:     20000,
+		CostMax:     40000,
+		SqMin:       30,
+	}
+	sub.Filters.RentTime = 2
+	q.Apply(sub)
+
+	switch {
+	case !reflect.DeepEqual(sub.RegionIDs, []int{77}) || !reflect.DeepEqual(sub.SellerTypes, []int{1}):
+		t.Errorf("Apply changed fields the phrase does not set: %+v", sub)
+	case sub.CostMin != 0 || sub.CostMax != 55000:
+		t.Errorf("Apply cost = %d-%d, want 0-55000", sub.CostMin, sub.CostMax)
+	case sub.SqMin != 30:
+		t.Errorf("Apply sq min = %v, want 30", sub.SqMin)
+	case sub.Filters.RentTime != 0:
+		t.Errorf("Apply rent time = %d, want 0: «снять» drops «посуточно»", sub.Filters.RentTime)
+	case !reflect.DeepEqual(sub.Filters.Rooms, []int{2}):
+		t.Errorf("Apply rooms = %v, want [2]", sub.Filters.Rooms)
+	}
+}
+
+func TestParseBudget(t *testing.T) {
+	tests := []struct {
+		text          string
+		wantMin, want int
+	}{
+		{"60000", 0, 60000},
+		{"60 000", 0, 60000},
+		{"60к", 0, 60000},
+		{"60 тыс", 0, 60000},
+		{"60 тыс.", 0, 60000},
+		{"60000 ₽", 0, 60000},
+		{"60000 руб.", 0, 60000},
+		{"30000-50000", 30000, 50000},
+		{"30000 – 50000", 30000, 50000},
+		{"30-50к", 30000, 50000},
+		{"до 60 тыс", 0, 60000},
+		{"До 60000", 0, 60000},
+		{"от 40000", 40000, 0},
+		{"-5000", 0, 5000},
+		{"от 5 до 8 млн", 5000000, 8000000},
+		{"от 5000000 до 8 млн", 5000000, 8000000},
+		{"5.5 млн", 0, 5500000},
+		{"5,5 млн", 0, 5500000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.text, func(t *testing.T) {
+			low, high, err := ParseBudget(tt.text)
+			if err != nil {
+				t.Fatalf("ParseBudget(%q) error: %v", tt.text, err)
+			}
+			if low != tt.wantMin || high != tt.want {
+				t.Errorf("ParseBudget(%q) = %d, %d, want %d, %d", tt.text, low, high, tt.wantMin, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseBudgetErrors(t *testing.T) {
+	for _, text := range []string{"", "много", "до", "-", "50000-30000", "от 8 до 5 млн"} {
+		t.Run(text, func(t *testing.T) {
+			if low, high, err := ParseBudget(text); err == nil {
+				t.Errorf("ParseBudget(%q) = %d, %d, want error", text, low, high)
+			}
+		})
+	}
+}
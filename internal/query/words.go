@@ -0,0 +1,242 @@
+package query
+
+import (
+	"strconv"
+	"strings"
+)
+
+// phrase устойчивое сочетание слов. Слово, оканчивающееся на «*»,
+// задает начало слова («комисс*» - «комиссии», «комиссией»)
+type phrase struct {
+	words []string
+	apply action
+}
+
+// Продавец: 1-собственник, 2-агент, 3-застройщик
+func seller(sellerType int) action {
+	return func(p *parser) error {
+		p.q.Params.SellerType = appendUnique(p.q.Params.SellerType, sellerType)
+		return nil
+	}
+}
+
+// deal задает тип объявления и срок аренды (0 - любой, 1 - длительно, 2 - посуточно)
+func deal(typeAd, rentTime int) action {
+	return func(p *parser) error {
+		p.q.Params.TypeAd = []int{typeAd}
+		p.q.Filters.RentTime = rentTime
+		return nil
+	}
+}
+
+// rent ищет аренду, не меняя уже заданный срок: «снять посуточно»
+func rent(p *parser) error {
+	if len(p.q.Params.TypeAd) == 0 || p.q.Params.TypeAd[0] != 1 {
+		p.q.Params.TypeAd = []int{1}
+		p.q.Filters.RentTime = 0
+	}
+	return nil
+}
+
+// phrases словарь сочетаний. Более длинные сочетания стоят раньше коротких
+var phrases = []phrase{
+	{split("без комисс*"), func(p *parser) error { p.q.Filters.CommissionMax = intPtr(0); return nil }},
+	{split("без залог*"), func(p *parser) error { p.q.Filters.DepositMax = intPtr(0); return nil }},
+	{split("без депозит*"), func(p *parser) error { p.q.Filters.DepositMax = intPtr(0); return nil }},
+	{split("без апартамент*"), func(p *parser) error { p.q.Filters.Apartments = boolPtr(false); return nil }},
+	{split("не апартамент*"), func(p *parser) error { p.q.Filters.Apartments = boolPtr(false); return nil }},
+	{split("апартамент*"), func(p *parser) error { p.q.Filters.Apartments = boolPtr(true); return nil }},
+	{split("не первый этаж*"), func(p *parser) error { p.q.Params.FloorMin = 2; return nil }},
+	{split("не первый"), func(p *parser) error { p.q.Params.FloorMin = 2; return nil }},
+	{split("не 1 этаж*"), func(p *parser) error { p.q.Params.FloorMin = 2; return nil }},
+
+	{split("без посредник*"), seller(1)},
+	{split("без агент*"), seller(1)},
+	{split("без риелтор*"), seller(1)},
+	{split("без риэлтор*"), seller(1)},
+	{split("от собственник*"), seller(1)},
+	{split("от хозя*"), seller(1)},
+	{split("собственник*"), seller(1)},
+	{split("хозя*"), seller(1)},
+	{split("от агент*"), seller(2)},
+	{split("агент*"), seller(2)},
+	{split("риелтор*"), seller(2)},
+	{split("риэлтор*"), seller(2)},
+	{split("от застройщик*"), seller(3)},
+	{split("застройщик*"), seller(3)},
+
+	{split("на длительный срок"), deal(1, 1)},
+	{split("на долгий срок"), deal(1, 1)},
+	{split("длительн*"), deal(1, 1)},
+	{split("долгосрочн*"), deal(1, 1)},
+	{split("надолго"), deal(1, 1)},
+	{split("на сутки"), deal(1, 2)},
+	{split("посуточн*"), deal(1, 2)},
+	{split("суточн*"), deal(1, 2)},
+	{split("снять"), rent},
+	{split("сниму"), rent},
+	{split("арендовать"), rent},
+	{split("аренд*"), rent},
+	{split("купить"), deal(2, 0)},
+	{split("куплю"), deal(2, 0)},
+	{split("покупк*"), deal(2, 0)},
+	{split("продаж*"), deal(2, 0)},
+}
+
+// fillers слова, которые не влияют на запрос
+var fillers = split("квартир* кв жилье хочу ищу ищем нужн* надо в на у около возле рядом " +
+	"недалеко близко с со и или от до за недорого срочно желательно пожалуйста ,")
+
+// metroWords слова, после которых идет название станции
+var metroWords = map[string]bool{"метро": true, "м": true, "ст.м": true, "станция": true, "станции": true}
+
+// separators разделяют названия нескольких станций
+var separators = map[string]bool{",": true, "и": true, "или": true}
+
+func split(text string) []string {
+	return strings.Fields(text)
+}
+
+// wordMatches сравнивает слово с образцом словаря
+func wordMatches(word, pattern string) bool {
+	if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+		return strings.HasPrefix(word, prefix)
+	}
+	return word == pattern
+}
+
+// matchPhrase ищет сочетание словаря, начинающееся со слова i
+func matchPhrase(words []string, i int) (int, action) {
+	for _, ph := range phrases {
+		if i+len(ph.words) > len(words) {
+			continue
+		}
+		ok := true
+		for j, pattern := range ph.words {
+			if !wordMatches(words[i+j], pattern) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return len(ph.words), ph.apply
+		}
+	}
+	return 0, nil
+}
+
+func isFiller(words []string, i int) bool {
+	for _, pattern := range fillers {
+		if wordMatches(words[i], pattern) {
+			return true
+		}
+	}
+	return false
+}
+
+// numerals начала слов с числом комнат: «двухкомнатная», «двух комнатная»
+var numerals = []struct {
+	prefix string
+	rooms  int
+}{
+	{"одно", 1}, {"двух", 2}, {"трех", 3}, {"четырех", 4}, {"пяти", 5},
+}
+
+// roomSlang разговорные названия квартир
+var roomSlang = []struct {
+	prefix string
+	rooms  int
+}{
+	{"студи", 0}, {"однуш", 1}, {"однух", 1}, {"двуш", 2}, {"треш", 3},
+}
+
+// matchRooms распознает количество комнат: «студия», «двушка», «двухкомнатная»,
+// «2к», «2-х комн», «1-2к», «1,2 комнатные»
+func matchRooms(words []string, i int) (int, []int) {
+	word := words[i]
+	next := ""
+	if i+1 < len(words) {
+		next = words[i+1]
+	}
+
+	for _, slang := range roomSlang {
+		if strings.HasPrefix(word, slang.prefix) {
+			return 1, []int{slang.rooms}
+		}
+	}
+	for _, numeral := range numerals {
+		rest, ok := strings.CutPrefix(word, numeral.prefix)
+		if !ok {
+			continue
+		}
+		rest = strings.TrimPrefix(rest, "-")
+		if strings.HasPrefix(rest, "комн") {
+			return 1, []int{numeral.rooms}
+		}
+		if rest == "" && strings.HasPrefix(next, "комн") {
+			return 2, []int{numeral.rooms}
+		}
+	}
+
+	// «2к», «2-х», «1-2», «1,2» и необязательный суффикс комнат
+	digits, suffix := cutRoomDigits(word)
+	rooms := parseRoomList(digits)
+	if rooms == nil {
+		return 0, nil
+	}
+	suffix = strings.TrimPrefix(suffix, "-")
+	if rest, ok := strings.CutPrefix(suffix, "х"); ok {
+		suffix = strings.TrimPrefix(rest, "-")
+	} else if rest, ok := strings.CutPrefix(suffix, "x"); ok {
+		suffix = strings.TrimPrefix(rest, "-")
+	}
+
+	switch {
+	case suffix == "к" || strings.HasPrefix(suffix, "комн"):
+		return 1, rooms
+	case suffix == "" && (next == "к" || strings.HasPrefix(next, "комн")):
+		return 2, rooms
+	}
+	return 0, nil
+}
+
+// cutRoomDigits отделяет начало слова из цифр, запятых и дефисов между цифрами
+func cutRoomDigits(word string) (string, string) {
+	end := 0
+	for end < len(word) {
+		c := word[end]
+		if c >= '0' && c <= '9' {
+			end++
+			continue
+		}
+		if (c == ',' || c == '-') && end > 0 && end+1 < len(word) && word[end+1] >= '0' && word[end+1] <= '9' {
+			end++
+			continue
+		}
+		break
+	}
+	return word[:end], word[end:]
+}
+
+// parseRoomList разбирает «2», «1,2» и «1-3». Число комнат - одна цифра
+func parseRoomList(text string) []int {
+	if text == "" {
+		return nil
+	}
+	var rooms []int
+	for _, part := range strings.Split(text, ",") {
+		low, high, isRange := strings.Cut(part, "-")
+		if !isRange {
+			high = low
+		}
+		from, err1 := strconv.Atoi(low)
+		to, err2 := strconv.Atoi(high)
+		if err1 != nil || err2 != nil || len(low) != 1 || len(high) != 1 || from > to {
+			return nil
+		}
+		for n := from; n <= to; n++ {
+			rooms = appendUnique(rooms, n)
+		}
+	}
+	return rooms
+}
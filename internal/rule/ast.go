@@ -0,0 +1,232 @@
+package rule
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"github.com/RedNessen/inpars-telegram-bot/internal/inpars"
+)
+
+// kind тип значения выражения
+type kind int
+
+const (
+	kindNumber kind = iota
+	kindString
+	kindBool
+	kindNumberList
+	kindStringList
+)
+
+func (k kind) String() string {
+	switch k {
+	case kindNumber:
+		return "число"
+	case kindString:
+		return "строка"
+	case kindBool:
+		return "логическое"
+	case kindNumberList:
+		return "список чисел"
+	default:
+		return "список строк"
+	}
+}
+
+// listOf возвращает тип списка из элементов типа k
+func listOf(k kind) (kind, bool) {
+	switch k {
+	case kindNumber:
+		return kindNumberList, true
+	case kindString:
+		return kindStringList, true
+	}
+	return 0, false
+}
+
+// node узел дерева выражения. Типы проверяются при разборе, поэтому
+// eval возвращает значение заранее известного типа:
+// float64, string, bool, []float64 или []string
+type node interface {
+	kind() kind
+	eval(estate *inpars.Estate) any
+}
+
+type literal struct {
+	k kind
+	v any
+}
+
+func (n *literal) kind() kind              { return n.k }
+func (n *literal) eval(*inpars.Estate) any { return n.v }
+
+type fieldRef struct {
+	f *field
+}
+
+func (n *fieldRef) kind() kind                     { return n.f.kind }
+func (n *fieldRef) eval(estate *inpars.Estate) any { return n.f.get(estate) }
+
+type listExpr struct {
+	k     kind
+	items []node
+}
+
+func (n *listExpr) kind() kind { return n.k }
+func (n *listExpr) eval(estate *inpars.Estate) any {
+	if n.k == kindNumberList {
+		values := make([]float64, len(n.items))
+		for i, item := range n.items {
+			values[i] = item.eval(estate).(float64)
+		}
+		return values
+	}
+	values := make([]string, len(n.items))
+	for i, item := range n.items {
+		values[i] = item.eval(estate).(string)
+	}
+	return values
+}
+
+type unaryExpr struct {
+	op string
+	x  node
+}
+
+func (n *unaryExpr) kind() kind { return n.x.kind() }
+func (n *unaryExpr) eval(estate *inpars.Estate) any {
+	if n.op == "!" {
+		return !n.x.eval(estate).(bool)
+	}
+	return -n.x.eval(estate).(float64)
+}
+
+type binaryExpr struct {
+	op   string
+	k    kind
+	l, r node
+}
+
+func (n *binaryExpr) kind() kind { return n.k }
+func (n *binaryExpr) eval(estate *inpars.Estate) any {
+	switch n.op {
+	case "&&":
+		return n.l.eval(estate).(bool) && n.r.eval(estate).(bool)
+	case "||":
+		return n.l.eval(estate).(bool) || n.r.eval(estate).(bool)
+	}
+
+	l, r := n.l.eval(estate), n.r.eval(estate)
+	switch n.op {
+	case "==":
+		return equal(l, r)
+	case "!=":
+		return !equal(l, r)
+	}
+
+	if ls, ok := l.(string); ok {
+		rs := r.(string)
+		switch n.op {
+		case "+":
+			return ls + rs
+		case "<":
+			return ls < rs
+		case "<=":
+			return ls <= rs
+		case ">":
+			return ls > rs
+		case ">=":
+			return ls >= rs
+		}
+	}
+
+	lf, rf := l.(float64), r.(float64)
+	switch n.op {
+	case "+":
+		return lf + rf
+	case "-":
+		return lf - rf
+	case "*":
+		return lf * rf
+	case "/":
+		// Деление на 0 дает NaN, а не ±Inf: все сравнения с NaN ложны, поэтому
+		// и cost / sq < 900, и cost / sq > 900 не выполняются без площади
+		if rf == 0 {
+			return math.NaN()
+		}
+		return lf / rf
+	case "%":
+		return math.Mod(lf, rf)
+	case "<":
+		return lf < rf
+	case "<=":
+		return lf <= rf
+	case ">":
+		return lf > rf
+	default: // ">="
+		return lf >= rf
+	}
+}
+
+func equal(l, r any) bool {
+	if ls, ok := l.(string); ok {
+		return fold(ls) == fold(r.(string))
+	}
+	return l == r
+}
+
+type inExpr struct {
+	x    node
+	list node
+}
+
+func (n *inExpr) kind() kind { return kindBool }
+func (n *inExpr) eval(estate *inpars.Estate) any {
+	switch list := n.list.eval(estate).(type) {
+	case []float64:
+		x := n.x.eval(estate).(float64)
+		for _, v := range list {
+			if v == x {
+				return true
+			}
+		}
+	case []string:
+		x := fold(n.x.eval(estate).(string))
+		for _, v := range list {
+			if fold(v) == x {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+type callExpr struct {
+	fn   *function
+	impl func(args []any) any
+	args []node
+}
+
+func (n *callExpr) kind() kind { return n.fn.result }
+func (n *callExpr) eval(estate *inpars.Estate) any {
+	args := make([]any, len(n.args))
+	for i, arg := range n.args {
+		args[i] = arg.eval(estate)
+	}
+	return n.impl(args)
+}
+
+// fold приводит строку к виду для сравнения без учета регистра и ё/е
+func fold(s string) string {
+	return strings.ReplaceAll(strings.ToLower(s), "ё", "е")
+}
+
+// typeError ошибка несовпадения типов операндов
+func typeError(op string, pos int, kinds ...kind) error {
+	names := make([]string, len(kinds))
+	for i, k := range kinds {
+		names[i] = k.String()
+	}
+	return fmt.Errorf("оператор %s не применим к типам %s (позиция %d)", op, strings.Join(names, " и "), pos)
+}
@@ -0,0 +1,108 @@
+package rule
+
+import (
+	"strings"
+	"time"
+
+	"github.com/RedNessen/inpars-telegram-bot/internal/inpars"
+)
+
+// field поле объявления, доступное в выражении
+type field struct {
+	name string
+	kind kind
+	doc  string
+	get  func(estate *inpars.Estate) any
+}
+
+// fieldsByName поля по имени в нижнем регистре
+var fieldsByName = map[string]*field{}
+
+// fieldList поля в порядке описания для справки
+var fieldList []*field
+
+func num(name, doc string, get func(e *inpars.Estate) float64) *field {
+	return &field{name: name, kind: kindNumber, doc: doc, get: func(e *inpars.Estate) any { return get(e) }}
+}
+
+func str(name, doc string, get func(e *inpars.Estate) string) *field {
+	return &field{name: name, kind: kindString, doc: doc, get: func(e *inpars.Estate) any { return get(e) }}
+}
+
+func boolean(name, doc string, get func(e *inpars.Estate) bool) *field {
+	return &field{name: name, kind: kindBool, doc: doc, get: func(e *inpars.Estate) any { return get(e) }}
+}
+
+func init() {
+	fieldList = []*field{
+		num("id", "ID объявления", func(e *inpars.Estate) float64 { return float64(e.ID) }),
+		num("cost", "цена, ₽", func(e *inpars.Estate) float64 { return float64(e.Cost) }),
+		num("sq", "площадь, м²", func(e *inpars.Estate) float64 { return e.Sq }),
+		num("sqLiving", "жилая площадь, м²", func(e *inpars.Estate) float64 { return e.SqLiving }),
+		num("sqKitchen", "площадь кухни, м²", func(e *inpars.Estate) float64 { return e.SqKitchen }),
+		num("sqPrice", "цена за м² (0, если площадь не указана)", func(e *inpars.Estate) float64 {
+			if e.Sq <= 0 {
+				return 0
+			}
+			return float64(e.Cost) / e.Sq
+		}),
+		num("rooms", "количество комнат", func(e *inpars.Estate) float64 { return float64(e.Rooms) }),
+		num("floor", "этаж", func(e *inpars.Estate) float64 { return float64(e.Floor) }),
+		num("floors", "этажность дома", func(e *inpars.Estate) float64 { return float64(e.Floors) }),
+		num("agent", "0-собственник, 1-агент, 2-застройщик", func(e *inpars.Estate) float64 { return float64(e.Agent) }),
+		num("typeAd", "1-сдам, 2-продам, 3-сниму, 4-куплю", func(e *inpars.Estate) float64 { return float64(e.TypeAd) }),
+		num("rentTime", "0-не указан, 1-длительно, 2-посуточно", func(e *inpars.Estate) float64 { return float64(e.RentTime) }),
+		num("regionId", "ID региона", func(e *inpars.Estate) float64 { return float64(e.RegionID) }),
+		num("cityId", "ID города", func(e *inpars.Estate) float64 { return float64(e.CityID) }),
+		num("metroId", "ID станции метро", func(e *inpars.Estate) float64 { return float64(e.MetroID) }),
+		num("categoryId", "ID категории", func(e *inpars.Estate) float64 { return float64(e.CategoryID) }),
+		num("sourceId", "ID источника", func(e *inpars.Estate) float64 { return float64(e.SourceID) }),
+		num("buildYear", "год постройки (0, если не указан)", func(e *inpars.Estate) float64 {
+			if e.House == nil {
+				return 0
+			}
+			return float64(e.House.BuildYear)
+		}),
+		num("commission", "комиссия в % от цены (0, если не указана)", func(e *inpars.Estate) float64 {
+			if e.RentTerms == nil {
+				return 0
+			}
+			if e.RentTerms.CommissionType == 2 && e.Cost > 0 {
+				return float64(e.RentTerms.Commission) / float64(e.Cost) * 100
+			}
+			return float64(e.RentTerms.Commission)
+		}),
+		num("deposit", "залог, ₽", func(e *inpars.Estate) float64 {
+			if e.RentTerms == nil {
+				return 0
+			}
+			return float64(e.RentTerms.Deposit)
+		}),
+		num("ageHours", "сколько часов назад опубликовано", func(e *inpars.Estate) float64 {
+			created, err := e.ListedSince()
+			if err != nil {
+				return 0
+			}
+			return time.Since(created).Hours()
+		}),
+		num("lat", "широта", func(e *inpars.Estate) float64 { return float64(e.Lat) }),
+		num("lng", "долгота", func(e *inpars.Estate) float64 { return float64(e.Lng) }),
+		str("title", "заголовок", func(e *inpars.Estate) string { return e.Title }),
+		str("text", "описание", func(e *inpars.Estate) string { return e.Text }),
+		str("address", "адрес", func(e *inpars.Estate) string { return e.Address }),
+		str("region", "регион", func(e *inpars.Estate) string { return e.Region }),
+		str("city", "город", func(e *inpars.Estate) string { return e.City }),
+		str("metro", "станция метро", func(e *inpars.Estate) string { return e.Metro }),
+		str("category", "категория", func(e *inpars.Estate) string { return e.Category }),
+		str("material", "материал дома", func(e *inpars.Estate) string { return e.Material }),
+		str("source", "источник (avito.ru, cian.ru...)", func(e *inpars.Estate) string { return e.Source }),
+		str("name", "имя продавца", func(e *inpars.Estate) string { return e.Name }),
+		boolean("isNew", "новостройка", func(e *inpars.Estate) bool { return e.IsNew }),
+		boolean("isApartments", "апартаменты", func(e *inpars.Estate) bool { return e.IsApartments }),
+		boolean("phoneProtected", "подменный номер", func(e *inpars.Estate) bool { return e.PhoneProtected }),
+	}
+
+	for _, f := range fieldList {
+		fieldsByName[strings.ToLower(f.name)] = f
+	}
+}
@@ -0,0 +1,136 @@
+package rule
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/RedNessen/inpars-telegram-bot/internal/textmatch"
+)
+
+// function встроенная функция языка правил
+type function struct {
+	name   string
+	params []kind
+	result kind
+	doc    string
+
+	// prepare вызывается при компиляции и может подготовить аргументы-константы
+	// (например, скомпилировать регулярное выражение). По умолчанию используется impl
+	prepare func(args []node) (func(args []any) any, error)
+	impl    func(args []any) any
+}
+
+func (f *function) bind(args []node) (func(args []any) any, error) {
+	if f.prepare != nil {
+		return f.prepare(args)
+	}
+	return f.impl, nil
+}
+
+// functions встроенные функции по имени
+var functions = map[string]*function{}
+
+func init() {
+	for _, f := range []*function{
+		{
+			name: "contains", params: []kind{kindString, kindString}, result: kindBool,
+			doc: "contains(text, \"балкон\") - строка содержит подстроку (без учета регистра и ё)",
+			impl: func(args []any) any {
+				return strings.Contains(fold(args[0].(string)), fold(args[1].(string)))
+			},
+		},
+		{
+			name: "startswith", params: []kind{kindString, kindString}, result: kindBool,
+			doc: "startsWith(title, \"студия\") - строка начинается с подстроки",
+			impl: func(args []any) any {
+				return strings.HasPrefix(fold(args[0].(string)), fold(args[1].(string)))
+			},
+		},
+		{
+			name: "endswith", params: []kind{kindString, kindString}, result: kindBool,
+			doc: "endsWith(address, \"к2\") - строка заканчивается подстрокой",
+			impl: func(args []any) any {
+				return strings.HasSuffix(fold(args[0].(string)), fold(args[1].(string)))
+			},
+		},
+		{
+			name: "mentions", params: []kind{kindString, kindString}, result: kindBool,
+			doc: "mentions(text, \"можно с животными\") - фраза встречается в любой словоформе",
+			prepare: func(args []node) (func(args []any) any, error) {
+				if lit, ok := args[1].(*literal); ok {
+					phrase := textmatch.NewPhrase(lit.v.(string))
+					return func(args []any) any {
+						return phrase.In(textmatch.Words(args[0].(string)))
+					}, nil
+				}
+				return func(args []any) any {
+					return textmatch.NewPhrase(args[1].(string)).In(textmatch.Words(args[0].(string)))
+				}, nil
+			},
+		},
+		{
+			name: "matches", params: []kind{kindString, kindString}, result: kindBool,
+			doc: "matches(text, \"\\\\d+ этаж\") - регулярное выражение (без учета регистра)",
+			prepare: func(args []node) (func(args []any) any, error) {
+				lit, ok := args[1].(*literal)
+				if !ok {
+					return nil, fmt.Errorf("регулярное выражение должно быть строковой константой")
+				}
+				re, err := regexp.Compile("(?i)" + strings.NewReplacer("ё", "е", "Ё", "Е").Replace(lit.v.(string)))
+				if err != nil {
+					return nil, fmt.Errorf("некорректное регулярное выражение: %w", err)
+				}
+				return func(args []any) any {
+					return re.MatchString(fold(args[0].(string)))
+				}, nil
+			},
+		},
+		{
+			name: "lower", params: []kind{kindString}, result: kindString,
+			doc: "lower(metro) - строка в нижнем регистре",
+			impl: func(args []any) any {
+				return fold(args[0].(string))
+			},
+		},
+		{
+			name: "len", params: []kind{kindString}, result: kindNumber,
+			doc: "len(text) - длина строки в символах",
+			impl: func(args []any) any {
+				return float64(utf8.RuneCountInString(args[0].(string)))
+			},
+		},
+		{
+			name: "abs", params: []kind{kindNumber}, result: kindNumber,
+			doc: "abs(x) - модуль числа",
+			impl: func(args []any) any {
+				return math.Abs(args[0].(float64))
+			},
+		},
+		{
+			name: "round", params: []kind{kindNumber}, result: kindNumber,
+			doc: "round(x) - округление до целого",
+			impl: func(args []any) any {
+				return math.Round(args[0].(float64))
+			},
+		},
+		{
+			name: "min", params: []kind{kindNumber, kindNumber}, result: kindNumber,
+			doc: "min(a, b) - меньшее из чисел",
+			impl: func(args []any) any {
+				return math.Min(args[0].(float64), args[1].(float64))
+			},
+		},
+		{
+			name: "max", params: []kind{kindNumber, kindNumber}, result: kindNumber,
+			doc: "max(a, b) - большее из чисел",
+			impl: func(args []any) any {
+				return math.Max(args[0].(float64), args[1].(float64))
+			},
+		},
+	} {
+		functions[f.name] = f
+	}
+}
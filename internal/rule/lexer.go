@@ -0,0 +1,129 @@
+package rule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// tokenKind тип лексемы
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokString
+	tokIdent
+	tokOp
+)
+
+// token лексема выражения
+type token struct {
+	kind tokenKind
+	text string  // Оператор или имя
+	num  float64 // Значение числа
+	str  string  // Значение строки без кавычек
+	pos  int     // Позиция в исходном тексте (в символах, с 1)
+}
+
+// operators операторы от длинных к коротким
+var operators = []string{
+	"&&", "||", "==", "!=", "<=", ">=",
+	"!", "<", ">", "+", "-", "*", "/", "%", "(", ")", "[", "]", ",",
+}
+
+// wordOperators словесные синонимы операторов
+var wordOperators = map[string]string{
+	"and": "&&",
+	"or":  "||",
+	"not": "!",
+	"и":   "&&",
+	"или": "||",
+	"не":  "!",
+}
+
+// lex разбивает выражение на лексемы
+func lex(source string) ([]token, error) {
+	runes := []rune(source)
+	var tokens []token
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		pos := i + 1
+
+		switch {
+		case unicode.IsSpace(r):
+			i++
+
+		case unicode.IsDigit(r) || r == '.' && i+1 < len(runes) && unicode.IsDigit(runes[i+1]):
+			start := i
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.' || runes[i] == '_') {
+				i++
+			}
+			text := strings.ReplaceAll(string(runes[start:i]), "_", "")
+			num, err := strconv.ParseFloat(text, 64)
+			if err != nil {
+				return nil, fmt.Errorf("некорректное число %q (позиция %d)", text, pos)
+			}
+			tokens = append(tokens, token{kind: tokNumber, text: text, num: num, pos: pos})
+
+		case r == '"' || r == '\'':
+			quote := r
+			var sb strings.Builder
+			i++
+			closed := false
+			for i < len(runes) {
+				c := runes[i]
+				i++
+				if c == quote {
+					closed = true
+					break
+				}
+				if c == '\\' && i < len(runes) {
+					c = runes[i]
+					i++
+					switch c {
+					case 'n':
+						c = '\n'
+					case 't':
+						c = '\t'
+					}
+				}
+				sb.WriteRune(c)
+			}
+			if !closed {
+				return nil, fmt.Errorf("незакрытая строка (позиция %d)", pos)
+			}
+			tokens = append(tokens, token{kind: tokString, str: sb.String(), pos: pos})
+
+		case unicode.IsLetter(r) || r == '_':
+			start := i
+			for i < len(runes) && (unicode.IsLetter(runes[i]) || unicode.IsDigit(runes[i]) || runes[i] == '_') {
+				i++
+			}
+			word := string(runes[start:i])
+			if op, ok := wordOperators[strings.ToLower(word)]; ok {
+				tokens = append(tokens, token{kind: tokOp, text: op, pos: pos})
+			} else {
+				tokens = append(tokens, token{kind: tokIdent, text: word, pos: pos})
+			}
+
+		default:
+			matched := false
+			for _, op := range operators {
+				if strings.HasPrefix(string(runes[i:min(i+2, len(runes))]), op) {
+					tokens = append(tokens, token{kind: tokOp, text: op, pos: pos})
+					i += len([]rune(op))
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return nil, fmt.Errorf("неожиданный символ %q (позиция %d)", r, pos)
+			}
+		}
+	}
+
+	return append(tokens, token{kind: tokEOF, pos: len(runes) + 1}), nil
+}
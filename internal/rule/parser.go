@@ -0,0 +1,306 @@
+package rule
+
+import (
+	"fmt"
+	"strings"
+)
+
+// parser разбирает лексемы в дерево и проверяет типы
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+// acceptOp пропускает оператор op, если он следующий
+func (p *parser) acceptOp(op string) bool {
+	if t := p.peek(); t.kind == tokOp && t.text == op {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expectOp(op string) error {
+	if !p.acceptOp(op) {
+		return p.unexpected(fmt.Sprintf("ожидается %q", op))
+	}
+	return nil
+}
+
+func (p *parser) unexpected(expected string) error {
+	t := p.peek()
+	if t.kind == tokEOF {
+		return fmt.Errorf("неожиданный конец выражения: %s", expected)
+	}
+	text := t.text
+	if t.kind == tokString {
+		text = `"` + t.str + `"`
+	}
+	return fmt.Errorf("неожиданное %q (позиция %d): %s", text, t.pos, expected)
+}
+
+// parseOr: and { "||" and }
+func (p *parser) parseOr() (node, error) {
+	return p.parseLogical("||", p.parseAnd)
+}
+
+// parseAnd: comparison { "&&" comparison }
+func (p *parser) parseAnd() (node, error) {
+	return p.parseLogical("&&", p.parseComparison)
+}
+
+func (p *parser) parseLogical(op string, operand func() (node, error)) (node, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		if !p.acceptOp(op) {
+			return left, nil
+		}
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		if left.kind() != kindBool || right.kind() != kindBool {
+			return nil, typeError(op, t.pos, left.kind(), right.kind())
+		}
+		left = &binaryExpr{op: op, k: kindBool, l: left, r: right}
+	}
+}
+
+var comparisonOps = []string{"==", "!=", "<=", ">=", "<", ">"}
+
+// parseComparison: additive [ (==|!=|<|<=|>|>=) additive | in additive ]
+func (p *parser) parseComparison() (node, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+
+	t := p.peek()
+	if t.kind == tokIdent && strings.ToLower(t.text) == "in" {
+		p.next()
+		list, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		if want, ok := listOf(left.kind()); !ok || list.kind() != want {
+			return nil, typeError("in", t.pos, left.kind(), list.kind())
+		}
+		return &inExpr{x: left, list: list}, nil
+	}
+
+	for _, op := range comparisonOps {
+		if !p.acceptOp(op) {
+			continue
+		}
+		right, err := p.parseAdditive()
+		if err != nil {
+			return nil, err
+		}
+		if left.kind() != right.kind() || left.kind() == kindNumberList || left.kind() == kindStringList ||
+			left.kind() == kindBool && op != "==" && op != "!=" {
+			return nil, typeError(op, t.pos, left.kind(), right.kind())
+		}
+		return &binaryExpr{op: op, k: kindBool, l: left, r: right}, nil
+	}
+	return left, nil
+}
+
+// parseAdditive: multiplicative { (+|-) multiplicative }
+func (p *parser) parseAdditive() (node, error) {
+	return p.parseArithmetic([]string{"+", "-"}, p.parseMultiplicative)
+}
+
+// parseMultiplicative: unary { (*|/|%) unary }
+func (p *parser) parseMultiplicative() (node, error) {
+	return p.parseArithmetic([]string{"*", "/", "%"}, p.parseUnary)
+}
+
+func (p *parser) parseArithmetic(ops []string, operand func() (node, error)) (node, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		op := ""
+		for _, candidate := range ops {
+			if p.acceptOp(candidate) {
+				op = candidate
+				break
+			}
+		}
+		if op == "" {
+			return left, nil
+		}
+
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		// Строки можно только складывать
+		ok := left.kind() == right.kind() &&
+			(left.kind() == kindNumber || left.kind() == kindString && op == "+")
+		if !ok {
+			return nil, typeError(op, t.pos, left.kind(), right.kind())
+		}
+		left = &binaryExpr{op: op, k: left.kind(), l: left, r: right}
+	}
+}
+
+// parseUnary: (!|-) unary | primary
+func (p *parser) parseUnary() (node, error) {
+	t := p.peek()
+	for _, op := range []string{"!", "-"} {
+		if !p.acceptOp(op) {
+			continue
+		}
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		want := kindBool
+		if op == "-" {
+			want = kindNumber
+		}
+		if x.kind() != want {
+			return nil, typeError(op, t.pos, x.kind())
+		}
+		return &unaryExpr{op: op, x: x}, nil
+	}
+	return p.parsePrimary()
+}
+
+// parsePrimary: число | строка | true | false | поле | функция(аргументы) | (выражение) | [список]
+func (p *parser) parsePrimary() (node, error) {
+	t := p.next()
+	switch t.kind {
+	case tokNumber:
+		return &literal{k: kindNumber, v: t.num}, nil
+
+	case tokString:
+		return &literal{k: kindString, v: t.str}, nil
+
+	case tokIdent:
+		name := strings.ToLower(t.text)
+		switch name {
+		case "true":
+			return &literal{k: kindBool, v: true}, nil
+		case "false":
+			return &literal{k: kindBool, v: false}, nil
+		}
+
+		if p.acceptOp("(") {
+			return p.parseCall(t)
+		}
+		f, ok := fieldsByName[name]
+		if !ok {
+			return nil, fmt.Errorf("неизвестное поле %q (позиция %d)", t.text, t.pos)
+		}
+		return &fieldRef{f: f}, nil
+
+	case tokOp:
+		switch t.text {
+		case "(":
+			x, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			return x, p.expectOp(")")
+		case "[":
+			return p.parseList(t)
+		}
+	}
+
+	// next не сдвигается дальше конца выражения, возвращаться нужно только к прочитанной лексеме
+	if t.kind != tokEOF {
+		p.pos--
+	}
+	return nil, p.unexpected("ожидается значение")
+}
+
+// parseList разбирает список значений одного типа: [1, 2, 3] или ["a", "b"]
+func (p *parser) parseList(open token) (node, error) {
+	list := &listExpr{}
+	for !p.acceptOp("]") {
+		if len(list.items) > 0 {
+			if err := p.expectOp(","); err != nil {
+				return nil, err
+			}
+		}
+		item, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		list.items = append(list.items, item)
+	}
+
+	if len(list.items) == 0 {
+		return nil, fmt.Errorf("пустой список (позиция %d)", open.pos)
+	}
+	k, ok := listOf(list.items[0].kind())
+	if !ok {
+		return nil, fmt.Errorf("список может содержать только числа или строки (позиция %d)", open.pos)
+	}
+	for _, item := range list.items[1:] {
+		if item.kind() != list.items[0].kind() {
+			return nil, fmt.Errorf("элементы списка должны быть одного типа (позиция %d)", open.pos)
+		}
+	}
+	list.k = k
+	return list, nil
+}
+
+// parseCall разбирает аргументы вызова функции и проверяет их типы
+func (p *parser) parseCall(name token) (node, error) {
+	fn, ok := functions[strings.ToLower(name.text)]
+	if !ok {
+		return nil, fmt.Errorf("неизвестная функция %q (позиция %d)", name.text, name.pos)
+	}
+
+	var args []node
+	for !p.acceptOp(")") {
+		if len(args) > 0 {
+			if err := p.expectOp(","); err != nil {
+				return nil, err
+			}
+		}
+		arg, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+
+	if len(args) != len(fn.params) {
+		return nil, fmt.Errorf("функция %s ожидает %d аргумент(а), передано %d (позиция %d)",
+			fn.name, len(fn.params), len(args), name.pos)
+	}
+	for i, arg := range args {
+		if arg.kind() != fn.params[i] {
+			return nil, fmt.Errorf("аргумент %d функции %s должен быть типа «%s», а не «%s» (позиция %d)",
+				i+1, fn.name, fn.params[i], arg.kind(), name.pos)
+		}
+	}
+
+	impl, err := fn.bind(args)
+	if err != nil {
+		return nil, fmt.Errorf("%s (позиция %d): %w", fn.name, name.pos, err)
+	}
+	return &callExpr{fn: fn, impl: impl, args: args}, nil
+}
@@ -0,0 +1,84 @@
+// Package rule реализует язык правил для подписок: логические выражения над
+// полями объявления, например
+//
+//	rooms >= 2 && cost / sq < 900 && agent == 0 && !contains(text, "посуточно")
+//
+// Выражение разбирается и проверяется по типам при компиляции, поэтому ошибки
+// (неизвестное поле, сравнение строки с числом) обнаруживаются сразу, а не при
+// проверке объявления. Язык не содержит циклов, присваиваний и доступа к чему-либо,
+// кроме полей объявления и встроенных функций, поэтому вычисление безопасно и
+// занимает время, пропорциональное длине выражения
+package rule
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/RedNessen/inpars-telegram-bot/internal/inpars"
+)
+
+// MaxLength ограничение длины выражения в символах
+const MaxLength = 1000
+
+// Program скомпилированное правило
+type Program struct {
+	source string
+	root   node
+}
+
+// Compile разбирает выражение и проверяет типы. Результат выражения должен быть логическим
+func Compile(source string) (*Program, error) {
+	source = strings.TrimSpace(source)
+	if source == "" {
+		return nil, fmt.Errorf("пустое правило")
+	}
+	if len([]rune(source)) > MaxLength {
+		return nil, fmt.Errorf("правило длиннее %d символов", MaxLength)
+	}
+
+	tokens, err := lex(source)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &parser{tokens: tokens}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek().kind != tokEOF {
+		return nil, p.unexpected("ожидается конец выражения")
+	}
+	if root.kind() != kindBool {
+		return nil, fmt.Errorf("правило должно быть условием (true/false), а не значением типа «%s»", root.kind())
+	}
+
+	return &Program{source: source, root: root}, nil
+}
+
+// Match вычисляет правило для объявления
+func (p *Program) Match(estate *inpars.Estate) bool {
+	return p.root.eval(estate).(bool)
+}
+
+// String возвращает исходный текст правила
+func (p *Program) String() string {
+	return p.source
+}
+
+// Help возвращает справку по полям и функциям языка
+func Help() string {
+	var sb strings.Builder
+
+	sb.WriteString("Операторы: && || ! (and, or, not), == != < <= > >=, + - * / %, in [список]\n")
+	sb.WriteString("Строки сравниваются без учета регистра и ё/е.\n\nПоля:\n")
+	for _, f := range fieldList {
+		sb.WriteString(fmt.Sprintf("  %s (%s) - %s\n", f.name, f.kind, f.doc))
+	}
+
+	sb.WriteString("\nФункции:\n")
+	for _, name := range []string{"contains", "mentions", "matches", "startswith", "endswith", "lower", "len", "abs", "round", "min", "max"} {
+		sb.WriteString("  " + functions[name].doc + "\n")
+	}
+	return sb.String()
+}
@@ -0,0 +1,218 @@
+package rule
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/RedNessen/inpars-telegram-bot/internal/inpars"
+)
+
+// testEstate объявление, на котором проверяются правила
+var testEstate = inpars.Estate{
+	ID:     100,
+	Title:  "Студия у метро",
+	Text:   "Есть балкон, 3 этаж. Ёлка во дворе",
+	Metro:  "Сокол",
+	Cost:   40000,
+	Sq:     50,
+	Rooms:  2,
+	Floor:  3,
+	Floors: 9,
+}
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		source string
+		want   bool
+	}{
+		// Приоритет арифметики
+		{"1 + 2 * 3 == 7", true},
+		{"(1 + 2) * 3 == 9", true},
+		{"2 * 3 % 4 == 2", true},
+		{"-2 * 3 == -6", true},
+		{"--1 == 1", true},
+		{"1 + 2 < 2 * 2", true},
+
+		// Левая ассоциативность
+		{"10 - 4 - 3 == 3", true},
+		{"100 / 10 / 5 == 2", true},
+		{"\"a\" + \"b\" + \"c\" == \"abc\"", true},
+
+		// && связывает сильнее ||, ! сильнее &&
+		{"true || false && false", true},
+		{"(true || false) && false", false},
+		{"!false && false", false},
+		{"!(false && false)", true},
+		{"false || !true || true", true},
+
+		// Словесные операторы
+		{"rooms >= 2 and not isNew", true},
+		{"rooms > 5 or floor == 3", true},
+		{"rooms >= 2 и не isNew", true},
+		{"rooms > 5 или floor == 3", true},
+
+		// Поля и сравнения
+		{"cost == 40000", true},
+		{"cost / sq == 800", true},
+		{"sqPrice < 900", true},
+		{"floor < floors", true},
+		{"metro == \"СОКОЛ\"", true},
+		{"metro != \"сокол\"", false},
+		{"title < \"яблоко\"", true},
+		{"isNew == false", true},
+
+		// Списки
+		{"rooms in [1, 2]", true},
+		{"rooms in [3, 4]", false},
+		{"metro in [\"аэропорт\", \"сокол\"]", true},
+
+		// contains без учета регистра и ё/е
+		{"contains(text, \"БАЛКОН\")", true},
+		{"contains(text, \"елка\")", true},
+		{"contains(text, \"ёлка\")", true},
+		{"contains(text, \"посуточно\")", false},
+		{"!contains(text, \"посуточно\")", true},
+		{"startsWith(title, \"студия\")", true},
+		{"endsWith(title, \"МЕТРО\")", true},
+
+		// Регулярные выражения
+		{`matches(text, "\\d+ этаж")`, true},
+		{`matches(text, "^\\d+ этаж")`, false},
+		{"matches(title, \"^СТУДИЯ\")", true},
+		{"matches(text, \"ёлк\")", true},
+		{"matches(text, \"ЁЛК\")", true},
+		{"matches(text, \"лоджия|балкон\")", true},
+
+		// Остальные функции
+		{"len(metro) == 5", true},
+		{"lower(metro) == \"сокол\"", true},
+		{"abs(-3) == 3", true},
+		{"round(2.6) == 3", true},
+		{"min(rooms, floor) == 2", true},
+		{"max(rooms, floor) == 3", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			program, err := Compile(tt.source)
+			if err != nil {
+				t.Fatalf("Compile(%q) error: %v", tt.source, err)
+			}
+			if got := program.Match(&testEstate); got != tt.want {
+				t.Errorf("Compile(%q).Match = %v, want %v", tt.source, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		// Типы
+		{`"a" > 1`, "оператор > не применим к типам строка и число (позиция 5)"},
+		{"!cost", "оператор ! не применим к типам число (позиция 1)"},
+		{"-title == 1", "оператор - не применим к типам строка (позиция 1)"},
+		{"cost && true", "оператор && не применим к типам число и логическое (позиция 6)"},
+		{`"a" - "b" == ""`, "оператор - не применим к типам строка и строка (позиция 5)"},
+		{"true < false", "оператор < не применим к типам логическое и логическое (позиция 6)"},
+		{`rooms in ["a"]`, "оператор in не применим к типам число и список строк (позиция 7)"},
+		{"cost", "правило должно быть условием (true/false), а не значением типа «число»"},
+		{"[1, \"a\"] == 1", "элементы списка должны быть одного типа (позиция 1)"},
+
+		// Сравнения не цепляются друг за друга
+		{"1 < 2 < 3", `неожиданное "<" (позиция 7): ожидается конец выражения`},
+
+		// Неизвестные поля и функции
+		{"price > 1", `неизвестное поле "price" (позиция 1)`},
+		{"rooms > 1 && foo(text)", `неизвестная функция "foo" (позиция 14)`},
+		{"contains(text)", "функция contains ожидает 2 аргумент(а), передано 1 (позиция 1)"},
+		{`contains(cost, "a")`, "аргумент 1 функции contains должен быть типа «строка», а не «число» (позиция 1)"},
+
+		// Регулярные выражения проверяются при компиляции
+		{"matches(text, title)", "matches (позиция 1): регулярное выражение должно быть строковой константой"},
+
+		// Синтаксис
+		{"", "пустое правило"},
+		{"rooms >", "неожиданный конец выражения: ожидается значение"},
+		{`contains(text, "балкон`, "незакрытая строка (позиция 16)"},
+		{"rooms # 2", "неожиданный символ '#' (позиция 7)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.source, func(t *testing.T) {
+			_, err := Compile(tt.source)
+			if err == nil || err.Error() != tt.want {
+				t.Errorf("Compile(%q) error = %v, want %q", tt.source, err, tt.want)
+			}
+		})
+	}
+
+	// Текст ошибки регулярного выражения зависит от пакета regexp, проверяем только начало
+	_, err := Compile(`matches(text, "(")`)
+	if err == nil || !strings.HasPrefix(err.Error(), "matches (позиция 1): некорректное регулярное выражение") {
+		t.Errorf("Compile with invalid regexp error = %v", err)
+	}
+}
+
+func TestDivisionByZero(t *testing.T) {
+	estate := testEstate
+	estate.Sq = 0
+
+	// Без площади цена за метр не больше и не меньше порога
+	for _, source := range []string{
+		"cost / sq < 900",
+		"cost / sq > 900",
+		"cost / sq == cost / sq",
+		"cost % 0 >= 0",
+		"-cost / sq < 0",
+	} {
+		program, err := Compile(source)
+		if err != nil {
+			t.Fatalf("Compile(%q) error: %v", source, err)
+		}
+		if program.Match(&estate) {
+			t.Errorf("Compile(%q).Match with zero area = true, want false", source)
+		}
+	}
+
+	program, err := Compile("cost / sq != 900")
+	if err != nil {
+		t.Fatalf("Compile error: %v", err)
+	}
+	if !program.Match(&estate) {
+		t.Errorf("cost / sq != 900 with zero area = false, want true")
+	}
+}
+
+// TestUsageExample проверяет пример из справки /rule
+func TestUsageExample(t *testing.T) {
+	program, err := Compile(`rooms >= 2 && cost / sq < 900 && agent == 0 && !contains(text, "посуточно")`)
+	if err != nil {
+		t.Fatalf("Compile error: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(e *inpars.Estate)
+		want   bool
+	}{
+		{"подходит", func(e *inpars.Estate) {}, true},
+		{"однушка", func(e *inpars.Estate) { e.Rooms = 1 }, false},
+		{"дорогой метр", func(e *inpars.Estate) { e.Sq = 40 }, false},
+		{"без площади", func(e *inpars.Estate) { e.Sq = 0 }, false},
+		{"агент", func(e *inpars.Estate) { e.Agent = 1 }, false},
+		{"посуточно", func(e *inpars.Estate) { e.Text = "Сдается ПОСУТОЧНО" }, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			estate := testEstate
+			tt.modify(&estate)
+			if got := program.Match(&estate); got != tt.want {
+				t.Errorf("Match = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
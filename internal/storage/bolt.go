@@ -0,0 +1,514 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/RedNessen/inpars-telegram-bot/internal/inpars"
+	"github.com/RedNessen/inpars-telegram-bot/internal/places"
+	"github.com/RedNessen/inpars-telegram-bot/internal/subscription"
+)
+
+// Названия bucket'ов в файле базы данных
+var (
+	bucketChats         = []byte("chats")
+	bucketSubscriptions = []byte("subscriptions")
+	bucketCursors       = []byte("cursors")
+	bucketSeen          = []byte("seen")
+	bucketMeta          = []byte("meta")
+	bucketTracked       = []byte("tracked")
+	bucketPlaces        = []byte("places")
+	bucketSettings      = []byte("settings")
+	bucketFavorites     = []byte("favorites")
+	bucketHidden        = []byte("hidden")
+	bucketWatches       = []byte("watches")
+)
+
+// Ключи bucket'а meta
+var (
+	keyLastPoll       = []byte("lastPoll")
+	keyLastUpdatePoll = []byte("lastUpdatePoll")
+)
+
+// chatRecord запись о чате в базе данных
+type chatRecord struct {
+	Active  bool      `json:"active"`
+	Updated time.Time `json:"updated"`
+}
+
+// BoltStorage хранилище во встроенной базе данных bbolt (один файл на диске)
+type BoltStorage struct {
+	db *bolt.DB
+}
+
+// OpenBolt открывает (или создает) файл базы данных по указанному пути
+func OpenBolt(path string) (*BoltStorage, error) {
+	if dir := filepath.Dir(path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return nil, fmt.Errorf("failed to create storage directory: %w", err)
+		}
+	}
+
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open storage: %w", err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{bucketChats, bucketSubscriptions, bucketCursors, bucketSeen, bucketMeta, bucketTracked, bucketPlaces, bucketSettings, bucketFavorites, bucketHidden, bucketWatches} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize storage: %w", err)
+	}
+
+	return &BoltStorage{db: db}, nil
+}
+
+// SaveChat сохраняет признак активности чата
+func (s *BoltStorage) SaveChat(chatID int64, active bool) error {
+	data, err := json.Marshal(chatRecord{Active: active, Updated: time.Now()})
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketChats).Put(int64Key(chatID), data)
+	})
+}
+
+// ActiveChats возвращает чаты, получающие уведомления
+func (s *BoltStorage) ActiveChats() ([]int64, error) {
+	var chats []int64
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketChats).ForEach(func(k, v []byte) error {
+			var record chatRecord
+			if err := json.Unmarshal(v, &record); err != nil {
+				return fmt.Errorf("failed to decode chat: %w", err)
+			}
+			if record.Active {
+				chats = append(chats, int64(binary.BigEndian.Uint64(k)))
+			}
+			return nil
+		})
+	})
+	return chats, err
+}
+
+// SaveSubscription добавляет или заменяет подписку
+func (s *BoltStorage) SaveSubscription(sub *subscription.Subscription) error {
+	data, err := json.Marshal(sub)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketSubscriptions).Put([]byte(sub.Key()), data)
+	})
+}
+
+// DeleteSubscription удаляет подписку вместе с ее курсором
+func (s *BoltStorage) DeleteSubscription(chatID int64, name string) error {
+	key := []byte(subscription.KeyOf(chatID, name))
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.Bucket(bucketSubscriptions).Delete(key); err != nil {
+			return err
+		}
+		return tx.Bucket(bucketCursors).Delete(key)
+	})
+}
+
+// Subscriptions возвращает все сохраненные подписки
+func (s *BoltStorage) Subscriptions() ([]*subscription.Subscription, error) {
+	var subs []*subscription.Subscription
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketSubscriptions).ForEach(func(k, v []byte) error {
+			var sub subscription.Subscription
+			if err := json.Unmarshal(v, &sub); err != nil {
+				return fmt.Errorf("failed to decode subscription %s: %w", k, err)
+			}
+			subs = append(subs, &sub)
+			return nil
+		})
+	})
+	return subs, err
+}
+
+// SaveCursors сохраняет последние обработанные ID подписок
+func (s *BoltStorage) SaveCursors(cursors map[string]int) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(bucketCursors)
+		for key, id := range cursors {
+			if err := bucket.Put([]byte(key), []byte(strconv.Itoa(id))); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Cursors возвращает сохраненные курсоры подписок
+func (s *BoltStorage) Cursors() (map[string]int, error) {
+	cursors := make(map[string]int)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketCursors).ForEach(func(k, v []byte) error {
+			id, err := strconv.Atoi(string(v))
+			if err != nil {
+				return fmt.Errorf("failed to decode cursor %s: %w", k, err)
+			}
+			cursors[string(k)] = id
+			return nil
+		})
+	})
+	return cursors, err
+}
+
+// MarkSeen отмечает объявления как обработанные
+func (s *BoltStorage) MarkSeen(ids []int) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(bucketSeen)
+		for _, id := range ids {
+			if err := bucket.Put(int64Key(int64(id)), nil); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// SeenIDs возвращает все обработанные объявления
+func (s *BoltStorage) SeenIDs() ([]int, error) {
+	var ids []int
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketSeen).ForEach(func(k, _ []byte) error {
+			ids = append(ids, int(binary.BigEndian.Uint64(k)))
+			return nil
+		})
+	})
+	return ids, err
+}
+
+// PruneSeen удаляет обработанные объявления с ID меньше minID.
+// Ключи хранятся в big-endian, поэтому курсор обходит их по возрастанию ID
+func (s *BoltStorage) PruneSeen(minID int) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucketSeen).Cursor()
+		for k, _ := c.First(); k != nil && int(binary.BigEndian.Uint64(k)) < minID; k, _ = c.Next() {
+			if err := c.Delete(); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// SaveLastPoll сохраняет время последней успешной проверки объявлений
+func (s *BoltStorage) SaveLastPoll(t time.Time) error {
+	return s.saveTime(keyLastPoll, t)
+}
+
+// LastPoll возвращает время последней проверки (нулевое, если проверок не было)
+func (s *BoltStorage) LastPoll() (time.Time, error) {
+	return s.loadTime(keyLastPoll)
+}
+
+// SaveLastUpdatePoll сохраняет время последней проверки изменений объявлений
+func (s *BoltStorage) SaveLastUpdatePoll(t time.Time) error {
+	return s.saveTime(keyLastUpdatePoll, t)
+}
+
+// LastUpdatePoll возвращает время последней проверки изменений
+func (s *BoltStorage) LastUpdatePoll() (time.Time, error) {
+	return s.loadTime(keyLastUpdatePoll)
+}
+
+func (s *BoltStorage) saveTime(key []byte, t time.Time) error {
+	data, err := t.MarshalBinary()
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketMeta).Put(key, data)
+	})
+}
+
+func (s *BoltStorage) loadTime(key []byte) (time.Time, error) {
+	var t time.Time
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(bucketMeta).Get(key)
+		if data == nil {
+			return nil
+		}
+		return t.UnmarshalBinary(data)
+	})
+	return t, err
+}
+
+// SaveTracked сохраняет снимок отправленного объявления
+func (s *BoltStorage) SaveTracked(tracked *TrackedEstate) error {
+	data, err := json.Marshal(tracked)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketTracked).Put(int64Key(int64(tracked.Estate.ID)), data)
+	})
+}
+
+// Tracked возвращает снимки объявлений с указанными ID
+func (s *BoltStorage) Tracked(ids []int) (map[int]*TrackedEstate, error) {
+	result := make(map[int]*TrackedEstate)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(bucketTracked)
+		for _, id := range ids {
+			data := bucket.Get(int64Key(int64(id)))
+			if data == nil {
+				continue
+			}
+			var tracked TrackedEstate
+			if err := json.Unmarshal(data, &tracked); err != nil {
+				return fmt.Errorf("failed to decode tracked estate %d: %w", id, err)
+			}
+			result[id] = &tracked
+		}
+		return nil
+	})
+	return result, err
+}
+
+// PruneTracked удаляет снимки, сохраненные раньше before
+func (s *BoltStorage) PruneTracked(before time.Time) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucketTracked).Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			var tracked TrackedEstate
+			if err := json.Unmarshal(v, &tracked); err != nil || tracked.Saved.Before(before) {
+				if err := c.Delete(); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// SaveChatSettings сохраняет настройки чата (настройки по умолчанию удаляются)
+func (s *BoltStorage) SaveChatSettings(chatID int64, settings ChatSettings) error {
+	key := int64Key(chatID)
+	if settings == (ChatSettings{}) {
+		return s.db.Update(func(tx *bolt.Tx) error {
+			return tx.Bucket(bucketSettings).Delete(key)
+		})
+	}
+
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketSettings).Put(key, data)
+	})
+}
+
+// ChatSettings возвращает настройки чатов, отличные от настроек по умолчанию
+func (s *BoltStorage) ChatSettings() (map[int64]ChatSettings, error) {
+	result := make(map[int64]ChatSettings)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketSettings).ForEach(func(k, v []byte) error {
+			chatID := int64(binary.BigEndian.Uint64(k))
+			var settings ChatSettings
+			if err := json.Unmarshal(v, &settings); err != nil {
+				return fmt.Errorf("failed to decode settings of chat %d: %w", chatID, err)
+			}
+			result[chatID] = settings
+			return nil
+		})
+	})
+	return result, err
+}
+
+// SaveFavorite добавляет объявление в избранное чата
+func (s *BoltStorage) SaveFavorite(chatID int64, estate *inpars.Estate) error {
+	return s.saveEstate(bucketFavorites, chatID, estate)
+}
+
+// DeleteFavorite удаляет объявление из избранного
+func (s *BoltStorage) DeleteFavorite(chatID int64, estateID int) (bool, error) {
+	return s.deleteEstate(bucketFavorites, chatID, estateID)
+}
+
+// IsFavorite проверяет, есть ли объявление в избранном чата
+func (s *BoltStorage) IsFavorite(chatID int64, estateID int) (bool, error) {
+	found := false
+	err := s.db.View(func(tx *bolt.Tx) error {
+		found = tx.Bucket(bucketFavorites).Get(chatEstateKey(chatID, estateID)) != nil
+		return nil
+	})
+	return found, err
+}
+
+// Favorites возвращает избранное чата, сначала недавно добавленные
+func (s *BoltStorage) Favorites(chatID int64) ([]*SavedEstate, error) {
+	var result []*SavedEstate
+	err := s.db.View(func(tx *bolt.Tx) error {
+		prefix := int64Key(chatID)
+		c := tx.Bucket(bucketFavorites).Cursor()
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			var saved SavedEstate
+			if err := json.Unmarshal(v, &saved); err != nil {
+				return fmt.Errorf("failed to decode favorite %x: %w", k, err)
+			}
+			result = append(result, &saved)
+		}
+		return nil
+	})
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Saved.After(result[j].Saved) })
+	return result, err
+}
+
+// SaveHidden скрывает объявление и его копии в чате
+func (s *BoltStorage) SaveHidden(chatID int64, estate *inpars.Estate) error {
+	return s.saveEstate(bucketHidden, chatID, estate)
+}
+
+// DeleteHidden возвращает скрытое объявление
+func (s *BoltStorage) DeleteHidden(chatID int64, estateID int) (bool, error) {
+	return s.deleteEstate(bucketHidden, chatID, estateID)
+}
+
+// Hidden возвращает скрытые объявления всех чатов
+func (s *BoltStorage) Hidden() ([]*SavedEstate, error) {
+	var result []*SavedEstate
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketHidden).ForEach(func(k, v []byte) error {
+			var saved SavedEstate
+			if err := json.Unmarshal(v, &saved); err != nil {
+				return fmt.Errorf("failed to decode hidden estate %x: %w", k, err)
+			}
+			result = append(result, &saved)
+			return nil
+		})
+	})
+	return result, err
+}
+
+func (s *BoltStorage) saveEstate(bucket []byte, chatID int64, estate *inpars.Estate) error {
+	data, err := json.Marshal(&SavedEstate{ChatID: chatID, Estate: *estate, Saved: time.Now()})
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucket).Put(chatEstateKey(chatID, estate.ID), data)
+	})
+}
+
+func (s *BoltStorage) deleteEstate(bucket []byte, chatID int64, estateID int) (bool, error) {
+	found := false
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		key := chatEstateKey(chatID, estateID)
+		if tx.Bucket(bucket).Get(key) == nil {
+			return nil
+		}
+		found = true
+		return tx.Bucket(bucket).Delete(key)
+	})
+	return found, err
+}
+
+// SaveWatch добавляет или обновляет отслеживаемое объявление чата
+func (s *BoltStorage) SaveWatch(watch *WatchedEstate) error {
+	data, err := json.Marshal(watch)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketWatches).Put(chatEstateKey(watch.ChatID, watch.Estate.ID), data)
+	})
+}
+
+// DeleteWatch прекращает отслеживание объявления
+func (s *BoltStorage) DeleteWatch(chatID int64, estateID int) (bool, error) {
+	return s.deleteEstate(bucketWatches, chatID, estateID)
+}
+
+// Watches возвращает отслеживаемые объявления всех чатов
+func (s *BoltStorage) Watches() ([]*WatchedEstate, error) {
+	var result []*WatchedEstate
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketWatches).ForEach(func(k, v []byte) error {
+			var watch WatchedEstate
+			if err := json.Unmarshal(v, &watch); err != nil {
+				return fmt.Errorf("failed to decode watched estate %x: %w", k, err)
+			}
+			result = append(result, &watch)
+			return nil
+		})
+	})
+	return result, err
+}
+
+// SavePlaces сохраняет точки интереса чата (пустой набор удаляется)
+func (s *BoltStorage) SavePlaces(chatPlaces *places.ChatPlaces) error {
+	key := int64Key(chatPlaces.ChatID)
+	if chatPlaces.IsEmpty() {
+		return s.db.Update(func(tx *bolt.Tx) error {
+			return tx.Bucket(bucketPlaces).Delete(key)
+		})
+	}
+
+	data, err := json.Marshal(chatPlaces)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketPlaces).Put(key, data)
+	})
+}
+
+// Places возвращает точки интереса всех чатов
+func (s *BoltStorage) Places() ([]*places.ChatPlaces, error) {
+	var result []*places.ChatPlaces
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketPlaces).ForEach(func(k, v []byte) error {
+			var chatPlaces places.ChatPlaces
+			if err := json.Unmarshal(v, &chatPlaces); err != nil {
+				return fmt.Errorf("failed to decode places of chat %d: %w", int64(binary.BigEndian.Uint64(k)), err)
+			}
+			result = append(result, &chatPlaces)
+			return nil
+		})
+	})
+	return result, err
+}
+
+// Close закрывает файл базы данных
+func (s *BoltStorage) Close() error {
+	return s.db.Close()
+}
+
+// chatEstateKey ключ объявления чата: объявления одного чата лежат рядом
+func chatEstateKey(chatID int64, estateID int) []byte {
+	return append(int64Key(chatID), int64Key(int64(estateID))...)
+}
+
+// int64Key кодирует число в ключ, сохраняющий порядок сортировки
+func int64Key(n int64) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(n))
+	return key
+}
@@ -0,0 +1,400 @@
+package storage
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/RedNessen/inpars-telegram-bot/internal/inpars"
+	"github.com/RedNessen/inpars-telegram-bot/internal/places"
+	"github.com/RedNessen/inpars-telegram-bot/internal/subscription"
+)
+
+// MemoryStorage хранилище в памяти, состояние теряется при перезапуске.
+// Используется, если путь к файлу базы данных не задан
+type MemoryStorage struct {
+	mu        sync.RWMutex
+	chats     map[int64]bool
+	subs      map[string]*subscription.Subscription
+	cursors   map[string]int
+	seen      map[int]bool
+	tracked   map[int]*TrackedEstate
+	places    map[int64]*places.ChatPlaces
+	settings  map[int64]ChatSettings
+	favorites map[int64]map[int]*SavedEstate
+	hidden    map[int64]map[int]*SavedEstate
+	watches   map[int64]map[int]*WatchedEstate
+	polled    time.Time
+	updated   time.Time
+}
+
+// NewMemory создает пустое хранилище в памяти
+func NewMemory() *MemoryStorage {
+	return &MemoryStorage{
+		chats:     make(map[int64]bool),
+		subs:      make(map[string]*subscription.Subscription),
+		cursors:   make(map[string]int),
+		seen:      make(map[int]bool),
+		tracked:   make(map[int]*TrackedEstate),
+		places:    make(map[int64]*places.ChatPlaces),
+		settings:  make(map[int64]ChatSettings),
+		favorites: make(map[int64]map[int]*SavedEstate),
+		hidden:    make(map[int64]map[int]*SavedEstate),
+		watches:   make(map[int64]map[int]*WatchedEstate),
+	}
+}
+
+// SaveChat сохраняет признак активности чата
+func (s *MemoryStorage) SaveChat(chatID int64, active bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.chats[chatID] = active
+	return nil
+}
+
+// ActiveChats возвращает чаты, получающие уведомления
+func (s *MemoryStorage) ActiveChats() ([]int64, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var chats []int64
+	for chatID, active := range s.chats {
+		if active {
+			chats = append(chats, chatID)
+		}
+	}
+	return chats, nil
+}
+
+// SaveSubscription добавляет или заменяет подписку
+func (s *MemoryStorage) SaveSubscription(sub *subscription.Subscription) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.subs[sub.Key()] = sub.Clone()
+	return nil
+}
+
+// DeleteSubscription удаляет подписку вместе с ее курсором
+func (s *MemoryStorage) DeleteSubscription(chatID int64, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := subscription.KeyOf(chatID, name)
+	delete(s.subs, key)
+	delete(s.cursors, key)
+	return nil
+}
+
+// Subscriptions возвращает все сохраненные подписки
+func (s *MemoryStorage) Subscriptions() ([]*subscription.Subscription, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	subs := make([]*subscription.Subscription, 0, len(s.subs))
+	for _, sub := range s.subs {
+		subs = append(subs, sub.Clone())
+	}
+	return subs, nil
+}
+
+// SaveCursors сохраняет последние обработанные ID подписок
+func (s *MemoryStorage) SaveCursors(cursors map[string]int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, id := range cursors {
+		s.cursors[key] = id
+	}
+	return nil
+}
+
+// Cursors возвращает сохраненные курсоры подписок
+func (s *MemoryStorage) Cursors() (map[string]int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	cursors := make(map[string]int, len(s.cursors))
+	for key, id := range s.cursors {
+		cursors[key] = id
+	}
+	return cursors, nil
+}
+
+// MarkSeen отмечает объявления как обработанные
+func (s *MemoryStorage) MarkSeen(ids []int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range ids {
+		s.seen[id] = true
+	}
+	return nil
+}
+
+// SeenIDs возвращает все обработанные объявления
+func (s *MemoryStorage) SeenIDs() ([]int, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids := make([]int, 0, len(s.seen))
+	for id := range s.seen {
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// PruneSeen удаляет обработанные объявления с ID меньше minID
+func (s *MemoryStorage) PruneSeen(minID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id := range s.seen {
+		if id < minID {
+			delete(s.seen, id)
+		}
+	}
+	return nil
+}
+
+// SaveLastPoll сохраняет время последней успешной проверки объявлений
+func (s *MemoryStorage) SaveLastPoll(t time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.polled = t
+	return nil
+}
+
+// LastPoll возвращает время последней проверки (нулевое, если проверок не было)
+func (s *MemoryStorage) LastPoll() (time.Time, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.polled, nil
+}
+
+// SaveLastUpdatePoll сохраняет время последней проверки изменений объявлений
+func (s *MemoryStorage) SaveLastUpdatePoll(t time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.updated = t
+	return nil
+}
+
+// LastUpdatePoll возвращает время последней проверки изменений
+func (s *MemoryStorage) LastUpdatePoll() (time.Time, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.updated, nil
+}
+
+// SaveTracked сохраняет снимок отправленного объявления
+func (s *MemoryStorage) SaveTracked(tracked *TrackedEstate) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	c := *tracked
+	c.Chats = make(map[int64]string, len(tracked.Chats))
+	for chatID, name := range tracked.Chats {
+		c.Chats[chatID] = name
+	}
+	s.tracked[tracked.Estate.ID] = &c
+	return nil
+}
+
+// Tracked возвращает снимки объявлений с указанными ID
+func (s *MemoryStorage) Tracked(ids []int) (map[int]*TrackedEstate, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make(map[int]*TrackedEstate)
+	for _, id := range ids {
+		if tracked, ok := s.tracked[id]; ok {
+			c := *tracked
+			result[id] = &c
+		}
+	}
+	return result, nil
+}
+
+// PruneTracked удаляет снимки, сохраненные раньше before
+func (s *MemoryStorage) PruneTracked(before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, tracked := range s.tracked {
+		if tracked.Saved.Before(before) {
+			delete(s.tracked, id)
+		}
+	}
+	return nil
+}
+
+// SaveChatSettings сохраняет настройки чата
+func (s *MemoryStorage) SaveChatSettings(chatID int64, settings ChatSettings) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if settings == (ChatSettings{}) {
+		delete(s.settings, chatID)
+		return nil
+	}
+	s.settings[chatID] = settings
+	return nil
+}
+
+// ChatSettings возвращает настройки чатов, отличные от настроек по умолчанию
+func (s *MemoryStorage) ChatSettings() (map[int64]ChatSettings, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make(map[int64]ChatSettings, len(s.settings))
+	for chatID, settings := range s.settings {
+		result[chatID] = settings
+	}
+	return result, nil
+}
+
+// SaveFavorite добавляет объявление в избранное чата
+func (s *MemoryStorage) SaveFavorite(chatID int64, estate *inpars.Estate) error {
+	s.saveEstate(s.favorites, chatID, estate)
+	return nil
+}
+
+// DeleteFavorite удаляет объявление из избранного
+func (s *MemoryStorage) DeleteFavorite(chatID int64, estateID int) (bool, error) {
+	return s.deleteEstate(s.favorites, chatID, estateID), nil
+}
+
+// IsFavorite проверяет, есть ли объявление в избранном чата
+func (s *MemoryStorage) IsFavorite(chatID int64, estateID int) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.favorites[chatID][estateID]
+	return ok, nil
+}
+
+// Favorites возвращает избранное чата, сначала недавно добавленные
+func (s *MemoryStorage) Favorites(chatID int64) ([]*SavedEstate, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]*SavedEstate, 0, len(s.favorites[chatID]))
+	for _, saved := range s.favorites[chatID] {
+		c := *saved
+		result = append(result, &c)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Saved.After(result[j].Saved) })
+	return result, nil
+}
+
+// SaveHidden скрывает объявление и его копии в чате
+func (s *MemoryStorage) SaveHidden(chatID int64, estate *inpars.Estate) error {
+	s.saveEstate(s.hidden, chatID, estate)
+	return nil
+}
+
+// DeleteHidden возвращает скрытое объявление
+func (s *MemoryStorage) DeleteHidden(chatID int64, estateID int) (bool, error) {
+	return s.deleteEstate(s.hidden, chatID, estateID), nil
+}
+
+// Hidden возвращает скрытые объявления всех чатов
+func (s *MemoryStorage) Hidden() ([]*SavedEstate, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []*SavedEstate
+	for _, estates := range s.hidden {
+		for _, saved := range estates {
+			c := *saved
+			result = append(result, &c)
+		}
+	}
+	return result, nil
+}
+
+func (s *MemoryStorage) saveEstate(byChat map[int64]map[int]*SavedEstate, chatID int64, estate *inpars.Estate) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if byChat[chatID] == nil {
+		byChat[chatID] = make(map[int]*SavedEstate)
+	}
+	byChat[chatID][estate.ID] = &SavedEstate{ChatID: chatID, Estate: *estate, Saved: time.Now()}
+}
+
+func (s *MemoryStorage) deleteEstate(byChat map[int64]map[int]*SavedEstate, chatID int64, estateID int) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := byChat[chatID][estateID]; !ok {
+		return false
+	}
+	delete(byChat[chatID], estateID)
+	return true
+}
+
+// SaveWatch добавляет или обновляет отслеживаемое объявление чата
+func (s *MemoryStorage) SaveWatch(watch *WatchedEstate) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.watches[watch.ChatID] == nil {
+		s.watches[watch.ChatID] = make(map[int]*WatchedEstate)
+	}
+	c := *watch
+	s.watches[watch.ChatID][watch.Estate.ID] = &c
+	return nil
+}
+
+// DeleteWatch прекращает отслеживание объявления
+func (s *MemoryStorage) DeleteWatch(chatID int64, estateID int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.watches[chatID][estateID]; !ok {
+		return false, nil
+	}
+	delete(s.watches[chatID], estateID)
+	return true, nil
+}
+
+// Watches возвращает отслеживаемые объявления всех чатов
+func (s *MemoryStorage) Watches() ([]*WatchedEstate, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []*WatchedEstate
+	for _, watches := range s.watches {
+		for _, watch := range watches {
+			c := *watch
+			result = append(result, &c)
+		}
+	}
+	return result, nil
+}
+
+// SavePlaces сохраняет точки интереса чата (пустой набор удаляется)
+func (s *MemoryStorage) SavePlaces(chatPlaces *places.ChatPlaces) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if chatPlaces.IsEmpty() {
+		delete(s.places, chatPlaces.ChatID)
+		return nil
+	}
+	s.places[chatPlaces.ChatID] = chatPlaces.Clone()
+	return nil
+}
+
+// Places возвращает точки интереса всех чатов
+func (s *MemoryStorage) Places() ([]*places.ChatPlaces, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make([]*places.ChatPlaces, 0, len(s.places))
+	for _, chatPlaces := range s.places {
+		result = append(result, chatPlaces.Clone())
+	}
+	return result, nil
+}
+
+// Close ничего не делает для хранилища в памяти
+func (s *MemoryStorage) Close() error {
+	return nil
+}
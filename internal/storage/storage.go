@@ -0,0 +1,123 @@
+package storage
+
+import (
+	"time"
+
+	"github.com/RedNessen/inpars-telegram-bot/internal/inpars"
+	"github.com/RedNessen/inpars-telegram-bot/internal/places"
+	"github.com/RedNessen/inpars-telegram-bot/internal/subscription"
+)
+
+// Storage хранит состояние бота между перезапусками: чаты, подписки,
+// курсоры подписок и уже обработанные объявления
+type Storage interface {
+	// SaveChat сохраняет признак активности чата
+	SaveChat(chatID int64, active bool) error
+	// ActiveChats возвращает чаты, получающие уведомления
+	ActiveChats() ([]int64, error)
+
+	// SaveSubscription добавляет или заменяет подписку
+	SaveSubscription(sub *subscription.Subscription) error
+	// DeleteSubscription удаляет подписку вместе с ее курсором
+	DeleteSubscription(chatID int64, name string) error
+	// Subscriptions возвращает все сохраненные подписки
+	Subscriptions() ([]*subscription.Subscription, error)
+
+	// SaveCursors сохраняет последние обработанные ID подписок (ключ - Subscription.Key)
+	SaveCursors(cursors map[string]int) error
+	// Cursors возвращает сохраненные курсоры подписок
+	Cursors() (map[string]int, error)
+
+	// MarkSeen отмечает объявления как обработанные
+	MarkSeen(ids []int) error
+	// SeenIDs возвращает все обработанные объявления
+	SeenIDs() ([]int, error)
+	// PruneSeen удаляет обработанные объявления с ID меньше minID
+	PruneSeen(minID int) error
+
+	// SaveLastPoll сохраняет время последней успешной проверки объявлений
+	SaveLastPoll(t time.Time) error
+	// LastPoll возвращает время последней проверки (нулевое, если проверок не было)
+	LastPoll() (time.Time, error)
+
+	// SaveLastUpdatePoll сохраняет время последней проверки изменений объявлений
+	SaveLastUpdatePoll(t time.Time) error
+	// LastUpdatePoll возвращает время последней проверки изменений
+	LastUpdatePoll() (time.Time, error)
+
+	// SaveTracked сохраняет снимок отправленного объявления
+	SaveTracked(tracked *TrackedEstate) error
+	// Tracked возвращает снимки объявлений с указанными ID (отсутствующие пропускаются)
+	Tracked(ids []int) (map[int]*TrackedEstate, error)
+	// PruneTracked удаляет снимки, сохраненные раньше before
+	PruneTracked(before time.Time) error
+
+	// SaveChatSettings сохраняет настройки чата
+	SaveChatSettings(chatID int64, settings ChatSettings) error
+	// ChatSettings возвращает настройки чатов, отличные от настроек по умолчанию
+	ChatSettings() (map[int64]ChatSettings, error)
+
+	// SaveFavorite добавляет объявление в избранное чата
+	SaveFavorite(chatID int64, estate *inpars.Estate) error
+	// DeleteFavorite удаляет объявление из избранного. Возвращает false, если его там не было
+	DeleteFavorite(chatID int64, estateID int) (bool, error)
+	// IsFavorite проверяет, есть ли объявление в избранном чата
+	IsFavorite(chatID int64, estateID int) (bool, error)
+	// Favorites возвращает избранное чата, сначала недавно добавленные
+	Favorites(chatID int64) ([]*SavedEstate, error)
+
+	// SaveHidden скрывает объявление и его копии в чате
+	SaveHidden(chatID int64, estate *inpars.Estate) error
+	// DeleteHidden возвращает скрытое объявление. Возвращает false, если оно не было скрыто
+	DeleteHidden(chatID int64, estateID int) (bool, error)
+	// Hidden возвращает скрытые объявления всех чатов
+	Hidden() ([]*SavedEstate, error)
+
+	// SaveWatch добавляет или обновляет отслеживаемое объявление чата
+	SaveWatch(watch *WatchedEstate) error
+	// DeleteWatch прекращает отслеживание. Возвращает false, если объявление не отслеживалось
+	DeleteWatch(chatID int64, estateID int) (bool, error)
+	// Watches возвращает отслеживаемые объявления всех чатов
+	Watches() ([]*WatchedEstate, error)
+
+	// SavePlaces сохраняет точки интереса чата (пустой набор удаляется)
+	SavePlaces(places *places.ChatPlaces) error
+	// Places возвращает точки интереса всех чатов
+	Places() ([]*places.ChatPlaces, error)
+
+	// Close освобождает ресурсы хранилища
+	Close() error
+}
+
+// ChatSettings настройки чата. Нулевое значение - настройки по умолчанию
+type ChatSettings struct {
+	NoPhotos bool `json:"no_photos,omitempty"` // Присылать объявления без фотографий
+}
+
+// SavedEstate объявление, сохраненное чатом в избранное или скрытое
+type SavedEstate struct {
+	ChatID int64         `json:"chat_id"`
+	Estate inpars.Estate `json:"estate"`
+	Saved  time.Time     `json:"saved"`
+}
+
+// WatchedEstate объявление, за которым чат следит командой /watch.
+// Estate - снимок на момент последнего уведомления
+type WatchedEstate struct {
+	ChatID  int64         `json:"chat_id"`
+	Estate  inpars.Estate `json:"estate"`
+	Removed bool          `json:"removed,omitempty"` // Объявление снято с публикации (API вернул 404)
+	Saved   time.Time     `json:"saved"`
+}
+
+// TrackedEstate снимок отправленного объявления для отслеживания его изменений
+type TrackedEstate struct {
+	Estate inpars.Estate    `json:"estate"`
+	Chats  map[int64]string `json:"chats"` // Чаты, получившие объявление, и имя подписки
+	Saved  time.Time        `json:"saved"`
+}
+
+var (
+	_ subscription.Store = (Storage)(nil)
+	_ places.Store       = (Storage)(nil)
+)
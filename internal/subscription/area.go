@@ -0,0 +1,91 @@
+package subscription
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/RedNessen/inpars-telegram-bot/internal/geo"
+	"github.com/RedNessen/inpars-telegram-bot/internal/inpars"
+)
+
+// Area ограничение по местоположению: объявление должно попасть хотя бы
+// в одну из фигур. Объявления без координат ограничение не проходят
+type Area struct {
+	Circles  []geo.Circle  `json:"circles,omitempty"`
+	Polygons []geo.Polygon `json:"polygons,omitempty"`
+}
+
+// IsEmpty возвращает true, если ограничение не задано
+func (a *Area) IsEmpty() bool {
+	return len(a.Circles) == 0 && len(a.Polygons) == 0
+}
+
+// Shapes возвращает все фигуры ограничения
+func (a *Area) Shapes() []geo.Shape {
+	shapes := make([]geo.Shape, 0, len(a.Circles)+len(a.Polygons))
+	for _, c := range a.Circles {
+		shapes = append(shapes, c)
+	}
+	for _, p := range a.Polygons {
+		shapes = append(shapes, p)
+	}
+	return shapes
+}
+
+// Contains проверяет, попадает ли точка хотя бы в одну фигуру
+func (a *Area) Contains(p geo.Point) bool {
+	for _, shape := range a.Shapes() {
+		if shape.Bounds().Contains(p) && shape.Contains(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// Clone возвращает независимую копию ограничения
+func (a Area) Clone() Area {
+	c := Area{}
+	if a.Circles != nil {
+		c.Circles = append([]geo.Circle(nil), a.Circles...)
+	}
+	for _, p := range a.Polygons {
+		rings := make([][]geo.Point, len(p.Rings))
+		for i, ring := range p.Rings {
+			rings[i] = append([]geo.Point(nil), ring...)
+		}
+		c.Polygons = append(c.Polygons, geo.Polygon{Rings: rings})
+	}
+	return c
+}
+
+// Describe возвращает описание ограничения
+func (a *Area) Describe() string {
+	var parts []string
+	for _, c := range a.Circles {
+		parts = append(parts, fmt.Sprintf("%.0f м от %.5f,%.5f", c.Radius, c.Center.Lat, c.Center.Lng))
+	}
+	if n := len(a.Polygons); n > 0 {
+		parts = append(parts, fmt.Sprintf("%d %s", n, pluralRu(n, "полигон", "полигона", "полигонов")))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// PointOf возвращает координаты объявления
+func PointOf(estate *inpars.Estate) geo.Point {
+	return geo.Point{Lat: float64(estate.Lat), Lng: float64(estate.Lng)}
+}
+
+// pluralRu выбирает форму слова для числа n: 1 полигон, 2 полигона, 5 полигонов
+func pluralRu(n int, one, few, many string) string {
+	n %= 100
+	if n >= 11 && n <= 14 {
+		return many
+	}
+	switch n % 10 {
+	case 1:
+		return one
+	case 2, 3, 4:
+		return few
+	}
+	return many
+}
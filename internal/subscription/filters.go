@@ -0,0 +1,208 @@
+package subscription
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/RedNessen/inpars-telegram-bot/internal/inpars"
+)
+
+// Filters ограничения по полям, которые API не умеет фильтровать.
+// Проверяются после получения объявления с расширенными полями (expand).
+// Объявление, в котором нет нужного для фильтра поля, не проходит фильтр
+type Filters struct {
+	Rooms         []int    `json:"rooms,omitempty"`         // Количество комнат (0 - студия)
+	RentTime      int      `json:"rentTime,omitempty"`      // 1-длительно, 2-посуточно
+	CommissionMax *int     `json:"commissionMax,omitempty"` // Максимальная комиссия в % (0 - без комиссии)
+	DepositMax    *int     `json:"depositMax,omitempty"`    // Максимальный залог в ₽ (0 - без залога)
+	Apartments    *bool    `json:"apartments,omitempty"`    // true - только апартаменты, false - без апартаментов
+	RealPhone     bool     `json:"realPhone,omitempty"`     // Только с настоящим (не подменным) номером
+	BuildYearMin  int      `json:"buildYearMin,omitempty"`
+	BuildYearMax  int      `json:"buildYearMax,omitempty"`
+	Materials     []string `json:"materials,omitempty"` // Материал дома, без учета регистра
+	KitchenMin    float64  `json:"kitchenMin,omitempty"`
+	KitchenMax    float64  `json:"kitchenMax,omitempty"`
+	SqPriceMin    int      `json:"sqPriceMin,omitempty"` // Цена за м²
+	SqPriceMax    int      `json:"sqPriceMax,omitempty"`
+}
+
+// IsEmpty возвращает true, если ни одно ограничение не задано
+func (f *Filters) IsEmpty() bool {
+	return len(f.Rooms) == 0 && f.RentTime == 0 && f.CommissionMax == nil && f.DepositMax == nil &&
+		f.Apartments == nil && !f.RealPhone && f.BuildYearMin == 0 && f.BuildYearMax == 0 &&
+		len(f.Materials) == 0 && f.KitchenMin == 0 && f.KitchenMax == 0 &&
+		f.SqPriceMin == 0 && f.SqPriceMax == 0
+}
+
+// Clone возвращает независимую копию фильтров
+func (f Filters) Clone() Filters {
+	c := f
+	c.Rooms = cloneInts(f.Rooms)
+	c.Materials = cloneStrings(f.Materials)
+	if f.CommissionMax != nil {
+		v := *f.CommissionMax
+		c.CommissionMax = &v
+	}
+	if f.DepositMax != nil {
+		v := *f.DepositMax
+		c.DepositMax = &v
+	}
+	if f.Apartments != nil {
+		v := *f.Apartments
+		c.Apartments = &v
+	}
+	return c
+}
+
+// Matches проверяет объявление по ограничениям
+func (f *Filters) Matches(estate *inpars.Estate) bool {
+	if len(f.Rooms) > 0 && !containsOrEmpty(f.Rooms, estate.Rooms) {
+		return false
+	}
+	if f.RentTime > 0 && estate.RentTime != f.RentTime {
+		return false
+	}
+
+	if f.CommissionMax != nil {
+		percent, ok := commissionPercent(estate)
+		if !ok || percent > float64(*f.CommissionMax) {
+			return false
+		}
+	}
+	if f.DepositMax != nil {
+		if estate.RentTerms == nil || estate.RentTerms.Deposit > *f.DepositMax {
+			return false
+		}
+	}
+
+	if f.Apartments != nil && estate.IsApartments != *f.Apartments {
+		return false
+	}
+	if f.RealPhone && estate.PhoneProtected {
+		return false
+	}
+
+	if f.BuildYearMin > 0 || f.BuildYearMax > 0 {
+		if estate.House == nil || estate.House.BuildYear == 0 ||
+			!inIntRange(estate.House.BuildYear, f.BuildYearMin, f.BuildYearMax) {
+			return false
+		}
+	}
+
+	if len(f.Materials) > 0 && !matchesMaterial(f.Materials, estate.Material) {
+		return false
+	}
+
+	if f.KitchenMin > 0 || f.KitchenMax > 0 {
+		if estate.SqKitchen <= 0 || !inFloatRange(estate.SqKitchen, f.KitchenMin, f.KitchenMax) {
+			return false
+		}
+	}
+
+	if f.SqPriceMin > 0 || f.SqPriceMax > 0 {
+		if estate.Sq <= 0 || estate.Cost <= 0 {
+			return false
+		}
+		sqPrice := int(float64(estate.Cost) / estate.Sq)
+		if !inIntRange(sqPrice, f.SqPriceMin, f.SqPriceMax) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Describe возвращает описания заданных ограничений
+func (f *Filters) Describe() []string {
+	var parts []string
+
+	if len(f.Rooms) > 0 {
+		parts = append(parts, "комнат: "+joinInts(f.Rooms))
+	}
+	switch f.RentTime {
+	case 1:
+		parts = append(parts, "аренда: длительно")
+	case 2:
+		parts = append(parts, "аренда: посуточно")
+	}
+	if f.CommissionMax != nil {
+		if *f.CommissionMax == 0 {
+			parts = append(parts, "без комиссии")
+		} else {
+			parts = append(parts, fmt.Sprintf("комиссия: до %d%%", *f.CommissionMax))
+		}
+	}
+	if f.DepositMax != nil {
+		if *f.DepositMax == 0 {
+			parts = append(parts, "без залога")
+		} else {
+			parts = append(parts, fmt.Sprintf("залог: до %d", *f.DepositMax))
+		}
+	}
+	if f.Apartments != nil {
+		if *f.Apartments {
+			parts = append(parts, "только апартаменты")
+		} else {
+			parts = append(parts, "без апартаментов")
+		}
+	}
+	if f.RealPhone {
+		parts = append(parts, "без подменных номеров")
+	}
+	if r := describeRange(f.BuildYearMin, f.BuildYearMax); r != "" {
+		parts = append(parts, "год постройки: "+r)
+	}
+	if len(f.Materials) > 0 {
+		parts = append(parts, "материал: "+strings.Join(f.Materials, ", "))
+	}
+	if f.KitchenMin > 0 || f.KitchenMax > 0 {
+		parts = append(parts, "кухня: "+describeFloatRange(f.KitchenMin, f.KitchenMax))
+	}
+	if r := describeRange(f.SqPriceMin, f.SqPriceMax); r != "" {
+		parts = append(parts, "цена за м²: "+r)
+	}
+
+	return parts
+}
+
+// commissionPercent возвращает комиссию в процентах от цены.
+// Фиксированная сумма пересчитывается относительно стоимости объявления
+func commissionPercent(estate *inpars.Estate) (float64, bool) {
+	terms := estate.RentTerms
+	if terms == nil {
+		return 0, false
+	}
+	if terms.Commission == 0 {
+		return 0, true
+	}
+	if terms.CommissionType == 2 {
+		if estate.Cost <= 0 {
+			return 0, false
+		}
+		return float64(terms.Commission) / float64(estate.Cost) * 100, true
+	}
+	return float64(terms.Commission), true
+}
+
+func matchesMaterial(materials []string, material string) bool {
+	material = strings.ToLower(material)
+	if material == "" {
+		return false
+	}
+	for _, m := range materials {
+		if strings.Contains(material, strings.ToLower(m)) {
+			return true
+		}
+	}
+	return false
+}
+
+func inFloatRange(v, min, max float64) bool {
+	if min > 0 && v < min {
+		return false
+	}
+	if max > 0 && v > max {
+		return false
+	}
+	return true
+}
@@ -0,0 +1,114 @@
+package subscription
+
+import (
+	"testing"
+
+	"github.com/RedNessen/inpars-telegram-bot/internal/inpars"
+)
+
+// testEstate объявление со всеми полями, которые проверяют фильтры
+var testEstate = inpars.Estate{
+	ID:           1,
+	Title:        "2-к квартира, 54 м², 5/9 эт.",
+	Text:         "Сдается на длительный срок. Есть балкон, рядом метро.",
+	Address:      "Москва, ул. Ленина, д. 5",
+	Cost:         50000,
+	Sq:           54,
+	SqKitchen:    9,
+	Rooms:        2,
+	RentTime:     1,
+	Material:     "Кирпичный",
+	IsApartments: false,
+	RentTerms:    &inpars.RentTerms{Commission: 50, CommissionType: 1, Deposit: 50000},
+	House:        &inpars.House{BuildYear: 1975},
+}
+
+func intPtr(v int) *int    { return &v }
+func boolPtr(v bool) *bool { return &v }
+
+func TestFiltersMatches(t *testing.T) {
+	tests := []struct {
+		name    string
+		filters Filters
+		modify  func(e *inpars.Estate)
+		want    bool
+	}{
+		{"без фильтров", Filters{}, nil, true},
+
+		{"комнаты подходят", Filters{Rooms: []int{1, 2}}, nil, true},
+		{"комнаты не подходят", Filters{Rooms: []int{0, 1}}, nil, false},
+		{"студия", Filters{Rooms: []int{0}}, func(e *inpars.Estate) { e.Rooms = 0 }, true},
+
+		{"длительно", Filters{RentTime: 1}, nil, true},
+		{"посуточно", Filters{RentTime: 2}, nil, false},
+
+		{"комиссия в пределах", Filters{CommissionMax: intPtr(50)}, nil, true},
+		{"комиссия выше", Filters{CommissionMax: intPtr(30)}, nil, false},
+		{"без комиссии", Filters{CommissionMax: intPtr(0)}, func(e *inpars.Estate) { e.RentTerms.Commission = 0 }, true},
+		{"фиксированная комиссия в процентах от цены", Filters{CommissionMax: intPtr(50)},
+			func(e *inpars.Estate) { e.RentTerms.Commission, e.RentTerms.CommissionType = 20000, 2 }, true},
+		{"фиксированная комиссия выше", Filters{CommissionMax: intPtr(30)},
+			func(e *inpars.Estate) { e.RentTerms.Commission, e.RentTerms.CommissionType = 20000, 2 }, false},
+		{"условия аренды неизвестны", Filters{CommissionMax: intPtr(100)}, func(e *inpars.Estate) { e.RentTerms = nil }, false},
+
+		{"залог в пределах", Filters{DepositMax: intPtr(50000)}, nil, true},
+		{"залог выше", Filters{DepositMax: intPtr(0)}, nil, false},
+
+		{"без апартаментов", Filters{Apartments: boolPtr(false)}, nil, true},
+		{"только апартаменты", Filters{Apartments: boolPtr(true)}, nil, false},
+
+		{"настоящий номер", Filters{RealPhone: true}, nil, true},
+		{"подменный номер", Filters{RealPhone: true}, func(e *inpars.Estate) { e.PhoneProtected = true }, false},
+
+		{"год постройки в диапазоне", Filters{BuildYearMin: 1970, BuildYearMax: 1980}, nil, true},
+		{"дом старше", Filters{BuildYearMin: 2000}, nil, false},
+		{"год постройки неизвестен", Filters{BuildYearMax: 2020}, func(e *inpars.Estate) { e.House = nil }, false},
+
+		{"материал по части слова без учета регистра", Filters{Materials: []string{"панель", "КИРПИЧ"}}, nil, true},
+		{"другой материал", Filters{Materials: []string{"монолит"}}, nil, false},
+		{"материал не указан", Filters{Materials: []string{"кирпич"}}, func(e *inpars.Estate) { e.Material = "" }, false},
+
+		{"кухня в диапазоне", Filters{KitchenMin: 8, KitchenMax: 12}, nil, true},
+		{"кухня меньше", Filters{KitchenMin: 10}, nil, false},
+		{"площадь кухни не указана", Filters{KitchenMax: 12}, func(e *inpars.Estate) { e.SqKitchen = 0 }, false},
+
+		{"цена за метр в диапазоне", Filters{SqPriceMax: 1000}, nil, true},
+		{"цена за метр выше", Filters{SqPriceMax: 900}, nil, false},
+		{"площадь не указана", Filters{SqPriceMax: 1000}, func(e *inpars.Estate) { e.Sq = 0 }, false},
+
+		{"все фильтры вместе", Filters{Rooms: []int{2}, RentTime: 1, DepositMax: intPtr(60000), KitchenMin: 6, SqPriceMax: 1000}, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			estate := testEstate
+			terms := *testEstate.RentTerms
+			estate.RentTerms = &terms
+			if tt.modify != nil {
+				tt.modify(&estate)
+			}
+			if got := tt.filters.Matches(&estate); got != tt.want {
+				t.Errorf("Matches = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFiltersIsEmptyAndClone(t *testing.T) {
+	if f := (Filters{}); !f.IsEmpty() {
+		t.Errorf("IsEmpty() of zero filters = false")
+	}
+	if f := (Filters{CommissionMax: intPtr(0)}); f.IsEmpty() {
+		t.Errorf("IsEmpty() with «без комиссии» = true")
+	}
+
+	f := Filters{Rooms: []int{1}, Materials: []string{"кирпич"}, DepositMax: intPtr(10), Apartments: boolPtr(true)}
+	c := f.Clone()
+	c.Rooms[0] = 2
+	c.Materials[0] = "панель"
+	*c.DepositMax = 20
+	*c.Apartments = false
+	if f.Rooms[0] != 1 || f.Materials[0] != "кирпич" || *f.DepositMax != 10 || !*f.Apartments {
+		t.Errorf("Clone shares data with the original: %+v", f)
+	}
+}
@@ -0,0 +1,104 @@
+package subscription
+
+import (
+	"strings"
+
+	"github.com/RedNessen/inpars-telegram-bot/internal/inpars"
+	"github.com/RedNessen/inpars-telegram-bot/internal/textmatch"
+)
+
+// Keywords фильтры по ключевым словам и регулярным выражениям в заголовке,
+// описании и адресе объявления. Слова сравниваются без учета регистра, ё/е
+// и окончаний, фраза из нескольких слов должна встретиться целиком
+type Keywords struct {
+	Any          []string `json:"any,omitempty"`          // Хотя бы одна фраза
+	All          []string `json:"all,omitempty"`          // Все фразы
+	Exclude      []string `json:"exclude,omitempty"`      // Ни одной фразы
+	Regex        []string `json:"regex,omitempty"`        // Совпадение каждого выражения
+	ExcludeRegex []string `json:"excludeRegex,omitempty"` // Ни одного совпадения
+
+	matcher *textmatch.Matcher
+}
+
+// IsEmpty возвращает true, если фильтры не заданы
+func (k *Keywords) IsEmpty() bool {
+	return len(k.Any) == 0 && len(k.All) == 0 && len(k.Exclude) == 0 &&
+		len(k.Regex) == 0 && len(k.ExcludeRegex) == 0
+}
+
+// Compile проверяет регулярные выражения и подготавливает фильтры к проверке
+func (k *Keywords) Compile() error {
+	matcher, err := textmatch.Compile(k.rules())
+	if err != nil {
+		return err
+	}
+	k.matcher = matcher
+	return nil
+}
+
+// Clone возвращает независимую копию фильтров. Подготовленные фильтры
+// не изменяются и используются копией совместно
+func (k Keywords) Clone() Keywords {
+	c := k
+	c.Any = cloneStrings(k.Any)
+	c.All = cloneStrings(k.All)
+	c.Exclude = cloneStrings(k.Exclude)
+	c.Regex = cloneStrings(k.Regex)
+	c.ExcludeRegex = cloneStrings(k.ExcludeRegex)
+	return c
+}
+
+// Matches проверяет заголовок, описание и адрес объявления
+func (k *Keywords) Matches(estate *inpars.Estate) bool {
+	if k.IsEmpty() {
+		return true
+	}
+
+	matcher := k.matcher
+	if matcher == nil {
+		// Фильтры не подготовлены (подписка создана в обход Manager)
+		var err error
+		if matcher, err = textmatch.Compile(k.rules()); err != nil {
+			return false
+		}
+	}
+	return matcher.Match(estate.Title, estate.Text, estate.Address)
+}
+
+// Describe возвращает описания заданных фильтров
+func (k *Keywords) Describe() []string {
+	var parts []string
+	if len(k.Any) > 0 {
+		parts = append(parts, "любое из слов: "+strings.Join(k.Any, ", "))
+	}
+	if len(k.All) > 0 {
+		parts = append(parts, "все слова: "+strings.Join(k.All, ", "))
+	}
+	if len(k.Exclude) > 0 {
+		parts = append(parts, "без слов: "+strings.Join(k.Exclude, ", "))
+	}
+	for _, re := range k.Regex {
+		parts = append(parts, "regex: "+re)
+	}
+	for _, re := range k.ExcludeRegex {
+		parts = append(parts, "без regex: "+re)
+	}
+	return parts
+}
+
+func (k *Keywords) rules() textmatch.Rules {
+	return textmatch.Rules{
+		Any:          k.Any,
+		All:          k.All,
+		Exclude:      k.Exclude,
+		Regex:        k.Regex,
+		ExcludeRegex: k.ExcludeRegex,
+	}
+}
+
+func cloneStrings(values []string) []string {
+	if values == nil {
+		return nil
+	}
+	return append([]string(nil), values...)
+}
@@ -0,0 +1,50 @@
+package subscription
+
+import (
+	"testing"
+)
+
+func TestKeywordsMatches(t *testing.T) {
+	tests := []struct {
+		name     string
+		keywords Keywords
+		want     bool
+	}{
+		{"без фильтров", Keywords{}, true},
+		{"слово в описании в другой форме", Keywords{Any: []string{"балконы"}}, true},
+		{"слово в заголовке", Keywords{All: []string{"квартира"}}, true},
+		{"слово в адресе", Keywords{All: []string{"ленина"}}, true},
+		{"нет ни одного слова", Keywords{Any: []string{"лоджия", "терраса"}}, false},
+		{"фраза целиком", Keywords{All: []string{"длительный срок"}}, true},
+		{"фраза не по порядку", Keywords{All: []string{"срок длительный"}}, false},
+		{"исключенное слово", Keywords{Exclude: []string{"метро"}}, false},
+		{"регулярное выражение", Keywords{Regex: []string{`\d+ м²`}}, true},
+		{"исключающее регулярное выражение", Keywords{ExcludeRegex: []string{"посуточн"}}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Фильтры проверяются и без подготовки, и после Compile
+			if got := tt.keywords.Matches(&testEstate); got != tt.want {
+				t.Errorf("Matches without Compile = %v, want %v", got, tt.want)
+			}
+			if err := tt.keywords.Compile(); err != nil {
+				t.Fatalf("Compile error: %v", err)
+			}
+			if got := tt.keywords.Matches(&testEstate); got != tt.want {
+				t.Errorf("Matches = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeywordsInvalidRegex(t *testing.T) {
+	k := Keywords{Regex: []string{"["}}
+	if err := k.Compile(); err == nil {
+		t.Errorf("Compile with invalid regexp succeeded")
+	}
+	// Неподготовленный фильтр с ошибкой ничего не пропускает
+	if k.Matches(&testEstate) {
+		t.Errorf("Matches with invalid regexp = true")
+	}
+}
@@ -0,0 +1,174 @@
+package subscription
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Store постоянное хранилище подписок
+type Store interface {
+	SaveSubscription(sub *Subscription) error
+	DeleteSubscription(chatID int64, name string) error
+	Subscriptions() ([]*Subscription, error)
+}
+
+// Manager хранит подписки всех чатов
+type Manager struct {
+	mu       sync.RWMutex
+	store    Store
+	defaults Subscription
+	byChat   map[int64]map[string]*Subscription
+}
+
+// NewManager создает менеджер подписок с шаблоном подписки по умолчанию
+// и загружает ранее сохраненные подписки из хранилища
+func NewManager(defaults Subscription, store Store) (*Manager, error) {
+	m := &Manager{
+		store:    store,
+		defaults: defaults,
+		byChat:   make(map[int64]map[string]*Subscription),
+	}
+
+	subs, err := store.Subscriptions()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load subscriptions: %w", err)
+	}
+	for _, sub := range subs {
+		m.put(sub)
+	}
+
+	return m, nil
+}
+
+// Defaults возвращает подписку чата из шаблона по умолчанию, не сохраняя ее
+func (m *Manager) Defaults(chatID int64) *Subscription {
+	sub := m.defaults.Clone()
+	sub.ChatID = chatID
+	return sub
+}
+
+// EnsureDefault создает подписку по умолчанию, если у чата еще нет ни одной подписки.
+// Возвращает true, если подписка была создана
+func (m *Manager) EnsureDefault(chatID int64) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.byChat[chatID]) > 0 {
+		return false, nil
+	}
+
+	sub := m.defaults.Clone()
+	sub.ChatID = chatID
+	sub.Created = time.Now()
+	if err := m.store.SaveSubscription(sub); err != nil {
+		return false, fmt.Errorf("failed to save subscription: %w", err)
+	}
+	m.put(sub)
+	return true, nil
+}
+
+// Put добавляет или заменяет подписку чата с тем же именем
+func (m *Manager) Put(sub *Subscription) error {
+	if sub.Name == "" {
+		return fmt.Errorf("subscription name is required")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sub = sub.Clone()
+	if err := sub.Keywords.Compile(); err != nil {
+		return fmt.Errorf("invalid keyword filters: %w", err)
+	}
+	if err := sub.CompileRule(); err != nil {
+		return fmt.Errorf("invalid rule: %w", err)
+	}
+	if sub.Created.IsZero() {
+		sub.Created = time.Now()
+	}
+	if err := m.store.SaveSubscription(sub); err != nil {
+		return fmt.Errorf("failed to save subscription: %w", err)
+	}
+	m.put(sub)
+	return nil
+}
+
+func (m *Manager) put(sub *Subscription) {
+	// Подготавливаем фильтры по тексту и правило заранее, чтобы не разбирать их для каждого
+	// объявления. Ошибка возможна только для подписок, сохраненных в обход Parse:
+	// такая подписка не пропустит ни одного объявления
+	if sub.Keywords.matcher == nil {
+		_ = sub.Keywords.Compile()
+	}
+	if sub.program == nil {
+		_ = sub.CompileRule()
+	}
+
+	subs, ok := m.byChat[sub.ChatID]
+	if !ok {
+		subs = make(map[string]*Subscription)
+		m.byChat[sub.ChatID] = subs
+	}
+	subs[sub.Name] = sub
+}
+
+// Delete удаляет подписку чата по имени. Возвращает false, если подписки не было
+func (m *Manager) Delete(chatID int64, name string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	subs := m.byChat[chatID]
+	if _, ok := subs[name]; !ok {
+		return false, nil
+	}
+	if err := m.store.DeleteSubscription(chatID, name); err != nil {
+		return false, fmt.Errorf("failed to delete subscription: %w", err)
+	}
+	delete(subs, name)
+	if len(subs) == 0 {
+		delete(m.byChat, chatID)
+	}
+	return true, nil
+}
+
+// Get возвращает копию подписки чата по имени
+func (m *Manager) Get(chatID int64, name string) (*Subscription, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	sub, ok := m.byChat[chatID][name]
+	if !ok {
+		return nil, false
+	}
+	return sub.Clone(), true
+}
+
+// ForChat возвращает копии подписок чата, отсортированные по имени
+func (m *Manager) ForChat(chatID int64) []*Subscription {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	result := make([]*Subscription, 0, len(m.byChat[chatID]))
+	for _, sub := range m.byChat[chatID] {
+		result = append(result, sub.Clone())
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Name < result[j].Name })
+	return result
+}
+
+// All возвращает копии всех подписок
+func (m *Manager) All() []*Subscription {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var result []*Subscription
+	for _, subs := range m.byChat {
+		for _, sub := range subs {
+			result = append(result, sub.Clone())
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Key() < result[j].Key() })
+	return result
+}
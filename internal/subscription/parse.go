@@ -0,0 +1,235 @@
+package subscription
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Parse разбирает аргументы команды /sub вида "имя ключ=значение ...".
+// Ключи заменяют соответствующие фильтры base (подписки по умолчанию),
+// остальные фильтры base сохраняются. Если задан регион без города, город
+// и метро base не переносятся: они относятся к другому региону.
+//
+// Поддерживаемые ключи:
+//
+//	region=77,78  city=1  metro=30,31  type=1  seller=1,2
+//	cost=25000-50000  floor=3-  sq=30-60
+//
+// и фильтры по полям, которые API не фильтрует (см. Filters):
+//
+//	rooms=0,1,2  rent=long|daily  commission=0  deposit=50000
+//	apartments=yes|no  phone=real  year=1990-  material=кирпич,монолит
+//	kitchen=8-  sqprice=-300000
+//
+// и фильтры по тексту объявления (см. Keywords):
+//
+//	any=можно_с_животными,питомец  all=балкон  exclude=посуточно,доля
+//	regex=\d+\s*этаж  noregex=только\s+слав
+//
+// Пробел во фразе заменяется на «_». Ключи слов можно повторять, значения
+// добавляются к уже заданным. Регулярное выражение задается целиком, без запятых-разделителей
+//
+// Диапазоны задаются через дефис, любую границу можно опустить.
+// commission задает максимальную комиссию в процентах, deposit - максимальный
+// залог, 0 означает «без комиссии» и «без залога»
+func Parse(base *Subscription, args string) (*Subscription, error) {
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("не указано имя подписки")
+	}
+
+	sub := base.Clone()
+	sub.Name = fields[0]
+	if strings.Contains(sub.Name, "=") {
+		return nil, fmt.Errorf("первым аргументом должно быть имя подписки")
+	}
+
+	keys := make(map[string]bool)
+	for _, field := range fields[1:] {
+		key, value, ok := strings.Cut(field, "=")
+		if !ok || value == "" {
+			return nil, fmt.Errorf("ожидается ключ=значение: %q", field)
+		}
+
+		var err error
+		keys[strings.ToLower(key)] = true
+		switch strings.ToLower(key) {
+		case "region":
+			sub.RegionIDs, err = parseInts(value)
+		case "city":
+			sub.CityIDs, err = parseInts(value)
+		case "metro":
+			sub.MetroIDs, err = parseInts(value)
+		case "type":
+			sub.TypeAd, err = parseInts(value)
+		case "seller":
+			sub.SellerTypes, err = parseInts(value)
+		case "cost":
+			sub.CostMin, sub.CostMax, err = parseIntRange(value)
+		case "floor":
+			sub.FloorMin, sub.FloorMax, err = parseIntRange(value)
+		case "sq":
+			sub.SqMin, sub.SqMax, err = parseFloatRange(value)
+		case "rooms":
+			sub.Filters.Rooms, err = parseInts(value)
+		case "rent":
+			sub.Filters.RentTime, err = parseRentTime(value)
+		case "commission":
+			sub.Filters.CommissionMax, err = parseLimit(value)
+		case "deposit":
+			sub.Filters.DepositMax, err = parseLimit(value)
+		case "apartments":
+			sub.Filters.Apartments, err = parseYesNo(value)
+		case "phone":
+			if strings.ToLower(value) != "real" {
+				err = fmt.Errorf("поддерживается только phone=real")
+			}
+			sub.Filters.RealPhone = true
+		case "year":
+			sub.Filters.BuildYearMin, sub.Filters.BuildYearMax, err = parseIntRange(value)
+		case "material":
+			sub.Filters.Materials = parseWords(value)
+		case "kitchen":
+			sub.Filters.KitchenMin, sub.Filters.KitchenMax, err = parseFloatRange(value)
+		case "sqprice":
+			sub.Filters.SqPriceMin, sub.Filters.SqPriceMax, err = parseIntRange(value)
+		case "any":
+			sub.Keywords.Any = append(sub.Keywords.Any, parsePhrases(value)...)
+		case "all":
+			sub.Keywords.All = append(sub.Keywords.All, parsePhrases(value)...)
+		case "exclude":
+			sub.Keywords.Exclude = append(sub.Keywords.Exclude, parsePhrases(value)...)
+		case "regex":
+			sub.Keywords.Regex = append(sub.Keywords.Regex, value)
+		case "noregex":
+			sub.Keywords.ExcludeRegex = append(sub.Keywords.ExcludeRegex, value)
+		default:
+			err = fmt.Errorf("неизвестный фильтр %q", key)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", key, err)
+		}
+	}
+
+	if keys["region"] && !keys["city"] {
+		sub.CityIDs = nil
+		if !keys["metro"] {
+			sub.MetroIDs = nil
+		}
+	}
+
+	if err := sub.Keywords.Compile(); err != nil {
+		return nil, err
+	}
+
+	return sub, nil
+}
+
+func parseInts(value string) ([]int, error) {
+	var result []int
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return nil, fmt.Errorf("некорректное число %q", part)
+		}
+		result = append(result, n)
+	}
+	return sortedUnique(result), nil
+}
+
+func parseRentTime(value string) (int, error) {
+	switch strings.ToLower(value) {
+	case "long", "1":
+		return 1, nil
+	case "daily", "2":
+		return 2, nil
+	}
+	return 0, fmt.Errorf("ожидается long или daily")
+}
+
+func parseLimit(value string) (*int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return nil, fmt.Errorf("некорректное число %q", value)
+	}
+	return &n, nil
+}
+
+func parseYesNo(value string) (*bool, error) {
+	var v bool
+	switch strings.ToLower(value) {
+	case "yes", "true", "1":
+		v = true
+	case "no", "false", "0":
+		v = false
+	default:
+		return nil, fmt.Errorf("ожидается yes или no")
+	}
+	return &v, nil
+}
+
+func parseWords(value string) []string {
+	var result []string
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			result = append(result, part)
+		}
+	}
+	return result
+}
+
+// parsePhrases разбирает фразы через запятую, «_» внутри фразы заменяется пробелом
+func parsePhrases(value string) []string {
+	words := parseWords(value)
+	for i, word := range words {
+		words[i] = strings.ReplaceAll(word, "_", " ")
+	}
+	return words
+}
+
+func parseIntRange(value string) (int, int, error) {
+	minStr, maxStr, _ := strings.Cut(value, "-")
+
+	var min, max int
+	var err error
+	if minStr != "" {
+		if min, err = strconv.Atoi(minStr); err != nil {
+			return 0, 0, fmt.Errorf("некорректное число %q", minStr)
+		}
+	}
+	if maxStr != "" {
+		if max, err = strconv.Atoi(maxStr); err != nil {
+			return 0, 0, fmt.Errorf("некорректное число %q", maxStr)
+		}
+	}
+	if min > 0 && max > 0 && min > max {
+		return 0, 0, fmt.Errorf("нижняя граница больше верхней")
+	}
+	return min, max, nil
+}
+
+func parseFloatRange(value string) (float64, float64, error) {
+	minStr, maxStr, _ := strings.Cut(strings.ReplaceAll(value, ",", "."), "-")
+
+	var min, max float64
+	var err error
+	if minStr != "" {
+		if min, err = strconv.ParseFloat(minStr, 64); err != nil {
+			return 0, 0, fmt.Errorf("некорректное число %q", minStr)
+		}
+	}
+	if maxStr != "" {
+		if max, err = strconv.ParseFloat(maxStr, 64); err != nil {
+			return 0, 0, fmt.Errorf("некорректное число %q", maxStr)
+		}
+	}
+	if min > 0 && max > 0 && min > max {
+		return 0, 0, fmt.Errorf("нижняя граница больше верхней")
+	}
+	return min, max, nil
+}
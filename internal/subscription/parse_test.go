@@ -0,0 +1,122 @@
+package subscription
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/RedNessen/inpars-telegram-bot/internal/geo"
+)
+
+// testDefaults подписка по умолчанию, на которой строятся подписки /sub
+var testDefaults = Subscription{
+	Name:      DefaultName,
+	RegionIDs: []int{77},
+	CityIDs:   []int{1},
+	MetroIDs:  []int{5},
+	TypeAd:    []int{1},
+	CostMax:   60000,
+	Rule:      "rooms >= 1",
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		args   string
+		modify func(s *Subscription)
+	}{
+		{"flat", func(s *Subscription) {}},
+		{"flat cost=30000-50000", func(s *Subscription) { s.CostMin, s.CostMax = 30000, 50000 }},
+		{"flat cost=-50000 floor=3-", func(s *Subscription) { s.CostMax, s.FloorMin = 50000, 3 }},
+		{"flat sq=30.5-60", func(s *Subscription) { s.SqMin, s.SqMax = 30.5, 60 }},
+		{"flat type=2,1,2 seller=1", func(s *Subscription) { s.TypeAd, s.SellerTypes = []int{1, 2}, []int{1} }},
+		// Регион без города: город и метро другого региона не переносятся
+		{"flat region=78", func(s *Subscription) { s.RegionIDs, s.CityIDs, s.MetroIDs = []int{78}, nil, nil }},
+		{"flat region=78 metro=9", func(s *Subscription) { s.RegionIDs, s.CityIDs, s.MetroIDs = []int{78}, nil, []int{9} }},
+		{"flat region=78 city=2", func(s *Subscription) { s.RegionIDs, s.CityIDs, s.MetroIDs = []int{78}, []int{2}, []int{5} }},
+		{"flat rooms=0,1 rent=daily", func(s *Subscription) { s.Filters.Rooms, s.Filters.RentTime = []int{0, 1}, 2 }},
+		{"flat commission=0 apartments=no", func(s *Subscription) {
+			zero, no := 0, false
+			s.Filters.CommissionMax, s.Filters.Apartments = &zero, &no
+		}},
+		{"flat material=кирпич,монолит kitchen=8-", func(s *Subscription) {
+			s.Filters.Materials, s.Filters.KitchenMin = []string{"кирпич", "монолит"}, 8
+		}},
+		{"flat any=можно_с_животными,питомец any=кот exclude=посуточно", func(s *Subscription) {
+			s.Keywords.Any = []string{"можно с животными", "питомец", "кот"}
+			s.Keywords.Exclude = []string{"посуточно"}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.args, func(t *testing.T) {
+			got, err := Parse(&testDefaults, tt.args)
+			if err != nil {
+				t.Fatalf("Parse(%q) error: %v", tt.args, err)
+			}
+
+			want := testDefaults.Clone()
+			want.Name = "flat"
+			tt.modify(want)
+
+			// Подготовленные фильтры слов не сравниваются
+			got.Keywords.matcher, want.Keywords.matcher = nil, nil
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Parse(%q) =\n%+v\nwant\n%+v", tt.args, *got, *want)
+			}
+		})
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, args := range []string{
+		"",
+		"cost=1-2",
+		"flat cost",
+		"flat cost=abc",
+		"flat rent=weekly",
+		"flat apartments=maybe",
+		"flat phone=fake",
+		"flat color=red",
+		"flat regex=[",
+	} {
+		if _, err := Parse(&testDefaults, args); err == nil {
+			t.Errorf("Parse(%q) succeeded, want error", args)
+		}
+	}
+}
+
+func TestParseDoesNotChangeBase(t *testing.T) {
+	before := testDefaults.Clone()
+	if _, err := Parse(&testDefaults, "flat region=78 any=балкон rooms=2"); err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	if !reflect.DeepEqual(&testDefaults, before) {
+		t.Errorf("Parse changed the base subscription: %+v", testDefaults)
+	}
+}
+
+func TestKeepSettings(t *testing.T) {
+	prev := testDefaults.Clone()
+	prev.Rule = "cost < 50000"
+	prev.Area = Area{Circles: []geo.Circle{{Center: geo.Point{Lat: 55.75, Lng: 37.61}, Radius: 1000}}}
+	prev.Created = time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	sub, err := Parse(&testDefaults, "default cost=-40000")
+	if err != nil {
+		t.Fatalf("Parse error: %v", err)
+	}
+	sub.KeepSettings(prev)
+
+	if sub.Rule != prev.Rule || !reflect.DeepEqual(sub.Area, prev.Area) || !sub.Created.Equal(prev.Created) {
+		t.Errorf("KeepSettings = rule %q, area %+v, created %v", sub.Rule, sub.Area, sub.Created)
+	}
+	if sub.CostMax != 40000 {
+		t.Errorf("KeepSettings changed filters: cost max %d", sub.CostMax)
+	}
+
+	// Район копируется, а не разделяется с прежней подпиской
+	sub.Area.Circles[0].Radius = 500
+	if prev.Area.Circles[0].Radius != 1000 {
+		t.Errorf("KeepSettings shares the area with the previous subscription")
+	}
+}
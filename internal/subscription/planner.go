@@ -0,0 +1,131 @@
+package subscription
+
+import (
+	"github.com/RedNessen/inpars-telegram-bot/internal/inpars"
+)
+
+// Query один запрос к API, покрывающий несколько подписок
+type Query struct {
+	Params        *inpars.EstateListParams
+	Subscriptions []*Subscription
+}
+
+// Plan объединяет подписки в минимальное количество запросов к API.
+//
+// Подписки попадают в один запрос, если у них пересекаются регионы и типы
+// объявлений (пустой фильтр пересекается с любым). Тогда любое объявление
+// может подойти только подпискам одного запроса, а параметры запроса
+// покрывают фильтры всех его подписок. Точная проверка выполняется
+// через Subscription.Matches уже после получения объявлений
+func Plan(subs []*Subscription) []*Query {
+	parent := make([]int, len(subs))
+	for i := range parent {
+		parent[i] = i
+	}
+
+	var find func(int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+		return parent[i]
+	}
+
+	for i := range subs {
+		for j := i + 1; j < len(subs); j++ {
+			if overlaps(subs[i].RegionIDs, subs[j].RegionIDs) && overlaps(subs[i].TypeAd, subs[j].TypeAd) {
+				parent[find(j)] = find(i)
+			}
+		}
+	}
+
+	groups := make(map[int]*Query)
+	var queries []*Query
+	for i, sub := range subs {
+		root := find(i)
+		q, ok := groups[root]
+		if !ok {
+			q = &Query{}
+			groups[root] = q
+			queries = append(queries, q)
+		}
+		q.Subscriptions = append(q.Subscriptions, sub)
+	}
+
+	for _, q := range queries {
+		q.Params = envelope(q.Subscriptions)
+	}
+
+	return queries
+}
+
+// envelope строит параметры запроса, включающие объявления всех подписок
+func envelope(subs []*Subscription) *inpars.EstateListParams {
+	params := subs[0].Params()
+	for _, sub := range subs[1:] {
+		p := sub.Params()
+		params.RegionID = unionOrAll(params.RegionID, p.RegionID)
+		params.CityID = unionOrAll(params.CityID, p.CityID)
+		params.MetroID = unionOrAll(params.MetroID, p.MetroID)
+		params.TypeAd = unionOrAll(params.TypeAd, p.TypeAd)
+		params.SellerType = unionOrAll(params.SellerType, p.SellerType)
+		params.CostMin = lowerBound(params.CostMin, p.CostMin)
+		params.CostMax = upperBound(params.CostMax, p.CostMax)
+		params.FloorMin = lowerBound(params.FloorMin, p.FloorMin)
+		params.FloorMax = upperBound(params.FloorMax, p.FloorMax)
+		params.SqMin = lowerBoundFloat(params.SqMin, p.SqMin)
+		params.SqMax = upperBoundFloat(params.SqMax, p.SqMax)
+	}
+	return params
+}
+
+// overlaps проверяет пересечение фильтров; пустой фильтр означает "любое значение"
+func overlaps(a, b []int) bool {
+	if len(a) == 0 || len(b) == 0 {
+		return true
+	}
+	for _, x := range a {
+		if containsOrEmpty(b, x) {
+			return true
+		}
+	}
+	return false
+}
+
+// unionOrAll объединяет фильтры; если один из них пуст, результат тоже пуст (без ограничения)
+func unionOrAll(a, b []int) []int {
+	if len(a) == 0 || len(b) == 0 {
+		return nil
+	}
+	return sortedUnique(append(append([]int(nil), a...), b...))
+}
+
+// lowerBound возвращает наименьшую нижнюю границу; 0 означает отсутствие границы
+func lowerBound(a, b int) int {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return min(a, b)
+}
+
+// upperBound возвращает наибольшую верхнюю границу; 0 означает отсутствие границы
+func upperBound(a, b int) int {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return max(a, b)
+}
+
+func lowerBoundFloat(a, b float64) float64 {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return min(a, b)
+}
+
+func upperBoundFloat(a, b float64) float64 {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return max(a, b)
+}
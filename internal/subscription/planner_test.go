@@ -0,0 +1,136 @@
+package subscription
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/RedNessen/inpars-telegram-bot/internal/inpars"
+)
+
+// planNames возвращает имена подписок каждого запроса плана
+func planNames(queries []*Query) [][]string {
+	var result [][]string
+	for _, q := range queries {
+		var names []string
+		for _, sub := range q.Subscriptions {
+			names = append(names, sub.Name)
+		}
+		result = append(result, names)
+	}
+	return result
+}
+
+func TestPlanGroups(t *testing.T) {
+	tests := []struct {
+		name string
+		subs []*Subscription
+		want [][]string
+	}{
+		{
+			"разные регионы",
+			[]*Subscription{
+				{Name: "a", RegionIDs: []int{77}},
+				{Name: "b", RegionIDs: []int{78}},
+			},
+			[][]string{{"a"}, {"b"}},
+		},
+		{
+			"общий регион",
+			[]*Subscription{
+				{Name: "a", RegionIDs: []int{77, 50}},
+				{Name: "b", RegionIDs: []int{50}},
+			},
+			[][]string{{"a", "b"}},
+		},
+		{
+			// a и c не пересекаются, но связаны через b
+			"транзитивное объединение",
+			[]*Subscription{
+				{Name: "a", RegionIDs: []int{77}},
+				{Name: "x", RegionIDs: []int{66}},
+				{Name: "c", RegionIDs: []int{78}},
+				{Name: "b", RegionIDs: []int{77, 78}},
+			},
+			[][]string{{"a", "c", "b"}, {"x"}},
+		},
+		{
+			"подписка без региона пересекается со всеми",
+			[]*Subscription{
+				{Name: "a", RegionIDs: []int{77}},
+				{Name: "b", RegionIDs: []int{78}},
+				{Name: "all"},
+			},
+			[][]string{{"a", "b", "all"}},
+		},
+		{
+			"один регион, разные типы объявлений",
+			[]*Subscription{
+				{Name: "rent", RegionIDs: []int{77}, TypeAd: []int{1}},
+				{Name: "sale", RegionIDs: []int{77}, TypeAd: []int{2}},
+				{Name: "any", RegionIDs: []int{78}},
+			},
+			[][]string{{"rent"}, {"sale"}, {"any"}},
+		},
+		{"нет подписок", nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := planNames(Plan(tt.subs)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Plan = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlanEnvelope(t *testing.T) {
+	tests := []struct {
+		name string
+		subs []*Subscription
+		want inpars.EstateListParams
+	}{
+		{
+			"объединение списков и границ",
+			[]*Subscription{
+				{Name: "a", RegionIDs: []int{77}, CityIDs: []int{1}, TypeAd: []int{1}, SellerTypes: []int{1},
+					CostMin: 30000, CostMax: 50000, FloorMin: 2, SqMin: 40, SqMax: 60},
+				{Name: "b", RegionIDs: []int{77, 50}, CityIDs: []int{2}, TypeAd: []int{1}, SellerTypes: []int{2},
+					CostMin: 20000, CostMax: 45000, FloorMin: 3, SqMin: 30, SqMax: 80},
+			},
+			inpars.EstateListParams{
+				RegionID: []int{50, 77}, CityID: []int{1, 2}, TypeAd: []int{1}, SellerType: []int{1, 2},
+				CostMin: 20000, CostMax: 50000, FloorMin: 2, SqMin: 30, SqMax: 80,
+			},
+		},
+		{
+			// Пустой фильтр или граница одной подписки снимает ограничение для всего запроса
+			"подписка без ограничения",
+			[]*Subscription{
+				{Name: "a", RegionIDs: []int{77}, CityIDs: []int{1}, MetroIDs: []int{5}, CostMax: 50000, FloorMin: 2},
+				{Name: "b", RegionIDs: []int{77}, CostMin: 10000},
+			},
+			inpars.EstateListParams{
+				RegionID: []int{77}, SellerType: []int{1, 2, 3}, CostMax: 0, FloorMin: 0,
+			},
+		},
+		{
+			"одна подписка",
+			[]*Subscription{
+				{Name: "a", RegionIDs: []int{78}, MetroIDs: []int{5, 6}, FloorMax: 9},
+			},
+			inpars.EstateListParams{RegionID: []int{78}, MetroID: []int{5, 6}, SellerType: []int{1, 2, 3}, FloorMax: 9},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queries := Plan(tt.subs)
+			if len(queries) != 1 {
+				t.Fatalf("Plan returned %d queries, want 1", len(queries))
+			}
+			if got := *queries[0].Params; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("envelope =\n%+v\nwant\n%+v", got, tt.want)
+			}
+		})
+	}
+}
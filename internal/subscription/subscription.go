@@ -0,0 +1,320 @@
+package subscription
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/RedNessen/inpars-telegram-bot/internal/config"
+	"github.com/RedNessen/inpars-telegram-bot/internal/inpars"
+	"github.com/RedNessen/inpars-telegram-bot/internal/rule"
+)
+
+// DefaultName имя подписки, создаваемой из конфигурации при /start
+const DefaultName = "default"
+
+// Subscription именованный набор фильтров, принадлежащий одному чату
+type Subscription struct {
+	ChatID      int64     `json:"chatId"`
+	Name        string    `json:"name"`
+	RegionIDs   []int     `json:"regionIds,omitempty"`
+	CityIDs     []int     `json:"cityIds,omitempty"`
+	MetroIDs    []int     `json:"metroIds,omitempty"`
+	TypeAd      []int     `json:"typeAd,omitempty"`      // 1-сдам, 2-продам, 3-сниму, 4-куплю
+	SellerTypes []int     `json:"sellerTypes,omitempty"` // 1-собственник, 2-агент, 3-застройщик
+	CostMin     int       `json:"costMin,omitempty"`
+	CostMax     int       `json:"costMax,omitempty"`
+	FloorMin    int       `json:"floorMin,omitempty"`
+	FloorMax    int       `json:"floorMax,omitempty"`
+	SqMin       float64   `json:"sqMin,omitempty"`
+	SqMax       float64   `json:"sqMax,omitempty"`
+	Filters     Filters   `json:"filters"`        // Фильтры по полям, недоступным в запросе к API
+	Keywords    Keywords  `json:"keywords"`       // Фильтры по тексту объявления
+	Rule        string    `json:"rule,omitempty"` // Правило на языке пакета rule
+	Area        Area      `json:"area"`           // Ограничение по местоположению
+	Created     time.Time `json:"created"`
+
+	program *rule.Program
+}
+
+// FromConfig создает шаблон подписки из фильтров по умолчанию в конфигурации
+func FromConfig(cfg *config.Config) Subscription {
+	return Subscription{
+		Name:        DefaultName,
+		RegionIDs:   cfg.DefaultRegions,
+		CityIDs:     cfg.DefaultCities,
+		TypeAd:      cfg.TypeAd,
+		SellerTypes: cfg.SellerTypes,
+		CostMin:     cfg.MinCost,
+		CostMax:     cfg.MaxCost,
+		FloorMin:    cfg.FloorMin,
+		FloorMax:    cfg.FloorMax,
+		Rule:        cfg.DefaultRule,
+	}
+}
+
+// Key возвращает уникальный ключ подписки (чат + имя)
+func (s *Subscription) Key() string {
+	return KeyOf(s.ChatID, s.Name)
+}
+
+// KeyOf возвращает ключ подписки по чату и имени
+func KeyOf(chatID int64, name string) string {
+	return fmt.Sprintf("%d/%s", chatID, name)
+}
+
+// Clone возвращает независимую копию подписки
+func (s *Subscription) Clone() *Subscription {
+	c := *s
+	c.RegionIDs = cloneInts(s.RegionIDs)
+	c.CityIDs = cloneInts(s.CityIDs)
+	c.MetroIDs = cloneInts(s.MetroIDs)
+	c.TypeAd = cloneInts(s.TypeAd)
+	c.SellerTypes = cloneInts(s.SellerTypes)
+	c.Filters = s.Filters.Clone()
+	c.Keywords = s.Keywords.Clone()
+	c.Area = s.Area.Clone()
+	return &c
+}
+
+// KeepSettings переносит из прежней версии подписки то, что задается не
+// фильтрами /sub, а отдельными командами: правило (/rule), район (/near,
+// /area) и дату создания. Вызывается, когда /sub заменяет подписку
+func (s *Subscription) KeepSettings(prev *Subscription) {
+	s.Rule = prev.Rule
+	s.program = prev.program
+	s.Area = prev.Area.Clone()
+	s.Created = prev.Created
+}
+
+// Matches проверяет, подходит ли объявление под фильтры подписки
+func (s *Subscription) Matches(estate *inpars.Estate) bool {
+	return s.MatchesArea(estate) && s.MatchesAttributes(estate)
+}
+
+// MatchesArea проверяет ограничение подписки по местоположению
+func (s *Subscription) MatchesArea(estate *inpars.Estate) bool {
+	if s.Area.IsEmpty() {
+		return true
+	}
+	p := PointOf(estate)
+	return !p.IsZero() && s.Area.Contains(p)
+}
+
+// MatchesAttributes проверяет все фильтры подписки, кроме местоположения.
+// Монитор проверяет местоположение сразу для всех подписок через пространственный индекс
+func (s *Subscription) MatchesAttributes(estate *inpars.Estate) bool {
+	if !containsOrEmpty(s.RegionIDs, estate.RegionID) ||
+		!containsOrEmpty(s.CityIDs, estate.CityID) ||
+		!containsOrEmpty(s.MetroIDs, estate.MetroID) ||
+		!containsOrEmpty(s.TypeAd, estate.TypeAd) ||
+		!containsOrEmpty(s.SellerTypes, SellerTypeOf(estate)) {
+		return false
+	}
+
+	if !inIntRange(estate.Cost, s.CostMin, s.CostMax) ||
+		!inIntRange(estate.Floor, s.FloorMin, s.FloorMax) {
+		return false
+	}
+
+	if s.SqMin > 0 && estate.Sq < s.SqMin {
+		return false
+	}
+	if s.SqMax > 0 && estate.Sq > s.SqMax {
+		return false
+	}
+
+	return s.Filters.Matches(estate) && s.Keywords.Matches(estate) && s.matchesRule(estate)
+}
+
+// CompileRule проверяет правило подписки и подготавливает его к проверке объявлений
+func (s *Subscription) CompileRule() error {
+	if s.Rule == "" {
+		s.program = nil
+		return nil
+	}
+	program, err := rule.Compile(s.Rule)
+	if err != nil {
+		return err
+	}
+	s.program = program
+	return nil
+}
+
+func (s *Subscription) matchesRule(estate *inpars.Estate) bool {
+	if s.Rule == "" {
+		return true
+	}
+
+	program := s.program
+	if program == nil {
+		// Правило не подготовлено (подписка создана в обход Manager)
+		var err error
+		if program, err = rule.Compile(s.Rule); err != nil {
+			return false
+		}
+	}
+	return program.Match(estate)
+}
+
+// Params преобразует подписку в параметры запроса списка объявлений
+func (s *Subscription) Params() *inpars.EstateListParams {
+	return &inpars.EstateListParams{
+		RegionID:   cloneInts(s.RegionIDs),
+		CityID:     cloneInts(s.CityIDs),
+		MetroID:    cloneInts(s.MetroIDs),
+		TypeAd:     cloneInts(s.TypeAd),
+		SellerType: sellerTypesOrAll(s.SellerTypes),
+		CostMin:    s.CostMin,
+		CostMax:    s.CostMax,
+		FloorMin:   s.FloorMin,
+		FloorMax:   s.FloorMax,
+		SqMin:      s.SqMin,
+		SqMax:      s.SqMax,
+	}
+}
+
+// Describe возвращает человекочитаемое описание фильтров подписки
+func (s *Subscription) Describe() string {
+	var parts []string
+
+	if len(s.RegionIDs) > 0 {
+		parts = append(parts, "регионы: "+joinInts(s.RegionIDs))
+	}
+	if len(s.CityIDs) > 0 {
+		parts = append(parts, "города: "+joinInts(s.CityIDs))
+	}
+	if len(s.MetroIDs) > 0 {
+		parts = append(parts, "метро: "+joinInts(s.MetroIDs))
+	}
+	if len(s.TypeAd) > 0 {
+		names := make([]string, 0, len(s.TypeAd))
+		for _, t := range s.TypeAd {
+			names = append(names, inpars.GetTypeAdName(t))
+		}
+		parts = append(parts, "тип: "+strings.Join(names, ", "))
+	}
+	if len(s.SellerTypes) > 0 {
+		names := make([]string, 0, len(s.SellerTypes))
+		for _, t := range s.SellerTypes {
+			names = append(names, inpars.GetSellerTypeName(t-1))
+		}
+		parts = append(parts, "продавец: "+strings.Join(names, ", "))
+	}
+	if r := describeRange(s.CostMin, s.CostMax); r != "" {
+		parts = append(parts, "цена: "+r)
+	}
+	if r := describeRange(s.FloorMin, s.FloorMax); r != "" {
+		parts = append(parts, "этаж: "+r)
+	}
+	if s.SqMin > 0 || s.SqMax > 0 {
+		parts = append(parts, "площадь: "+describeFloatRange(s.SqMin, s.SqMax))
+	}
+	parts = append(parts, s.Filters.Describe()...)
+	parts = append(parts, s.Keywords.Describe()...)
+	if s.Rule != "" {
+		parts = append(parts, "правило: "+s.Rule)
+	}
+	if !s.Area.IsEmpty() {
+		parts = append(parts, "район: "+s.Area.Describe())
+	}
+
+	if len(parts) == 0 {
+		return "без фильтров"
+	}
+	return strings.Join(parts, "; ")
+}
+
+// SellerTypeOf переводит поле agent объявления (0,1,2) в тип продавца фильтра (1,2,3)
+func SellerTypeOf(estate *inpars.Estate) int {
+	return estate.Agent + 1
+}
+
+// sellerTypesOrAll возвращает типы продавцов для запроса.
+// API по умолчанию отдает только собственников, поэтому пустой фильтр раскрывается во все типы
+func sellerTypesOrAll(types []int) []int {
+	if len(types) == 0 {
+		return []int{1, 2, 3}
+	}
+	return cloneInts(types)
+}
+
+func containsOrEmpty(values []int, v int) bool {
+	if len(values) == 0 {
+		return true
+	}
+	for _, x := range values {
+		if x == v {
+			return true
+		}
+	}
+	return false
+}
+
+func inIntRange(v, min, max int) bool {
+	if min > 0 && v < min {
+		return false
+	}
+	if max > 0 && v > max {
+		return false
+	}
+	return true
+}
+
+func describeRange(min, max int) string {
+	switch {
+	case min > 0 && max > 0:
+		return fmt.Sprintf("%d–%d", min, max)
+	case min > 0:
+		return fmt.Sprintf("от %d", min)
+	case max > 0:
+		return fmt.Sprintf("до %d", max)
+	default:
+		return ""
+	}
+}
+
+func describeFloatRange(min, max float64) string {
+	switch {
+	case min > 0 && max > 0:
+		return fmt.Sprintf("%g–%g м²", min, max)
+	case min > 0:
+		return fmt.Sprintf("от %g м²", min)
+	default:
+		return fmt.Sprintf("до %g м²", max)
+	}
+}
+
+func cloneInts(values []int) []int {
+	if values == nil {
+		return nil
+	}
+	return append([]int(nil), values...)
+}
+
+func joinInts(values []int) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = strconv.Itoa(v)
+	}
+	return strings.Join(parts, ",")
+}
+
+// sortedUnique возвращает отсортированный набор уникальных значений
+func sortedUnique(values []int) []int {
+	if len(values) == 0 {
+		return nil
+	}
+	set := make(map[int]bool, len(values))
+	result := make([]int, 0, len(values))
+	for _, v := range values {
+		if !set[v] {
+			set[v] = true
+			result = append(result, v)
+		}
+	}
+	sort.Ints(result)
+	return result
+}
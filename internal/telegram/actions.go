@@ -0,0 +1,283 @@
+package telegram
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/RedNessen/inpars-telegram-bot/internal/dedup"
+	"github.com/RedNessen/inpars-telegram-bot/internal/inpars"
+)
+
+// Префиксы данных кнопок карточки объявления
+const (
+	actionFavorite = "fav"
+	actionHide     = "hide"
+	actionUnhide   = "unhide"
+	actionPhones   = "phones"
+	actionRefresh  = "refresh"
+)
+
+// errCaptionTooLong карточка не помещается в подпись к фото
+var errCaptionTooLong = errors.New("caption too long")
+
+// cardKeyboard возвращает кнопки карточки объявления
+func (b *Bot) cardKeyboard(chatID int64, estate *inpars.Estate) tgbotapi.InlineKeyboardMarkup {
+	id := strconv.Itoa(estate.ID)
+
+	favorite := "⭐ Сохранить"
+	if ok, err := b.store.IsFavorite(chatID, estate.ID); err == nil && ok {
+		favorite = "🌟 В избранном"
+	}
+
+	actions := tgbotapi.NewInlineKeyboardRow(tgbotapi.NewInlineKeyboardButtonData("📞 Телефоны", callbackData(actionPhones, id)))
+	if estate.Lat != 0 || estate.Lng != 0 {
+		actions = append(actions, tgbotapi.NewInlineKeyboardButtonURL("🗺 Карта", mapURL(estate)))
+	}
+	actions = append(actions, tgbotapi.NewInlineKeyboardButtonData("🔄 Обновить", callbackData(actionRefresh, id)))
+
+	return tgbotapi.NewInlineKeyboardMarkup(
+		tgbotapi.NewInlineKeyboardRow(
+			tgbotapi.NewInlineKeyboardButtonData(favorite, callbackData(actionFavorite, id)),
+			tgbotapi.NewInlineKeyboardButtonData("🙈 Скрыть похожие", callbackData(actionHide, id)),
+		),
+		actions,
+	)
+}
+
+// mapURL возвращает ссылку на объявление на Яндекс Картах
+func mapURL(estate *inpars.Estate) string {
+	return fmt.Sprintf("https://yandex.ru/maps/?pt=%.6f,%.6f&z=17&l=map", float64(estate.Lng), float64(estate.Lat))
+}
+
+// onFavorite добавляет объявление в избранное или убирает из него
+func (b *Bot) onFavorite(ctx context.Context, query *tgbotapi.CallbackQuery, arg string) (string, bool) {
+	chatID := query.Message.Chat.ID
+	estate, _, err := b.cardEstate(ctx, chatID, arg, false)
+	if err != nil {
+		log.Printf("Failed to load estate %s for chat %d: %v", arg, chatID, err)
+		return "Не удалось получить объявление", false
+	}
+
+	answer := "⭐ Сохранено в избранное"
+	if deleted, err := b.store.DeleteFavorite(chatID, estate.ID); err != nil {
+		log.Printf("Failed to delete favorite %d of chat %d: %v", estate.ID, chatID, err)
+		return "Не удалось изменить избранное", false
+	} else if deleted {
+		answer = "Удалено из избранного"
+	} else if err := b.store.SaveFavorite(chatID, estate); err != nil {
+		log.Printf("Failed to save favorite %d of chat %d: %v", estate.ID, chatID, err)
+		return "Не удалось сохранить в избранное", false
+	}
+
+	edit := tgbotapi.NewEditMessageReplyMarkup(chatID, query.Message.MessageID, keepSearchRow(query.Message, b.cardKeyboard(chatID, estate)))
+	if _, err := b.api.Request(edit); err != nil && !isNotModified(err) {
+		log.Printf("Failed to update keyboard of message %d in %d: %v", query.Message.MessageID, chatID, err)
+	}
+	return answer, false
+}
+
+// onHide скрывает объявление и его копии с других площадок и сворачивает карточку
+func (b *Bot) onHide(ctx context.Context, query *tgbotapi.CallbackQuery, arg string) (string, bool) {
+	chatID := query.Message.Chat.ID
+	estate, _, err := b.cardEstate(ctx, chatID, arg, false)
+	if err != nil {
+		log.Printf("Failed to load estate %s for chat %d: %v", arg, chatID, err)
+		return "Не удалось получить объявление", false
+	}
+
+	if err := b.store.SaveHidden(chatID, estate); err != nil {
+		log.Printf("Failed to hide estate %d in chat %d: %v", estate.ID, chatID, err)
+		return "Не удалось скрыть объявление", false
+	}
+	b.setHidden(chatID, estate)
+
+	text := fmt.Sprintf("🙈 <b>Скрыто:</b> %s\nЭто объявление и его копии с других площадок больше не придут.", html.EscapeString(estate.Title))
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(tgbotapi.NewInlineKeyboardRow(
+		tgbotapi.NewInlineKeyboardButtonData("↩️ Вернуть", callbackData(actionUnhide, arg)),
+	))
+	if err := b.editCard(query.Message, keepSearchTitle(query.Message, text), keepSearchRow(query.Message, keyboard)); err != nil {
+		log.Printf("Failed to collapse card %d in %d: %v", query.Message.MessageID, chatID, err)
+	}
+	return "Объявление скрыто", false
+}
+
+// onUnhide возвращает скрытое объявление и разворачивает карточку
+func (b *Bot) onUnhide(ctx context.Context, query *tgbotapi.CallbackQuery, arg string) (string, bool) {
+	chatID := query.Message.Chat.ID
+	estate, subName, err := b.cardEstate(ctx, chatID, arg, false)
+	if err != nil {
+		log.Printf("Failed to load estate %s for chat %d: %v", arg, chatID, err)
+		return "Не удалось получить объявление", false
+	}
+
+	if _, err := b.store.DeleteHidden(chatID, estate.ID); err != nil {
+		log.Printf("Failed to unhide estate %d in chat %d: %v", estate.ID, chatID, err)
+		return "Не удалось вернуть объявление", false
+	}
+	b.unsetHidden(chatID, estate.ID)
+
+	if err := b.editCard(query.Message, keepSearchTitle(query.Message, b.cardText(chatID, estate, subName)), keepSearchRow(query.Message, b.cardKeyboard(chatID, estate))); err != nil {
+		log.Printf("Failed to restore card %d in %d: %v", query.Message.MessageID, chatID, err)
+	}
+	return "Объявление возвращено", false
+}
+
+// onPhones показывает все телефоны объявления
+func (b *Bot) onPhones(ctx context.Context, query *tgbotapi.CallbackQuery, arg string) (string, bool) {
+	chatID := query.Message.Chat.ID
+	estate, subName, err := b.cardEstate(ctx, chatID, arg, true)
+	if err != nil {
+		log.Printf("Failed to load estate %s for chat %d: %v", arg, chatID, err)
+		return "Не удалось получить объявление", false
+	}
+
+	phones := formatPhones(estate)
+	if phones == "" {
+		return "Телефоны в объявлении не указаны", true
+	}
+
+	text := b.cardText(chatID, estate, subName) + "\n\n📞 <b>Телефоны:</b>\n" + phones
+	err = b.editCard(query.Message, keepSearchTitle(query.Message, text), keepSearchRow(query.Message, b.cardKeyboard(chatID, estate)))
+	if errors.Is(err, errCaptionTooLong) {
+		// В подпись к фото телефоны не помещаются - показываем их окном
+		return "📞 " + strings.ReplaceAll(phones, "\n", ", "), true
+	}
+	if err != nil {
+		log.Printf("Failed to show phones in card %d in %d: %v", query.Message.MessageID, chatID, err)
+		return "Не удалось показать телефоны", false
+	}
+	return "", false
+}
+
+// onRefresh заново загружает объявление и обновляет карточку
+func (b *Bot) onRefresh(ctx context.Context, query *tgbotapi.CallbackQuery, arg string) (string, bool) {
+	chatID := query.Message.Chat.ID
+	estate, subName, err := b.cardEstate(ctx, chatID, arg, true)
+	if err != nil {
+		log.Printf("Failed to load estate %s for chat %d: %v", arg, chatID, err)
+		return "Не удалось получить объявление", false
+	}
+
+	text := b.cardText(chatID, estate, subName) + fmt.Sprintf("\n🔄 Обновлено %s", time.Now().Format("02.01 15:04"))
+	err = b.editCard(query.Message, keepSearchTitle(query.Message, text), keepSearchRow(query.Message, b.cardKeyboard(chatID, estate)))
+	if errors.Is(err, errCaptionTooLong) {
+		return fmt.Sprintf("Цена: %s", estate.FormatCost()), true
+	}
+	if err != nil {
+		log.Printf("Failed to refresh card %d in %d: %v", query.Message.MessageID, chatID, err)
+		return "Не удалось обновить карточку", false
+	}
+	return "Карточка обновлена", false
+}
+
+// cardEstate возвращает объявление карточки и имя подписки, по которой оно пришло.
+// Если fresh равен false, используется сохраненный снимок объявления, иначе
+// объявление запрашивается из API
+func (b *Bot) cardEstate(ctx context.Context, chatID int64, arg string, fresh bool) (*inpars.Estate, string, error) {
+	id, err := strconv.Atoi(arg)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid estate id %q", arg)
+	}
+
+	subName := ""
+	tracked, err := b.store.Tracked([]int{id})
+	if err != nil {
+		log.Printf("Failed to load tracked estate %d: %v", id, err)
+	}
+	if snapshot, ok := tracked[id]; ok {
+		subName = snapshot.Chats[chatID]
+		if !fresh {
+			return &snapshot.Estate, subName, nil
+		}
+	}
+
+	resp, err := b.client.GetEstateContext(ctx, id, inpars.DefaultExpand...)
+	if err != nil {
+		return nil, "", err
+	}
+	return &resp.Data, subName, nil
+}
+
+// cardText форматирует карточку объявления с подписью подписки
+func (b *Bot) cardText(chatID int64, estate *inpars.Estate, subName string) string {
+	text := b.formatEstateMessage(chatID, estate)
+	if subName != "" {
+		text += fmt.Sprintf("\n🔔 Подписка: %s", html.EscapeString(subName))
+	}
+	return text
+}
+
+// editCard изменяет карточку, кнопку которой нажали: текст сообщения,
+// подпись к фото или подпись к альбому над сообщением с кнопками
+func (b *Bot) editCard(message *tgbotapi.Message, text string, keyboard tgbotapi.InlineKeyboardMarkup) error {
+	return b.editCardMessage(message.Chat.ID, messageCard(message), text, keyboard)
+}
+
+// messageCard возвращает карточку, к которой относится сообщение с кнопками
+func messageCard(message *tgbotapi.Message) dedup.Card {
+	album := message.ReplyToMessage
+	if message.Text == cardActionsText && album != nil && len(album.Photo) > 0 {
+		return dedup.Card{MessageID: album.MessageID, Caption: true, KeyboardID: message.MessageID}
+	}
+	return dedup.Card{MessageID: message.MessageID, Caption: len(message.Photo) > 0}
+}
+
+// editCardMessage изменяет текст или подпись карточки вместе с кнопками.
+// Кнопки карточки-подписи к альбому изменяются в отдельном сообщении
+func (b *Bot) editCardMessage(chatID int64, card dedup.Card, text string, keyboard tgbotapi.InlineKeyboardMarkup) error {
+	var edit tgbotapi.Chattable
+	if card.Caption {
+		if captionLength(text) > maxCaptionLength {
+			return errCaptionTooLong
+		}
+		caption := tgbotapi.NewEditMessageCaption(chatID, card.MessageID, text)
+		caption.ParseMode = "HTML"
+		if card.KeyboardID == 0 {
+			caption.ReplyMarkup = &keyboard
+		}
+		edit = caption
+	} else {
+		textEdit := tgbotapi.NewEditMessageText(chatID, card.MessageID, text)
+		textEdit.ParseMode = "HTML"
+		textEdit.ReplyMarkup = &keyboard
+		edit = textEdit
+	}
+
+	if _, err := b.api.Send(edit); err != nil && !isNotModified(err) {
+		return err
+	}
+	if card.KeyboardID != 0 {
+		markup := tgbotapi.NewEditMessageReplyMarkup(chatID, card.KeyboardID, keyboard)
+		if _, err := b.api.Request(markup); err != nil && !isNotModified(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// formatPhones форматирует все телефоны объявления, по одному в строке
+func formatPhones(estate *inpars.Estate) string {
+	var lines []string
+	for _, phone := range estate.Phones {
+		if phone > 0 {
+			lines = append(lines, fmt.Sprintf("+%d", phone))
+		}
+	}
+	if len(lines) > 0 && estate.PhoneProtected {
+		lines[len(lines)-1] += " (подменный номер)"
+	}
+	return strings.Join(lines, "\n")
+}
+
+// isNotModified возвращает true, если Telegram отклонил изменение, потому что сообщение не изменилось
+func isNotModified(err error) bool {
+	return strings.Contains(err.Error(), "message is not modified")
+}
@@ -0,0 +1,157 @@
+package telegram
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/RedNessen/inpars-telegram-bot/internal/dedup"
+	"github.com/RedNessen/inpars-telegram-bot/internal/inpars"
+	"github.com/RedNessen/inpars-telegram-bot/internal/storage"
+)
+
+func TestMessageCard(t *testing.T) {
+	photo := []tgbotapi.PhotoSize{{FileID: "photo"}}
+	album := &tgbotapi.Message{MessageID: 10, Photo: photo}
+
+	tests := []struct {
+		name    string
+		message *tgbotapi.Message
+		want    dedup.Card
+	}{
+		{
+			"текстовая карточка",
+			&tgbotapi.Message{MessageID: 5, Text: "🏠 Студия"},
+			dedup.Card{MessageID: 5},
+		},
+		{
+			"подпись к одному фото",
+			&tgbotapi.Message{MessageID: 6, Photo: photo, Caption: "🏠 Студия"},
+			dedup.Card{MessageID: 6, Caption: true},
+		},
+		{
+			// Кнопки под альбомом относятся к подписи альбома
+			"кнопки под альбомом",
+			&tgbotapi.Message{MessageID: 11, Text: cardActionsText, ReplyToMessage: album},
+			dedup.Card{MessageID: 10, Caption: true, KeyboardID: 11},
+		},
+		{
+			// Карточка не поместилась в подпись и отправлена ответом на альбом без подписи
+			"карточка ответом на альбом",
+			&tgbotapi.Message{MessageID: 12, Text: "🏠 Студия", ReplyToMessage: album},
+			dedup.Card{MessageID: 12},
+		},
+		{
+			"текст кнопок без альбома",
+			&tgbotapi.Message{MessageID: 13, Text: cardActionsText, ReplyToMessage: &tgbotapi.Message{MessageID: 9}},
+			dedup.Card{MessageID: 13},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := messageCard(tt.message); got != tt.want {
+				t.Errorf("messageCard = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCardKeyboardCallbackData(t *testing.T) {
+	b := &Bot{store: storage.NewMemory()}
+	b.registerCallbacks()
+	estate := &inpars.Estate{ID: 2147483647, Lat: 55.7558, Lng: 37.6173}
+
+	keyboard := b.cardKeyboard(1, estate)
+	buttons := 0
+	for _, row := range keyboard.InlineKeyboard {
+		for _, button := range row {
+			if button.CallbackData == nil {
+				continue
+			}
+			buttons++
+			data := *button.CallbackData
+			// Telegram ограничивает данные кнопки 64 байтами
+			if len(data) > 64 {
+				t.Errorf("callback data %q is longer than 64 bytes", data)
+			}
+			prefix, arg, _ := strings.Cut(data, ":")
+			if _, ok := b.callbacks[prefix]; !ok {
+				t.Errorf("callback data %q has no handler", data)
+			}
+			if arg != "2147483647" {
+				t.Errorf("callback data %q does not carry the estate ID", data)
+			}
+		}
+	}
+	if buttons != 4 {
+		t.Errorf("cardKeyboard has %d callback buttons, want 4", buttons)
+	}
+}
+
+// fakeTelegram запоминает запросы к Bot API и отвечает успехом
+type fakeTelegram struct {
+	requests []url.Values
+}
+
+func (f *fakeTelegram) Do(req *http.Request) (*http.Response, error) {
+	req.ParseForm()
+	f.requests = append(f.requests, req.PostForm)
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       io.NopCloser(strings.NewReader(`{"ok":true,"result":true}`)),
+	}, nil
+}
+
+func TestHandleCallback(t *testing.T) {
+	api := &fakeTelegram{}
+	b := &Bot{api: &tgbotapi.BotAPI{Token: "test", Client: api}}
+	b.api.SetAPIEndpoint(tgbotapi.APIEndpoint)
+
+	var calls []string
+	b.callbacks = map[string]callbackHandler{
+		"fav": func(_ context.Context, _ *tgbotapi.CallbackQuery, arg string) (string, bool) {
+			calls = append(calls, arg)
+			return "⭐ Сохранено в избранное", false
+		},
+	}
+
+	message := &tgbotapi.Message{MessageID: 1, Chat: &tgbotapi.Chat{ID: 1}}
+	tests := []struct {
+		data    string
+		message *tgbotapi.Message
+		answer  string
+		calls   int
+	}{
+		{"fav:123", message, "⭐ Сохранено в избранное", 1},
+		// Аргумент может содержать двоеточие
+		{"fav:n:5:10", message, "⭐ Сохранено в избранное", 2},
+		{"unknown:1", message, "Кнопка больше не действует", 2},
+		{"fav", message, "⭐ Сохранено в избранное", 3},
+		// Сообщение слишком старое, и Telegram его не прислал
+		{"fav:123", nil, "Кнопка больше не действует", 3},
+	}
+
+	for _, tt := range tests {
+		api.requests = nil
+		b.handleCallback(context.Background(), &tgbotapi.CallbackQuery{
+			ID: "q", Data: tt.data, From: &tgbotapi.User{ID: 1}, Message: tt.message,
+		})
+
+		if len(calls) != tt.calls {
+			t.Errorf("handleCallback(%q) called the handler %d times in total, want %d", tt.data, len(calls), tt.calls)
+		}
+		if len(api.requests) != 1 || api.requests[0].Get("text") != tt.answer {
+			t.Errorf("handleCallback(%q) answered %v, want %q", tt.data, api.requests, tt.answer)
+		}
+	}
+
+	if calls[0] != "123" || calls[1] != "n:5:10" || calls[2] != "" {
+		t.Errorf("handler arguments = %q", calls)
+	}
+}
@@ -1,22 +1,48 @@
 package telegram
 
 import (
+	"context"
 	"fmt"
+	"html"
 	"log"
 	"strings"
+	"sync"
+	"time"
 
-	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"github.com/RedNessen/inpars-telegram-bot/internal/dedup"
 	"github.com/RedNessen/inpars-telegram-bot/internal/inpars"
+	"github.com/RedNessen/inpars-telegram-bot/internal/places"
+	"github.com/RedNessen/inpars-telegram-bot/internal/query"
+	"github.com/RedNessen/inpars-telegram-bot/internal/storage"
+	"github.com/RedNessen/inpars-telegram-bot/internal/subscription"
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 )
 
 // Bot представляет Telegram бота
 type Bot struct {
-	api       *tgbotapi.BotAPI
-	chatIDs   map[int64]bool // Список активных чатов
+	api     *tgbotapi.BotAPI
+	client  *inpars.Client
+	ref     *inpars.Reference // Справочники регионов, городов и метро
+	subs    *subscription.Manager
+	places  *places.Manager
+	store   storage.Storage
+	mu      sync.RWMutex
+	chatIDs map[int64]bool // Список активных чатов
+
+	settings    map[int64]storage.ChatSettings      // Настройки чатов, отличные от настроек по умолчанию
+	hidden      map[int64]map[int]dedup.Fingerprint // Скрытые объявления чатов и отпечатки их квартир
+	geoRequests map[int64]*geoRequest               // Незавершенный ввод района по геопозиции
+	wizards     map[int64]*filterWizard             // Незавершенная настройка фильтров /filter
+	refreshes   map[string][]int64                  // Чаты, ожидающие завершения задачи /refresh, по ссылке
+
+	inlineCache  map[string]*inlineAnswer // Ответы на inline-запросы по пользователю, фразе и смещению
+	inlineLatest map[int64]string         // ID последнего inline-запроса пользователя
+
+	callbacks map[string]callbackHandler // Обработчики inline-кнопок по префиксу данных
 }
 
-// NewBot создает новый экземпляр Telegram бота
-func NewBot(token string) (*Bot, error) {
+// NewBot создает новый экземпляр Telegram бота и восстанавливает список активных чатов
+func NewBot(token string, client *inpars.Client, ref *inpars.Reference, subs *subscription.Manager, chatPlaces *places.Manager, store storage.Storage) (*Bot, error) {
 	api, err := tgbotapi.NewBotAPI(token)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create bot: %w", err)
@@ -24,49 +50,156 @@ func NewBot(token string) (*Bot, error) {
 
 	log.Printf("Telegram bot authorized as @%s", api.Self.UserName)
 
-	return &Bot{
+	chats, err := store.ActiveChats()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load chats: %w", err)
+	}
+
+	settings, err := store.ChatSettings()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load chat settings: %w", err)
+	}
+
+	hidden, err := store.Hidden()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load hidden listings: %w", err)
+	}
+
+	b := &Bot{
 		api:     api,
+		client:  client,
+		ref:     ref,
+		subs:    subs,
+		places:  chatPlaces,
+		store:   store,
 		chatIDs: make(map[int64]bool),
-	}, nil
+
+		settings:    settings,
+		hidden:      make(map[int64]map[int]dedup.Fingerprint),
+		geoRequests: make(map[int64]*geoRequest),
+		wizards:     make(map[int64]*filterWizard),
+		refreshes:   make(map[string][]int64),
+
+		inlineCache:  make(map[string]*inlineAnswer),
+		inlineLatest: make(map[int64]string),
+	}
+	for _, chatID := range chats {
+		b.chatIDs[chatID] = true
+	}
+	for _, saved := range hidden {
+		b.setHidden(saved.ChatID, &saved.Estate)
+	}
+	b.registerCallbacks()
+	log.Printf("Restored %d active chats", len(chats))
+
+	return b, nil
 }
 
-// Start запускает бота для обработки сообщений
-func (b *Bot) Start() error {
+// Start запускает бота для обработки сообщений и работает до отмены контекста.
+// Сообщение, которое обрабатывается в момент отмены, обрабатывается до конца
+func (b *Bot) Start(ctx context.Context) error {
 	u := tgbotapi.NewUpdate(0)
 	u.Timeout = 60
 
 	updates := b.api.GetUpdatesChan(u)
-
-	for update := range updates {
-		if update.Message == nil {
-			continue
+	defer b.api.StopReceivingUpdates()
+
+	for {
+		select {
+		case <-ctx.Done():
+			log.Println("Telegram bot stopped")
+			return nil
+		case update, ok := <-updates:
+			if !ok {
+				return nil
+			}
+			switch {
+			case update.CallbackQuery != nil:
+				b.handleCallback(ctx, update.CallbackQuery)
+			case update.InlineQuery != nil:
+				b.handleInlineQuery(ctx, update.InlineQuery)
+			case update.Message != nil:
+				b.handleMessage(ctx, update.Message)
+			}
 		}
-
-		b.handleMessage(update.Message)
 	}
-
-	return nil
 }
 
 // handleMessage обрабатывает входящие сообщения
-func (b *Bot) handleMessage(message *tgbotapi.Message) {
+func (b *Bot) handleMessage(ctx context.Context, message *tgbotapi.Message) {
 	chatID := message.Chat.ID
 	text := message.Text
+	if message.Document != nil {
+		text = message.Caption
+	}
 
 	log.Printf("Received message from %d: %s", chatID, text)
 
 	// Добавляем чат в список активных
-	b.chatIDs[chatID] = true
+	b.setChatActive(chatID, true)
+
+	if message.Location != nil {
+		b.handleLocation(chatID, message.Location)
+		return
+	}
+
+	command, args := splitCommand(text)
+	if !strings.HasPrefix(command, "/") && (b.handleFilterText(ctx, chatID, text) || b.handleGeoRadius(chatID, text)) {
+		return
+	}
 
 	switch {
-	case text == "/start":
+	case command == "/start":
+		if created, err := b.subs.EnsureDefault(chatID); err != nil {
+			log.Printf("Failed to create default subscription for chat %d: %v", chatID, err)
+		} else if created {
+			log.Printf("Created default subscription for chat %d", chatID)
+		}
 		b.sendStartMessage(chatID)
-	case text == "/help":
+	case command == "/help":
 		b.sendHelpMessage(chatID)
-	case text == "/stop":
-		delete(b.chatIDs, chatID)
+	case command == "/stop":
+		b.setChatActive(chatID, false)
 		msg := tgbotapi.NewMessage(chatID, "Уведомления о новых объявлениях остановлены. Используйте /start для возобновления.")
 		b.api.Send(msg)
+	case command == "/subs":
+		b.sendSubscriptions(chatID)
+	case command == "/sub":
+		b.handleSubscribe(ctx, chatID, args)
+	case command == "/unsub":
+		b.handleUnsubscribe(chatID, args)
+	case command == "/filter":
+		b.handleFilter(ctx, chatID, args)
+	case command == "/filters":
+		b.handleFilters(chatID, args)
+	case command == "/search":
+		b.handleSearch(ctx, chatID, args)
+	case command == "/favorites":
+		b.sendFavorites(ctx, chatID, 0, 0)
+	case command == "/unfav":
+		b.handleUnfavorite(chatID, args)
+	case command == "/hidden":
+		b.sendHidden(chatID)
+	case command == "/unhide":
+		b.handleUnhide(chatID, args)
+	case command == "/watch":
+		b.handleWatch(ctx, chatID, args)
+	case command == "/watches":
+		b.sendWatches(chatID, 0)
+	case command == "/photos":
+		b.handlePhotos(chatID, args)
+	case command == "/refresh":
+		b.handleRefresh(ctx, chatID, args)
+	case command == "/rule":
+		b.handleRule(chatID, args)
+	case command == "/near":
+		b.handleNear(chatID, args)
+	case command == "/places":
+		b.handlePlaces(chatID, args)
+	case command == "/place":
+		b.handlePlace(chatID, args)
+	case command == "/area":
+		b.handleArea(ctx, chatID, args, message.Document)
 	default:
 		msg := tgbotapi.NewMessage(chatID, "Используйте /help для просмотра доступных команд.")
 		b.api.Send(msg)
@@ -91,39 +224,201 @@ func (b *Bot) sendHelpMessage(chatID int64) {
 
 /start - Начать получать уведомления
 /stop - Остановить уведомления
+/subs - Список ваших подписок
+/sub имя фильтры - Создать или изменить подписку: ключами или фразой, например /sub центр двушка у метро Сокол до 60 тыс
+/unsub имя - Удалить подписку
+/filter [имя] - Настроить подписку по шагам: регион, город, метро, сделка, комнаты, бюджет, продавец
+/filters [имя] - Изменить отдельные фильтры подписки
+/search [запрос] - Найти объявления сейчас: по фильтрам подписки или по фразе, например /search двушка у метро Сокол до 60 тыс без комиссии
+/rule имя выражение - Задать правило подписки (справка: /rule)
+/near имя [радиус] - Искать рядом с геопозицией
+/area имя - Ограничить подписку полигоном из GeoJSON (файл с подписью) или снять ограничение: /area имя off
+/places - Ваши места (работа, дом родителей): расстояние до них в карточках
+/place имя - Добавить место по геопозиции, /place имя off - удалить
+/favorites - Избранные объявления с текущими ценами
+/unfav ID - Удалить объявление из избранного
+/hidden - Скрытые объявления, /unhide ID - вернуть
+/watch ID|ссылка - Следить за изменениями объявления
+/watches - Отслеживаемые объявления
+/photos on|off - Присылать объявления с фото или только текстом
+/refresh ссылка - Обновить объявление по ссылке (avito, cian, youla, domclick...)
 /help - Показать это сообщение
 
+Фильтры подписки: region=77,78 city=1 metro=30 type=1 seller=1,2 cost=25000-50000 floor=3- sq=30-60
+Дополнительно: rooms=1,2 rent=long|daily commission=0 deposit=50000 apartments=no phone=real year=1990- material=кирпич kitchen=8- sqprice=-300000
+По тексту: any=можно_с_животными all=балкон exclude=посуточно,доля regex=... noregex=...
+Регион, город и метро можно указать названием: region=питер metro=парк_культуры
+
+В любом чате наберите @имя_бота и фразу, например «1к Бутово 40000», чтобы отправить туда карточку объявления.
+
 Бот автоматически мониторит новые объявления и отправляет их вам.`
 
 	msg := tgbotapi.NewMessage(chatID, text)
 	b.api.Send(msg)
 }
 
-// SendEstate отправляет информацию об объявлении всем активным пользователям
-func (b *Bot) SendEstate(estate *inpars.Estate) error {
-	message := b.formatEstateMessage(estate)
+// sendSubscriptions отправляет список подписок чата
+func (b *Bot) sendSubscriptions(chatID int64) {
+	subs := b.subs.ForChat(chatID)
+	if len(subs) == 0 {
+		b.api.Send(tgbotapi.NewMessage(chatID, "У вас нет подписок. Создайте подписку командой /sub или используйте /start."))
+		return
+	}
+
+	var sb strings.Builder
+	sb.WriteString("🔔 Ваши подписки:\n")
+	for _, sub := range subs {
+		sb.WriteString(fmt.Sprintf("\n<b>%s</b>\n%s\n", html.EscapeString(sub.Name), html.EscapeString(sub.Describe())))
+	}
 
-	for chatID := range b.chatIDs {
-		msg := tgbotapi.NewMessage(chatID, message)
-		msg.ParseMode = "HTML"
-		msg.DisableWebPagePreview = false
+	msg := tgbotapi.NewMessage(chatID, sb.String())
+	msg.ParseMode = "HTML"
+	b.api.Send(msg)
+}
 
-		_, err := b.api.Send(msg)
+// handleSubscribe создает или заменяет подписку чата. Фильтры задаются
+// ключами (region=77 cost=-60000) или фразой (двушка у метро Сокол до 60 тыс)
+// и дополняют фильтры по умолчанию. Правило и район прежней подписки
+// с тем же именем сохраняются
+func (b *Bot) handleSubscribe(ctx context.Context, chatID int64, args string) {
+	name, phrase, _ := strings.Cut(strings.TrimSpace(args), " ")
+	base := b.subs.Defaults(chatID)
+	var sub *subscription.Subscription
+	var err error
+	if phrase != "" && !strings.Contains(phrase, "=") {
+		sub, err = b.phraseSubscription(ctx, base, name, phrase)
 		if err != nil {
-			log.Printf("Failed to send message to %d: %v", chatID, err)
-			// Если пользователь заблокировал бота, удаляем его из списка
-			if strings.Contains(err.Error(), "blocked") || strings.Contains(err.Error(), "forbidden") {
-				delete(b.chatIDs, chatID)
-			}
-			continue
+			b.api.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("%v\n\nПример: /sub центр двушка у метро Сокол до 60 тыс без комиссии", err)))
+			return
 		}
+	} else if args, err = b.resolveNames(ctx, chatID, args); err != nil {
+		b.api.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Не удалось разобрать подписку: %v", err)))
+		return
+	} else if sub, err = subscription.Parse(base, args); err != nil {
+		b.api.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Не удалось разобрать подписку: %v\n\nПример: /sub центр region=77 type=1 cost=30000-60000 floor=2-\nили: /sub центр двушка у метро Сокол до 60 тыс", err)))
+		return
+	}
+
+	if existing, ok := b.subs.Get(chatID, sub.Name); ok {
+		sub.KeepSettings(existing)
+	}
+
+	if err := b.subs.Put(sub); err != nil {
+		log.Printf("Failed to save subscription %s: %v", sub.Key(), err)
+		b.api.Send(tgbotapi.NewMessage(chatID, "Не удалось сохранить подписку."))
+		return
+	}
+
+	log.Printf("Saved subscription %s: %s", sub.Key(), sub.Describe())
+	b.api.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("✅ Подписка «%s» сохранена: %s", sub.Name, sub.Describe())))
+}
+
+// phraseSubscription создает подписку из фразы. Регион, город и фильтры,
+// которые фраза не задает, берутся из base
+func (b *Bot) phraseSubscription(ctx context.Context, base *subscription.Subscription, name, phrase string) (*subscription.Subscription, error) {
+	sub := base.Clone()
+	sub.Name = name
+
+	q, err := query.Parse(ctx, phrase, b.metroFinder(sub))
+	if err != nil {
+		return nil, err
+	}
+	q.Apply(sub)
+	return sub, nil
+}
+
+// handleUnsubscribe удаляет подписку чата
+func (b *Bot) handleUnsubscribe(chatID int64, args string) {
+	name := strings.TrimSpace(args)
+	if name == "" {
+		b.api.Send(tgbotapi.NewMessage(chatID, "Укажите имя подписки: /unsub имя"))
+		return
+	}
+
+	deleted, err := b.subs.Delete(chatID, name)
+	if err != nil {
+		log.Printf("Failed to delete subscription %d/%s: %v", chatID, name, err)
+		b.api.Send(tgbotapi.NewMessage(chatID, "Не удалось удалить подписку."))
+		return
+	}
+	if !deleted {
+		b.api.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Подписка «%s» не найдена. Список подписок: /subs", name)))
+		return
+	}
+
+	log.Printf("Deleted subscription %d/%s", chatID, name)
+	b.api.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("🗑 Подписка «%s» удалена.", name)))
+}
+
+// SendEstate отправляет информацию об объявлении в чат, подписка которого совпала
+func (b *Bot) SendEstate(chatID int64, estate *inpars.Estate, subName string) error {
+	message := b.formatEstateMessage(chatID, estate)
+	if subName != "" {
+		message += fmt.Sprintf("\n🔔 Подписка: %s", html.EscapeString(subName))
+	}
+
+	_, err := b.sendCard(chatID, estate, message)
+	return err
+}
+
+// sendMessage отправляет подготовленное сообщение и возвращает его ID.
+// Если пользователь заблокировал бота, чат отключается
+func (b *Bot) sendMessage(msg tgbotapi.MessageConfig) (int, error) {
+	sent, err := b.api.Send(msg)
+	if err != nil {
+		b.handleSendError(msg.ChatID, err)
+		return 0, fmt.Errorf("failed to send message to %d: %w", msg.ChatID, err)
+	}
+
+	return sent.MessageID, nil
+}
+
+// handleSendError отключает чат, если пользователь заблокировал бота
+func (b *Bot) handleSendError(chatID int64, err error) {
+	if isBlocked(err) {
+		b.setChatActive(chatID, false)
+	}
+}
+
+// isBlocked возвращает true, если отправка не удалась из-за блокировки бота пользователем
+func isBlocked(err error) bool {
+	return strings.Contains(err.Error(), "blocked") || strings.Contains(err.Error(), "forbidden")
+}
+
+// SendCatchUpSummary сообщает чату, сколько объявлений появилось, пока бот был недоступен
+func (b *Bot) SendCatchUpSummary(chatID int64, missed, shown int, since time.Time, complete bool) error {
+	var sb strings.Builder
+
+	// После «более» существительное стоит в родительном падеже: более 21 объявления
+	count := fmt.Sprintf("%d %s", missed, plural(missed, "объявление", "объявления", "объявлений"))
+	if !complete {
+		count = fmt.Sprintf("более %d %s", missed, plural(missed, "объявления", "объявлений", "объявлений"))
+	}
+
+	sb.WriteString("⏰ Пока бот был недоступен")
+	if !since.IsZero() {
+		sb.WriteString(fmt.Sprintf(" (с %s)", since.Format("02.01.2006 15:04")))
 	}
+	sb.WriteString(fmt.Sprintf(", вы пропустили %s по вашим подпискам.", count))
 
+	switch {
+	case shown == 0:
+		sb.WriteString("\n\nОтправка пропущенных объявлений отключена.")
+	case shown < missed || !complete:
+		sb.WriteString(fmt.Sprintf("\n\nОтправляю %d %s.",
+			shown, plural(shown, "самое свежее", "самых свежих", "самых свежих")))
+	}
+
+	_, err := b.api.Send(tgbotapi.NewMessage(chatID, sb.String()))
+	if err != nil {
+		return fmt.Errorf("failed to send message to %d: %w", chatID, err)
+	}
 	return nil
 }
 
-// formatEstateMessage форматирует информацию об объявлении для Telegram
-func (b *Bot) formatEstateMessage(estate *inpars.Estate) string {
+// formatEstateMessage форматирует информацию об объявлении для Telegram.
+// Расстояния считаются до мест чата chatID
+func (b *Bot) formatEstateMessage(chatID int64, estate *inpars.Estate) string {
 	var sb strings.Builder
 
 	// Заголовок
@@ -143,7 +438,19 @@ func (b *Bot) formatEstateMessage(estate *inpars.Estate) string {
 	} else if estate.TypeAd == 1 {
 		sb.WriteString(" / месяц")
 	}
-	sb.WriteString("\n\n")
+	sb.WriteString("\n")
+
+	// Изменение цены по истории
+	if prev, ok := estate.PreviousCost(); ok && estate.Cost > 0 {
+		sb.WriteString(formatPriceChange(prev, estate.Cost))
+		sb.WriteString("\n")
+	}
+
+	// Срок экспозиции
+	if since, err := estate.ListedSince(); err == nil {
+		sb.WriteString(fmt.Sprintf("⏳ На рынке: %s\n", formatDuration(time.Since(since))))
+	}
+	sb.WriteString("\n")
 
 	// Адрес
 	if estate.Address != "" {
@@ -155,6 +462,9 @@ func (b *Bot) formatEstateMessage(estate *inpars.Estate) string {
 		sb.WriteString(fmt.Sprintf("🚇 %s\n", estate.Metro))
 	}
 
+	// Расстояния до мест чата
+	sb.WriteString(b.formatPlaces(chatID, estate))
+
 	// Характеристики
 	sb.WriteString("\n<b>Характеристики:</b>\n")
 
@@ -220,6 +530,52 @@ func (b *Bot) formatEstateMessage(estate *inpars.Estate) string {
 	return sb.String()
 }
 
+// formatPriceChange форматирует изменение цены: "было 52 000 ₽ → сейчас 45 000 ₽, −13%"
+func formatPriceChange(prev, cur int) string {
+	icon := "📉"
+	if cur > prev {
+		icon = "📈"
+	}
+
+	percent := float64(cur-prev) / float64(prev) * 100
+	sign := "+"
+	if percent < 0 {
+		sign = "−"
+		percent = -percent
+	}
+
+	return fmt.Sprintf("%s было %s → сейчас %s, %s%.0f%%", icon, formatPrice(prev), formatPrice(cur), sign, percent)
+}
+
+// formatDuration форматирует срок экспозиции в днях или часах
+func formatDuration(d time.Duration) string {
+	if d < 24*time.Hour {
+		hours := max(int(d.Hours()), 0)
+		if hours == 0 {
+			return "меньше часа"
+		}
+		return fmt.Sprintf("%d %s", hours, plural(hours, "час", "часа", "часов"))
+	}
+	days := int(d.Hours() / 24)
+	return fmt.Sprintf("%d %s", days, plural(days, "день", "дня", "дней"))
+}
+
+// plural выбирает форму слова для числа n: 1 день, 2 дня, 5 дней
+func plural(n int, one, few, many string) string {
+	n %= 100
+	if n >= 11 && n <= 14 {
+		return many
+	}
+	switch n % 10 {
+	case 1:
+		return one
+	case 2, 3, 4:
+		return few
+	default:
+		return many
+	}
+}
+
 // formatPrice форматирует цену с разделителями тысяч
 func formatPrice(price int) string {
 	if price == 0 {
@@ -238,8 +594,45 @@ func formatPrice(price int) string {
 	return str + " ₽"
 }
 
+// splitCommand отделяет команду от аргументов и убирает упоминание бота (/cmd@bot)
+func splitCommand(text string) (string, string) {
+	command, args, _ := strings.Cut(strings.TrimSpace(text), " ")
+	command, _, _ = strings.Cut(command, "@")
+	return command, strings.TrimSpace(args)
+}
+
+// setChatActive добавляет чат в список активных или удаляет из него
+func (b *Bot) setChatActive(chatID int64, active bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.chatIDs[chatID] == active {
+		return
+	}
+
+	if active {
+		b.chatIDs[chatID] = true
+	} else {
+		delete(b.chatIDs, chatID)
+	}
+
+	if err := b.store.SaveChat(chatID, active); err != nil {
+		log.Printf("Failed to save chat %d: %v", chatID, err)
+	}
+}
+
+// IsChatActive проверяет, получает ли чат уведомления
+func (b *Bot) IsChatActive(chatID int64) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.chatIDs[chatID]
+}
+
 // GetActiveChatIDs возвращает список активных chat ID
 func (b *Bot) GetActiveChatIDs() []int64 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
 	chatIDs := make([]int64, 0, len(b.chatIDs))
 	for id := range b.chatIDs {
 		chatIDs = append(chatIDs, id)
@@ -249,5 +642,7 @@ func (b *Bot) GetActiveChatIDs() []int64 {
 
 // HasActiveChats проверяет, есть ли активные чаты
 func (b *Bot) HasActiveChats() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
 	return len(b.chatIDs) > 0
 }
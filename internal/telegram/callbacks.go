@@ -0,0 +1,51 @@
+package telegram
+
+import (
+	"context"
+	"log"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+// callbackHandler обрабатывает нажатие inline-кнопки; arg - данные кнопки после
+// префикса. Возвращает текст ответа на нажатие, alert показывает его окном
+type callbackHandler func(ctx context.Context, query *tgbotapi.CallbackQuery, arg string) (answer string, alert bool)
+
+// registerCallbacks связывает префиксы данных кнопок с обработчиками
+func (b *Bot) registerCallbacks() {
+	b.callbacks = map[string]callbackHandler{
+		actionFavorite: b.onFavorite,
+		actionHide:     b.onHide,
+		actionUnhide:   b.onUnhide,
+		actionPhones:   b.onPhones,
+		actionRefresh:  b.onRefresh,
+
+		actionFavoritesPage: b.onFavoritesPage,
+		actionUnwatch:       b.onUnwatch,
+		actionFilter:        b.onFilter,
+		actionSearch:        b.onSearch,
+	}
+}
+
+// callbackData формирует данные кнопки "префикс:аргумент". Telegram ограничивает их 64 байтами
+func callbackData(prefix, arg string) string {
+	return prefix + ":" + arg
+}
+
+// handleCallback находит обработчик по префиксу данных кнопки и отвечает на нажатие
+func (b *Bot) handleCallback(ctx context.Context, query *tgbotapi.CallbackQuery) {
+	log.Printf("Received callback from %d: %s", query.From.ID, query.Data)
+
+	answer, alert := "Кнопка больше не действует", false
+	prefix, arg, _ := strings.Cut(query.Data, ":")
+	if handler, ok := b.callbacks[prefix]; ok && query.Message != nil {
+		answer, alert = handler(ctx, query, arg)
+	}
+
+	callback := tgbotapi.NewCallback(query.ID, answer)
+	callback.ShowAlert = alert
+	if _, err := b.api.Request(callback); err != nil {
+		log.Printf("Failed to answer callback %s: %v", query.ID, err)
+	}
+}
@@ -0,0 +1,85 @@
+package telegram
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/RedNessen/inpars-telegram-bot/internal/dedup"
+)
+
+// SendEstateGroup отправляет одну карточку на группу копий объявления
+// и возвращает ее, чтобы дополнять новыми источниками
+func (b *Bot) SendEstateGroup(chatID int64, group *dedup.Group, subName string) (dedup.Card, error) {
+	card, err := b.sendCard(chatID, &group.Estate, b.formatGroupMessage(chatID, group, subName))
+	card.SubName = subName
+	return card, err
+}
+
+// UpdateEstateGroup обновляет отправленную карточку после появления новой копии
+// объявления. Если обновленная карточка больше не помещается в подпись к фото,
+// она отправляется отдельным сообщением; возвращается актуальная карточка
+func (b *Bot) UpdateEstateGroup(chatID int64, card dedup.Card, group *dedup.Group) (dedup.Card, error) {
+	text := b.formatGroupMessage(chatID, group, card.SubName)
+	keyboard := b.cardKeyboard(chatID, &group.Estate)
+
+	err := b.editCardMessage(chatID, card, text, keyboard)
+	if !errors.Is(err, errCaptionTooLong) {
+		if err != nil {
+			return card, fmt.Errorf("failed to edit message %d in %d: %w", card.MessageID, chatID, err)
+		}
+		return card, nil
+	}
+
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ParseMode = "HTML"
+	msg.DisableWebPagePreview = true
+	msg.ReplyToMessageID = card.MessageID
+	msg.ReplyMarkup = keyboard
+	messageID, err := b.sendMessage(msg)
+	if err != nil {
+		return card, err
+	}
+	if card.KeyboardID != 0 {
+		// Кнопки теперь у новой карточки
+		if _, err := b.api.Request(tgbotapi.NewDeleteMessage(chatID, card.KeyboardID)); err != nil {
+			log.Printf("Failed to delete actions message %d in %d: %v", card.KeyboardID, chatID, err)
+		}
+	}
+	return dedup.Card{MessageID: messageID, SubName: card.SubName}, nil
+}
+
+// formatGroupMessage форматирует карточку группы: первое объявление и,
+// если копий несколько, ссылки на все источники
+func (b *Bot) formatGroupMessage(chatID int64, group *dedup.Group, subName string) string {
+	var sb strings.Builder
+	sb.WriteString(b.formatEstateMessage(chatID, &group.Estate))
+
+	if len(group.Sources) > 1 {
+		sb.WriteString(fmt.Sprintf("\n\n🔁 Найдено на %d площадках:", len(group.Sources)))
+		for _, source := range group.Sources {
+			name := source.Source
+			if name == "" {
+				name = fmt.Sprintf("#%d", source.EstateID)
+			}
+			sb.WriteString("\n• ")
+			if source.URL != "" {
+				sb.WriteString(fmt.Sprintf("<a href=\"%s\">%s</a>", source.URL, html.EscapeString(name)))
+			} else {
+				sb.WriteString(html.EscapeString(name))
+			}
+			if source.Cost != group.Estate.Cost && source.Cost > 0 {
+				sb.WriteString(fmt.Sprintf(" — %s", formatPrice(source.Cost)))
+			}
+		}
+	}
+
+	if subName != "" {
+		sb.WriteString(fmt.Sprintf("\n🔔 Подписка: %s", html.EscapeString(subName)))
+	}
+	return sb.String()
+}
@@ -0,0 +1,285 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/RedNessen/inpars-telegram-bot/internal/dedup"
+	"github.com/RedNessen/inpars-telegram-bot/internal/inpars"
+	"github.com/RedNessen/inpars-telegram-bot/internal/storage"
+)
+
+const (
+	// actionFavoritesPage префикс кнопок листания избранного
+	actionFavoritesPage = "favpage"
+	// favoritesPageSize количество объявлений на странице избранного
+	favoritesPageSize = 5
+)
+
+// sendFavorites отправляет страницу избранного. Если messageID не равен нулю,
+// сообщение со списком изменяется на месте
+func (b *Bot) sendFavorites(ctx context.Context, chatID int64, page int, messageID int) {
+	favorites, err := b.store.Favorites(chatID)
+	if err != nil {
+		log.Printf("Failed to load favorites of chat %d: %v", chatID, err)
+		b.api.Send(tgbotapi.NewMessage(chatID, "Не удалось загрузить избранное."))
+		return
+	}
+	if len(favorites) == 0 {
+		text := "В избранном пока ничего нет. Нажмите ⭐ Сохранить под объявлением, чтобы добавить его."
+		if messageID != 0 {
+			b.api.Send(tgbotapi.NewEditMessageText(chatID, messageID, text))
+		} else {
+			b.api.Send(tgbotapi.NewMessage(chatID, text))
+		}
+		return
+	}
+
+	pages := (len(favorites) + favoritesPageSize - 1) / favoritesPageSize
+	page = min(max(page, 0), pages-1)
+	items := favorites[page*favoritesPageSize : min((page+1)*favoritesPageSize, len(favorites))]
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("⭐ <b>Избранное</b> (%d)", len(favorites)))
+	if pages > 1 {
+		sb.WriteString(fmt.Sprintf(", страница %d из %d", page+1, pages))
+	}
+	sb.WriteString("\n")
+	for i, saved := range items {
+		sb.WriteString("\n")
+		sb.WriteString(b.formatFavorite(ctx, page*favoritesPageSize+i+1, saved))
+	}
+
+	var keyboard *tgbotapi.InlineKeyboardMarkup
+	if pages > 1 {
+		var row []tgbotapi.InlineKeyboardButton
+		if page > 0 {
+			row = append(row, tgbotapi.NewInlineKeyboardButtonData("◀️", callbackData(actionFavoritesPage, strconv.Itoa(page-1))))
+		}
+		if page < pages-1 {
+			row = append(row, tgbotapi.NewInlineKeyboardButtonData("▶️", callbackData(actionFavoritesPage, strconv.Itoa(page+1))))
+		}
+		markup := tgbotapi.NewInlineKeyboardMarkup(row)
+		keyboard = &markup
+	}
+
+	if messageID != 0 {
+		edit := tgbotapi.NewEditMessageText(chatID, messageID, sb.String())
+		edit.ParseMode = "HTML"
+		edit.DisableWebPagePreview = true
+		edit.ReplyMarkup = keyboard
+		if _, err := b.api.Send(edit); err != nil && !isNotModified(err) {
+			log.Printf("Failed to edit favorites of chat %d: %v", chatID, err)
+		}
+		return
+	}
+
+	msg := tgbotapi.NewMessage(chatID, sb.String())
+	msg.ParseMode = "HTML"
+	msg.DisableWebPagePreview = true
+	if keyboard != nil {
+		msg.ReplyMarkup = *keyboard
+	}
+	if _, err := b.sendMessage(msg); err != nil {
+		log.Printf("Failed to send favorites: %v", err)
+	}
+}
+
+// formatFavorite форматирует объявление из избранного с текущей ценой из API
+func (b *Bot) formatFavorite(ctx context.Context, n int, saved *storage.SavedEstate) string {
+	estate := &saved.Estate
+
+	var sb strings.Builder
+	title := html.EscapeString(estate.Title)
+	if estate.URL != "" {
+		title = fmt.Sprintf("<a href=\"%s\">%s</a>", estate.URL, title)
+	}
+	sb.WriteString(fmt.Sprintf("%d. %s\n", n, title))
+
+	resp, err := b.client.GetEstateContext(ctx, estate.ID)
+	switch {
+	case inpars.IsNotFound(err):
+		sb.WriteString(fmt.Sprintf("❌ Снято с публикации, последняя цена %s\n", estate.FormatCost()))
+	case err != nil:
+		log.Printf("Failed to get favorite estate %d: %v", estate.ID, err)
+		sb.WriteString(fmt.Sprintf("💰 %s (не удалось узнать текущую цену)\n", estate.FormatCost()))
+	case resp.Data.Cost != estate.Cost && estate.Cost > 0 && resp.Data.Cost > 0:
+		sb.WriteString(fmt.Sprintf("💰 %s\n", formatPriceChange(estate.Cost, resp.Data.Cost)))
+	default:
+		sb.WriteString(fmt.Sprintf("💰 %s\n", resp.Data.FormatCost()))
+	}
+
+	if estate.Address != "" {
+		sb.WriteString(fmt.Sprintf("📍 %s\n", html.EscapeString(estate.Address)))
+	}
+	sb.WriteString(fmt.Sprintf("Сохранено %s • /unfav %d\n", saved.Saved.Format("02.01.2006"), estate.ID))
+	return sb.String()
+}
+
+// onFavoritesPage листает избранное
+func (b *Bot) onFavoritesPage(ctx context.Context, query *tgbotapi.CallbackQuery, arg string) (string, bool) {
+	page, err := strconv.Atoi(arg)
+	if err != nil {
+		return "Кнопка больше не действует", false
+	}
+	b.sendFavorites(ctx, query.Message.Chat.ID, page, query.Message.MessageID)
+	return "", false
+}
+
+// handleUnfavorite удаляет объявления из избранного по ID
+func (b *Bot) handleUnfavorite(chatID int64, args string) {
+	ids, err := parseEstateIDs(args)
+	if err != nil {
+		b.api.Send(tgbotapi.NewMessage(chatID, "Укажите ID объявления: /unfav 123456. ID показаны в /favorites"))
+		return
+	}
+
+	removed := 0
+	for _, id := range ids {
+		deleted, err := b.store.DeleteFavorite(chatID, id)
+		if err != nil {
+			log.Printf("Failed to delete favorite %d of chat %d: %v", id, chatID, err)
+			b.api.Send(tgbotapi.NewMessage(chatID, "Не удалось изменить избранное."))
+			return
+		}
+		if deleted {
+			removed++
+		}
+	}
+
+	if removed == 0 {
+		b.api.Send(tgbotapi.NewMessage(chatID, "Таких объявлений в избранном нет. Список: /favorites"))
+		return
+	}
+	b.api.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("🗑 Удалено из избранного: %d.", removed)))
+}
+
+// sendHidden отправляет список скрытых объявлений чата
+func (b *Bot) sendHidden(chatID int64) {
+	hidden, err := b.store.Hidden()
+	if err != nil {
+		log.Printf("Failed to load hidden listings: %v", err)
+		b.api.Send(tgbotapi.NewMessage(chatID, "Не удалось загрузить скрытые объявления."))
+		return
+	}
+
+	var sb strings.Builder
+	count := 0
+	for _, saved := range hidden {
+		if saved.ChatID != chatID {
+			continue
+		}
+		count++
+		sb.WriteString(fmt.Sprintf("\n• %s - /unhide %d", html.EscapeString(saved.Estate.Title), saved.Estate.ID))
+	}
+
+	if count == 0 {
+		b.api.Send(tgbotapi.NewMessage(chatID, "Скрытых объявлений нет. Нажмите 🙈 Скрыть похожие под объявлением, чтобы оно и его копии больше не приходили."))
+		return
+	}
+
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("🙈 <b>Скрытые объявления</b> (%d):\n%s", count, sb.String()))
+	msg.ParseMode = "HTML"
+	if _, err := b.sendMessage(msg); err != nil {
+		log.Printf("Failed to send hidden listings: %v", err)
+	}
+}
+
+// handleUnhide возвращает скрытые объявления по ID
+func (b *Bot) handleUnhide(chatID int64, args string) {
+	ids, err := parseEstateIDs(args)
+	if err != nil {
+		b.api.Send(tgbotapi.NewMessage(chatID, "Укажите ID объявления: /unhide 123456. ID показаны в /hidden"))
+		return
+	}
+
+	restored := 0
+	for _, id := range ids {
+		deleted, err := b.store.DeleteHidden(chatID, id)
+		if err != nil {
+			log.Printf("Failed to unhide estate %d in chat %d: %v", id, chatID, err)
+			b.api.Send(tgbotapi.NewMessage(chatID, "Не удалось вернуть объявление."))
+			return
+		}
+		if deleted {
+			b.unsetHidden(chatID, id)
+			restored++
+		}
+	}
+
+	if restored == 0 {
+		b.api.Send(tgbotapi.NewMessage(chatID, "Таких скрытых объявлений нет. Список: /hidden"))
+		return
+	}
+	b.api.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("↩️ Возвращено объявлений: %d.", restored)))
+}
+
+// parseEstateIDs разбирает ID объявлений через пробел или запятую
+func parseEstateIDs(args string) ([]int, error) {
+	fields := strings.FieldsFunc(args, func(r rune) bool { return r == ',' || r == ' ' })
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("no ids")
+	}
+
+	ids := make([]int, 0, len(fields))
+	for _, field := range fields {
+		id, err := strconv.Atoi(field)
+		if err != nil || id <= 0 {
+			return nil, fmt.Errorf("invalid id %q", field)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// IsHidden проверяет, скрыл ли чат какое-либо из объявлений
+func (b *Bot) IsHidden(chatID int64, estateIDs ...int) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, id := range estateIDs {
+		if _, ok := b.hidden[chatID][id]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+// HiddenFingerprints возвращает отпечатки квартир, скрытых чатом, чтобы
+// не присылать их копии, опубликованные позже или на других площадках
+func (b *Bot) HiddenFingerprints(chatID int64) []dedup.Fingerprint {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	result := make([]dedup.Fingerprint, 0, len(b.hidden[chatID]))
+	for _, fp := range b.hidden[chatID] {
+		result = append(result, fp)
+	}
+	return result
+}
+
+func (b *Bot) setHidden(chatID int64, estate *inpars.Estate) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.hidden[chatID] == nil {
+		b.hidden[chatID] = make(map[int]dedup.Fingerprint)
+	}
+	b.hidden[chatID][estate.ID] = dedup.FingerprintOf(estate)
+}
+
+func (b *Bot) unsetHidden(chatID int64, estateID int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.hidden[chatID], estateID)
+	if len(b.hidden[chatID]) == 0 {
+		delete(b.hidden, chatID)
+	}
+}
@@ -0,0 +1,818 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/RedNessen/inpars-telegram-bot/internal/inpars"
+	"github.com/RedNessen/inpars-telegram-bot/internal/query"
+	"github.com/RedNessen/inpars-telegram-bot/internal/subscription"
+)
+
+// filterStep шаг мастера настройки фильтров
+type filterStep int
+
+const (
+	stepRegion filterStep = iota
+	stepCity
+	stepMetro
+	stepDeal
+	stepRooms
+	stepBudget
+	stepSeller
+	stepDone
+	stepMenu // Меню /filters: выбор шага, который нужно изменить
+)
+
+const (
+	// actionFilter префикс кнопок мастера. Аргумент - "действие:значение"
+	actionFilter = "flt"
+	// filterPageSize количество вариантов на странице списка регионов, городов и станций
+	filterPageSize = 10
+)
+
+// Варианты типа сделки: тип объявления и срок аренды
+const (
+	dealRent  = "rent"
+	dealDaily = "daily"
+	dealBuy   = "buy"
+)
+
+// filterStepTitles заголовки шагов мастера
+var filterStepTitles = map[filterStep]string{
+	stepRegion: "📍 Регион",
+	stepCity:   "🏙 Город",
+	stepMetro:  "🚇 Метро",
+	stepDeal:   "🤝 Сделка",
+	stepRooms:  "🛏 Комнаты",
+	stepBudget: "💰 Бюджет",
+	stepSeller: "👤 Продавец",
+}
+
+// listItem вариант в списке регионов, городов или станций метро
+type listItem struct {
+	ID    int
+	Title string
+}
+
+// filterWizard состояние мастера /filter в чате. Все кнопки относятся
+// к одному сообщению мастера, кнопки старых сообщений не действуют
+type filterWizard struct {
+	sub       *subscription.Subscription // Черновик подписки
+	step      filterStep
+	single    bool // Изменяется один шаг из меню /filters, после него подписка сохраняется
+	messageID int
+	page      int
+	search    string // Часть названия для поиска в списке
+	loadErr   error  // Ошибка загрузки списка текущего шага
+
+	regions      []listItem
+	cities       []listItem
+	citiesRegion int
+	metro        []listItem
+	metroKey     [2]int // Регион и город, для которых загружены станции
+}
+
+// handleFilter запускает мастер настройки подписки. Без имени настраивается
+// подписка по умолчанию; фильтры, которых нет в мастере, сохраняются
+func (b *Bot) handleFilter(ctx context.Context, chatID int64, args string) {
+	name := strings.TrimSpace(args)
+	if name == "" {
+		name = subscription.DefaultName
+	}
+	if strings.ContainsAny(name, " =") {
+		b.api.Send(tgbotapi.NewMessage(chatID, "Имя подписки - одно слово: /filter дом"))
+		return
+	}
+
+	sub := &subscription.Subscription{ChatID: chatID, Name: name}
+	if existing, ok := b.subs.Get(chatID, name); ok {
+		sub = existing.Clone()
+	}
+
+	w := &filterWizard{sub: sub, step: stepRegion}
+	b.setFilterWizard(chatID, w)
+	b.showFilterStep(ctx, chatID, w)
+}
+
+// handleFilters показывает фильтры подписки с кнопками изменения отдельных шагов
+func (b *Bot) handleFilters(chatID int64, args string) {
+	name, existing, ok := b.chatSubscription(chatID, strings.TrimSpace(args))
+	if !ok {
+		b.api.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Подписка «%s» не найдена. Настроить ее: /filter %s", name, name)))
+		return
+	}
+
+	w := &filterWizard{sub: existing.Clone(), step: stepMenu}
+	b.setFilterWizard(chatID, w)
+	b.showFilterStep(context.Background(), chatID, w)
+}
+
+// chatSubscription находит подписку чата по имени. Без имени берется подписка
+// по умолчанию, а если у чата одна подписка - она
+func (b *Bot) chatSubscription(chatID int64, name string) (string, *subscription.Subscription, bool) {
+	if name == "" {
+		name = subscription.DefaultName
+		if subs := b.subs.ForChat(chatID); len(subs) == 1 {
+			name = subs[0].Name
+		}
+	}
+	sub, ok := b.subs.Get(chatID, name)
+	return name, sub, ok
+}
+
+// handleFilterText принимает текст для мастера: часть названия региона,
+// города или станции либо бюджет. Возвращает false, если мастер не ждет текста
+func (b *Bot) handleFilterText(ctx context.Context, chatID int64, text string) bool {
+	w := b.filterWizard(chatID)
+	if w == nil {
+		return false
+	}
+
+	switch w.step {
+	case stepRegion, stepCity, stepMetro:
+		w.search = strings.TrimSpace(text)
+		w.page = 0
+	case stepBudget:
+		min, max, err := query.ParseBudget(text)
+		if err != nil {
+			b.api.Send(tgbotapi.NewMessage(chatID, err.Error()))
+			return true
+		}
+		w.sub.CostMin, w.sub.CostMax = min, max
+		if b.advanceFilter(ctx, chatID, w) {
+			return true
+		}
+	default:
+		return false
+	}
+
+	// Ответ на текст - новое сообщение мастера, у старого убираем кнопки
+	if w.messageID != 0 {
+		empty := tgbotapi.InlineKeyboardMarkup{InlineKeyboard: [][]tgbotapi.InlineKeyboardButton{}}
+		b.api.Send(tgbotapi.NewEditMessageReplyMarkup(chatID, w.messageID, empty))
+		w.messageID = 0
+	}
+	b.showFilterStep(ctx, chatID, w)
+	return true
+}
+
+// onFilter обрабатывает кнопки мастера
+func (b *Bot) onFilter(ctx context.Context, query *tgbotapi.CallbackQuery, arg string) (string, bool) {
+	chatID := query.Message.Chat.ID
+	w := b.filterWizard(chatID)
+	if w == nil || w.messageID != query.Message.MessageID {
+		return "Настройка уже завершена. Начать заново: /filter", false
+	}
+
+	action, value, _ := strings.Cut(arg, ":")
+	id, _ := strconv.Atoi(value)
+
+	switch action {
+	case "p": // Страница списка
+		w.page = id
+	case "e": // Изменить шаг из меню /filters
+		step := filterStep(id)
+		if step < stepRegion || step >= stepDone {
+			return "Кнопка больше не действует", false
+		}
+		w.step, w.single = step, true
+		w.page, w.search = 0, ""
+		if b.skipFilterStep(ctx, w) {
+			w.step, w.single = stepMenu, false
+			if step == stepMetro && len(w.sub.RegionIDs) > 0 {
+				return "В выбранном городе нет метро", true
+			}
+			return "Сначала выберите регион", true
+		}
+	case "a": // Настроить все шаги заново
+		w.step, w.single = stepRegion, false
+		w.page, w.search = 0, ""
+	case "q": // Отмена
+		b.setFilterWizard(chatID, nil)
+		b.editFilterMessage(chatID, w.messageID, "Настройка отменена, подписка не изменилась.", nil)
+		return "", false
+	case "b": // Назад
+		b.retreatFilter(ctx, w)
+	case "x": // Любое значение
+		b.clearFilterStep(w)
+		if b.advanceFilter(ctx, chatID, w) {
+			return "Подписка сохранена", false
+		}
+	case "n": // Готово (шаги с несколькими вариантами)
+		if b.advanceFilter(ctx, chatID, w) {
+			return "Подписка сохранена", false
+		}
+	case "s": // Выбор варианта
+		if !b.selectFilterOption(w, value) {
+			break
+		}
+		if b.advanceFilter(ctx, chatID, w) {
+			return "Подписка сохранена", false
+		}
+	default:
+		return "Кнопка больше не действует", false
+	}
+
+	b.showFilterStep(ctx, chatID, w)
+	return "", false
+}
+
+// selectFilterOption применяет выбранный вариант. Возвращает true, если
+// шаг завершен; на шагах с несколькими вариантами выбор переключается
+func (b *Bot) selectFilterOption(w *filterWizard, value string) bool {
+	sub := w.sub
+	id, _ := strconv.Atoi(value)
+
+	switch w.step {
+	case stepRegion:
+		if !slices.Equal(sub.RegionIDs, []int{id}) {
+			sub.RegionIDs = []int{id}
+			sub.CityIDs, sub.MetroIDs = nil, nil
+		}
+		return true
+	case stepCity:
+		if !slices.Equal(sub.CityIDs, []int{id}) {
+			sub.CityIDs = []int{id}
+			sub.MetroIDs = nil
+		}
+		return true
+	case stepMetro:
+		sub.MetroIDs = toggleInt(sub.MetroIDs, id)
+		return false
+	case stepDeal:
+		switch value {
+		case dealRent:
+			sub.TypeAd, sub.Filters.RentTime = []int{1}, 1
+		case dealDaily:
+			sub.TypeAd, sub.Filters.RentTime = []int{1}, 2
+		case dealBuy:
+			sub.TypeAd, sub.Filters.RentTime = []int{2}, 0
+		}
+		return true
+	case stepRooms:
+		sub.Filters.Rooms = toggleInt(sub.Filters.Rooms, id)
+		return false
+	case stepBudget:
+		min, max, err := query.ParseBudget(value)
+		if err != nil {
+			return false
+		}
+		sub.CostMin, sub.CostMax = min, max
+		return true
+	case stepSeller:
+		sub.SellerTypes = toggleInt(sub.SellerTypes, id)
+		return false
+	}
+	return false
+}
+
+// clearFilterStep снимает ограничение текущего шага
+func (b *Bot) clearFilterStep(w *filterWizard) {
+	sub := w.sub
+	switch w.step {
+	case stepRegion:
+		sub.RegionIDs, sub.CityIDs, sub.MetroIDs = nil, nil, nil
+	case stepCity:
+		sub.CityIDs, sub.MetroIDs = nil, nil
+	case stepMetro:
+		sub.MetroIDs = nil
+	case stepDeal:
+		sub.TypeAd, sub.Filters.RentTime = nil, 0
+	case stepRooms:
+		sub.Filters.Rooms = nil
+	case stepBudget:
+		sub.CostMin, sub.CostMax = 0, 0
+	case stepSeller:
+		sub.SellerTypes = nil
+	}
+}
+
+// advanceFilter переходит к следующему шагу, пропуская шаги без вариантов.
+// После последнего шага (или единственного шага из /filters) подписка
+// сохраняется, и функция возвращает true
+func (b *Bot) advanceFilter(ctx context.Context, chatID int64, w *filterWizard) bool {
+	w.page, w.search = 0, ""
+	if !w.single {
+		w.step++
+		for w.step < stepDone && b.skipFilterStep(ctx, w) {
+			w.step++
+		}
+	}
+	if !w.single && w.step < stepDone {
+		return false
+	}
+
+	b.setFilterWizard(chatID, nil)
+	b.saveFilter(chatID, w)
+	return true
+}
+
+// retreatFilter возвращается к предыдущему шагу
+func (b *Bot) retreatFilter(ctx context.Context, w *filterWizard) {
+	w.page, w.search = 0, ""
+	for w.step > stepRegion {
+		w.step--
+		if !b.skipFilterStep(ctx, w) {
+			return
+		}
+	}
+}
+
+// skipFilterStep сообщает, что на шаге нечего выбирать: город и метро
+// выбираются только в выбранном регионе, а метро есть не во всех городах
+func (b *Bot) skipFilterStep(ctx context.Context, w *filterWizard) bool {
+	switch w.step {
+	case stepCity:
+		return len(w.sub.RegionIDs) == 0
+	case stepMetro:
+		if len(w.sub.RegionIDs) == 0 {
+			return true
+		}
+		b.loadFilterOptions(ctx, w)
+		return w.loadErr == nil && len(w.metro) == 0
+	}
+	return false
+}
+
+// saveFilter сохраняет подписку из мастера
+func (b *Bot) saveFilter(chatID int64, w *filterWizard) {
+	text := fmt.Sprintf("✅ Подписка «%s» сохранена\n\n%s\nИзменить: /filters %s",
+		html.EscapeString(w.sub.Name), b.describeFilter(w), html.EscapeString(w.sub.Name))
+	if err := b.subs.Put(w.sub); err != nil {
+		log.Printf("Failed to save subscription %s from wizard: %v", w.sub.Key(), err)
+		text = "Не удалось сохранить подписку."
+	} else {
+		log.Printf("Saved subscription %s from wizard: %s", w.sub.Key(), w.sub.Describe())
+	}
+	b.editFilterMessage(chatID, w.messageID, text, nil)
+}
+
+// showFilterStep отправляет или изменяет сообщение мастера для текущего шага
+func (b *Bot) showFilterStep(ctx context.Context, chatID int64, w *filterWizard) {
+	b.loadFilterOptions(ctx, w)
+	text, keyboard := b.renderFilterStep(w)
+
+	if w.messageID != 0 {
+		b.editFilterMessage(chatID, w.messageID, text, &keyboard)
+		return
+	}
+
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ParseMode = "HTML"
+	msg.ReplyMarkup = keyboard
+	messageID, err := b.sendMessage(msg)
+	if err != nil {
+		log.Printf("Failed to send filter wizard: %v", err)
+		b.setFilterWizard(chatID, nil)
+		return
+	}
+	w.messageID = messageID
+}
+
+func (b *Bot) editFilterMessage(chatID int64, messageID int, text string, keyboard *tgbotapi.InlineKeyboardMarkup) {
+	if messageID == 0 {
+		msg := tgbotapi.NewMessage(chatID, text)
+		msg.ParseMode = "HTML"
+		b.sendMessage(msg)
+		return
+	}
+
+	edit := tgbotapi.NewEditMessageText(chatID, messageID, text)
+	edit.ParseMode = "HTML"
+	edit.ReplyMarkup = keyboard
+	if _, err := b.api.Send(edit); err != nil && !isNotModified(err) {
+		log.Printf("Failed to edit filter wizard in chat %d: %v", chatID, err)
+	}
+}
+
+// loadFilterOptions загружает список вариантов текущего шага, если он еще не загружен
+func (b *Bot) loadFilterOptions(ctx context.Context, w *filterWizard) {
+	w.loadErr = nil
+	region, city := firstInt(w.sub.RegionIDs), firstInt(w.sub.CityIDs)
+
+	switch w.step {
+	case stepRegion:
+		if w.regions != nil {
+			return
+		}
+		regions, err := b.ref.Regions(ctx)
+		if err != nil {
+			w.loadErr = err
+			break
+		}
+		w.regions = make([]listItem, 0, len(regions))
+		for _, r := range regions {
+			w.regions = append(w.regions, listItem{ID: r.ID, Title: r.Title})
+		}
+		sortItems(w.regions)
+	case stepCity:
+		if w.cities != nil && w.citiesRegion == region {
+			return
+		}
+		cities, err := b.ref.Cities(ctx, region)
+		if err != nil {
+			w.loadErr = err
+			break
+		}
+		w.cities, w.citiesRegion = make([]listItem, 0, len(cities)), region
+		for _, c := range cities {
+			w.cities = append(w.cities, listItem{ID: c.ID, Title: c.Title})
+		}
+		sortItems(w.cities)
+	case stepMetro:
+		if w.metro != nil && w.metroKey == [2]int{region, city} {
+			return
+		}
+		stations, err := b.ref.Metro(ctx, region, city)
+		if err != nil {
+			w.loadErr = err
+			break
+		}
+		w.metro, w.metroKey = make([]listItem, 0, len(stations)), [2]int{region, city}
+		for _, m := range stations {
+			w.metro = append(w.metro, listItem{ID: m.ID, Title: m.Title})
+		}
+		sortItems(w.metro)
+	}
+
+	if w.loadErr != nil {
+		log.Printf("Failed to load filter options for step %d: %v", w.step, w.loadErr)
+	}
+}
+
+// renderFilterStep формирует текст и кнопки текущего шага
+func (b *Bot) renderFilterStep(w *filterWizard) (string, tgbotapi.InlineKeyboardMarkup) {
+	sub := w.sub
+	var sb strings.Builder
+	var rows [][]tgbotapi.InlineKeyboardButton
+
+	if w.step == stepMenu {
+		sb.WriteString(fmt.Sprintf("⚙️ Фильтры подписки «%s»\n\n", html.EscapeString(sub.Name)))
+		sb.WriteString(b.describeFilter(w))
+		sb.WriteString("\nЧто изменить?")
+
+		var row []tgbotapi.InlineKeyboardButton
+		for step := stepRegion; step < stepDone; step++ {
+			row = append(row, filterButton(filterStepTitles[step], "e", strconv.Itoa(int(step))))
+			if len(row) == 3 {
+				rows = append(rows, row)
+				row = nil
+			}
+		}
+		rows = append(rows, row)
+		rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+			filterButton("🔁 Настроить заново", "a", ""),
+			filterButton("✖️ Закрыть", "q", ""),
+		))
+		return sb.String(), tgbotapi.NewInlineKeyboardMarkup(rows...)
+	}
+
+	if w.single {
+		sb.WriteString(fmt.Sprintf("<b>%s</b>\n", filterStepTitles[w.step]))
+	} else {
+		sb.WriteString(fmt.Sprintf("<b>Шаг %d из %d. %s</b>\n", int(w.step)+1, int(stepDone), filterStepTitles[w.step]))
+	}
+
+	// Нижний ряд: «любое значение», «готово» для шагов с несколькими вариантами, назад и отмена
+	var anyLabel string
+	multi := false
+
+	switch w.step {
+	case stepRegion, stepCity, stepMetro:
+		items, selected := w.regions, sub.RegionIDs
+		prompt := "Выберите регион"
+		anyLabel = "Любой регион"
+		switch w.step {
+		case stepCity:
+			items, selected = w.cities, sub.CityIDs
+			prompt, anyLabel = "Выберите город", "Весь регион"
+		case stepMetro:
+			items, selected = w.metro, sub.MetroIDs
+			prompt, anyLabel = "Отметьте станции метро и нажмите «Готово»", "Любое метро"
+			multi = true
+		}
+
+		sb.WriteString(prompt)
+		sb.WriteString(". Можно написать часть названия.\n")
+		if w.loadErr != nil {
+			sb.WriteString("\n⚠️ Не удалось загрузить список, попробуйте позже или пропустите шаг.\n")
+		}
+
+		filtered := filterItems(items, w.search)
+		if w.search != "" {
+			sb.WriteString(fmt.Sprintf("\nПоиск «%s»: найдено %d.\n", html.EscapeString(w.search), len(filtered)))
+		}
+		rows = append(rows, itemRows(filtered, selected, w.page)...)
+		rows = append(rows, bottomRow(w, anyLabel, multi))
+		return sb.String(), tgbotapi.NewInlineKeyboardMarkup(rows...)
+
+	case stepDeal:
+		sb.WriteString("Что вы ищете?")
+		rows = append(rows,
+			tgbotapi.NewInlineKeyboardRow(
+				filterButton(mark(dealOf(sub) == dealRent)+"Снять надолго", "s", dealRent),
+				filterButton(mark(dealOf(sub) == dealDaily)+"Снять посуточно", "s", dealDaily),
+			),
+			tgbotapi.NewInlineKeyboardRow(filterButton(mark(dealOf(sub) == dealBuy)+"Купить", "s", dealBuy)),
+		)
+		anyLabel = "Любая сделка"
+
+	case stepRooms:
+		sb.WriteString("Сколько комнат? Можно отметить несколько вариантов.")
+		var row []tgbotapi.InlineKeyboardButton
+		for rooms := 0; rooms <= 4; rooms++ {
+			label := strconv.Itoa(rooms)
+			if rooms == 0 {
+				label = "Студия"
+			}
+			row = append(row, filterButton(mark(slices.Contains(sub.Filters.Rooms, rooms))+label, "s", strconv.Itoa(rooms)))
+		}
+		rows = append(rows, row)
+		anyLabel, multi = "Любое количество", true
+
+	case stepBudget:
+		sb.WriteString("Выберите верхнюю границу цены или напишите диапазон: 30000-50000, до 60 тыс, от 5 до 8 млн.")
+		if current := describeBudget(sub.CostMin, sub.CostMax); current != "" {
+			sb.WriteString(fmt.Sprintf("\nСейчас: %s", current))
+		}
+		presets := []int{30000, 40000, 50000, 60000, 80000, 100000}
+		if dealOf(sub) == dealBuy {
+			presets = []int{5000000, 7000000, 10000000, 15000000, 20000000, 30000000}
+		} else if dealOf(sub) == dealDaily {
+			presets = []int{2000, 3000, 4000, 5000, 7000, 10000}
+		}
+		var row []tgbotapi.InlineKeyboardButton
+		for _, cost := range presets {
+			row = append(row, filterButton("до "+formatShortPrice(cost), "s", "-"+strconv.Itoa(cost)))
+			if len(row) == 3 {
+				rows = append(rows, row)
+				row = nil
+			}
+		}
+		anyLabel = "Любой бюджет"
+
+	case stepSeller:
+		sb.WriteString("От кого объявления? Можно отметить несколько вариантов.")
+		var row []tgbotapi.InlineKeyboardButton
+		for sellerType := 1; sellerType <= 3; sellerType++ {
+			label := mark(slices.Contains(sub.SellerTypes, sellerType)) + sellerTypeTitle(sellerType)
+			row = append(row, filterButton(label, "s", strconv.Itoa(sellerType)))
+		}
+		rows = append(rows, row)
+		anyLabel, multi = "Любой продавец", true
+	}
+
+	rows = append(rows, bottomRow(w, anyLabel, multi))
+	return sb.String(), tgbotapi.NewInlineKeyboardMarkup(rows...)
+}
+
+// describeFilter описывает шаги мастера с названиями вместо ID, если списки загружены
+func (b *Bot) describeFilter(w *filterWizard) string {
+	sub := w.sub
+	var sb strings.Builder
+
+	line := func(step filterStep, value string) {
+		sb.WriteString(fmt.Sprintf("%s: %s\n", filterStepTitles[step], html.EscapeString(value)))
+	}
+
+	line(stepRegion, itemTitles(w.regions, sub.RegionIDs, "любой"))
+	line(stepCity, itemTitles(w.cities, sub.CityIDs, "любой"))
+	line(stepMetro, itemTitles(w.metro, sub.MetroIDs, "любое"))
+
+	deal := "любая"
+	switch dealOf(sub) {
+	case dealRent:
+		deal = "снять надолго"
+	case dealDaily:
+		deal = "снять посуточно"
+	case dealBuy:
+		deal = "купить"
+	default:
+		if len(sub.TypeAd) > 0 {
+			deal = strings.ToLower(strings.Join(mapInts(sub.TypeAd, typeAdTitle), ", "))
+		}
+	}
+	line(stepDeal, deal)
+
+	rooms := "любое количество"
+	if len(sub.Filters.Rooms) > 0 {
+		sorted := slices.Sorted(slices.Values(sub.Filters.Rooms))
+		rooms = strings.Join(mapInts(sorted, roomsTitle), ", ")
+	}
+	line(stepRooms, rooms)
+
+	budget := describeBudget(sub.CostMin, sub.CostMax)
+	if budget == "" {
+		budget = "любой"
+	}
+	line(stepBudget, budget)
+
+	sellers := "любой"
+	if len(sub.SellerTypes) > 0 {
+		sorted := slices.Sorted(slices.Values(sub.SellerTypes))
+		sellers = strings.ToLower(strings.Join(mapInts(sorted, sellerTypeTitle), ", "))
+	}
+	line(stepSeller, sellers)
+
+	return sb.String()
+}
+
+// itemRows формирует кнопки страницы списка в два столбца и кнопки листания
+func itemRows(items []listItem, selected []int, page int) [][]tgbotapi.InlineKeyboardButton {
+	pages := max((len(items)+filterPageSize-1)/filterPageSize, 1)
+	page = min(max(page, 0), pages-1)
+	pageItems := items[page*filterPageSize : min((page+1)*filterPageSize, len(items))]
+
+	var rows [][]tgbotapi.InlineKeyboardButton
+	for i := 0; i < len(pageItems); i += 2 {
+		var row []tgbotapi.InlineKeyboardButton
+		for _, item := range pageItems[i:min(i+2, len(pageItems))] {
+			label := mark(slices.Contains(selected, item.ID)) + truncate(item.Title, 30)
+			row = append(row, filterButton(label, "s", strconv.Itoa(item.ID)))
+		}
+		rows = append(rows, row)
+	}
+
+	if pages > 1 {
+		var nav []tgbotapi.InlineKeyboardButton
+		if page > 0 {
+			nav = append(nav, filterButton("◀️", "p", strconv.Itoa(page-1)))
+		}
+		nav = append(nav, filterButton(fmt.Sprintf("%d/%d", page+1, pages), "p", strconv.Itoa(page)))
+		if page < pages-1 {
+			nav = append(nav, filterButton("▶️", "p", strconv.Itoa(page+1)))
+		}
+		rows = append(rows, nav)
+	}
+	return rows
+}
+
+// bottomRow формирует ряд управления мастером
+func bottomRow(w *filterWizard, anyLabel string, multi bool) []tgbotapi.InlineKeyboardButton {
+	var row []tgbotapi.InlineKeyboardButton
+	if !w.single && w.step > stepRegion {
+		row = append(row, filterButton("⬅️", "b", ""))
+	}
+	row = append(row, filterButton(anyLabel, "x", ""))
+	if multi {
+		row = append(row, filterButton("✅ Готово", "n", ""))
+	}
+	row = append(row, filterButton("✖️", "q", ""))
+	return row
+}
+
+func filterButton(label, action, value string) tgbotapi.InlineKeyboardButton {
+	return tgbotapi.NewInlineKeyboardButtonData(label, callbackData(actionFilter, action+":"+value))
+}
+
+// dealOf определяет вариант сделки мастера по типу объявления и сроку аренды
+func dealOf(sub *subscription.Subscription) string {
+	switch {
+	case slices.Equal(sub.TypeAd, []int{1}) && sub.Filters.RentTime == 2:
+		return dealDaily
+	case slices.Equal(sub.TypeAd, []int{1}):
+		return dealRent
+	case slices.Equal(sub.TypeAd, []int{2}):
+		return dealBuy
+	}
+	return ""
+}
+
+// describeBudget описывает диапазон цены. Пустая строка - без ограничений
+func describeBudget(min, max int) string {
+	switch {
+	case min > 0 && max > 0:
+		return fmt.Sprintf("%s - %s ₽", formatPrice(min), formatPrice(max))
+	case min > 0:
+		return fmt.Sprintf("от %s ₽", formatPrice(min))
+	case max > 0:
+		return fmt.Sprintf("до %s ₽", formatPrice(max))
+	}
+	return ""
+}
+
+// formatShortPrice форматирует цену кнопки: 60000 → «60 тыс», 5000000 → «5 млн»
+func formatShortPrice(price int) string {
+	if price >= 1000000 && price%100000 == 0 {
+		return strconv.FormatFloat(float64(price)/1e6, 'f', -1, 64) + " млн"
+	}
+	if price >= 1000 && price%1000 == 0 {
+		return strconv.Itoa(price/1000) + " тыс"
+	}
+	return formatPrice(price)
+}
+
+func typeAdTitle(typeAd int) string {
+	return inpars.GetTypeAdName(typeAd)
+}
+
+func sellerTypeTitle(sellerType int) string {
+	// Тип продавца в фильтре начинается с 1, а поле agent объявления - с 0
+	return inpars.GetSellerTypeName(sellerType - 1)
+}
+
+func roomsTitle(rooms int) string {
+	if rooms == 0 {
+		return "студия"
+	}
+	return strconv.Itoa(rooms)
+}
+
+// itemTitles возвращает названия выбранных вариантов. Если список не
+// загружен, вместо названия показывается ID
+func itemTitles(items []listItem, ids []int, empty string) string {
+	if len(ids) == 0 {
+		return empty
+	}
+	titles := make([]string, 0, len(ids))
+	for _, id := range ids {
+		title := "ID " + strconv.Itoa(id)
+		for _, item := range items {
+			if item.ID == id {
+				title = item.Title
+				break
+			}
+		}
+		titles = append(titles, title)
+	}
+	return strings.Join(titles, ", ")
+}
+
+// filterItems оставляет варианты, название которых подходит под строку поиска
+// (см. inpars.NameScore), лучшие совпадения - первыми
+func filterItems(items []listItem, search string) []listItem {
+	search = inpars.NormalizeName(search)
+	if search == "" {
+		return items
+	}
+
+	scores := make(map[int]int)
+	var result []listItem
+	for _, item := range items {
+		if score := inpars.NameScore(item.Title, search); score > 0 {
+			scores[item.ID] = score
+			result = append(result, item)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool { return scores[result[i].ID] > scores[result[j].ID] })
+	return result
+}
+
+func sortItems(items []listItem) {
+	sort.SliceStable(items, func(i, j int) bool { return items[i].Title < items[j].Title })
+}
+
+func toggleInt(values []int, value int) []int {
+	if i := slices.Index(values, value); i >= 0 {
+		return slices.Delete(slices.Clone(values), i, i+1)
+	}
+	return append(slices.Clone(values), value)
+}
+
+func firstInt(values []int) int {
+	if len(values) == 0 {
+		return 0
+	}
+	return values[0]
+}
+
+func mapInts(values []int, f func(int) string) []string {
+	result := make([]string, len(values))
+	for i, v := range values {
+		result[i] = f(v)
+	}
+	return result
+}
+
+// mark отмечает выбранный вариант на кнопке
+func mark(selected bool) string {
+	if selected {
+		return "✅ "
+	}
+	return ""
+}
+
+func (b *Bot) filterWizard(chatID int64) *filterWizard {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.wizards[chatID]
+}
+
+func (b *Bot) setFilterWizard(chatID int64, w *filterWizard) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if w == nil {
+		delete(b.wizards, chatID)
+		return
+	}
+	b.wizards[chatID] = w
+}
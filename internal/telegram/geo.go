@@ -0,0 +1,295 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/RedNessen/inpars-telegram-bot/internal/geo"
+	"github.com/RedNessen/inpars-telegram-bot/internal/subscription"
+)
+
+const (
+	// maxGeoJSONSize ограничение размера файла GeoJSON
+	maxGeoJSONSize = 1 << 20
+	// maxAreaPoints ограничение общего количества точек полигонов подписки
+	maxAreaPoints = 20000
+	// maxRadius максимальный радиус круга в метрах
+	maxRadius = 100000
+)
+
+// geoRequest незавершенный ввод по геопозиции: района подписки
+// (/near → геопозиция → радиус) или места чата (/place → геопозиция)
+type geoRequest struct {
+	subName   string
+	placeName string
+	radius    float64    // Радиус, если указан в команде
+	point     *geo.Point // Полученная геопозиция
+}
+
+// handleNear начинает ограничение подписки кругом вокруг геопозиции
+func (b *Bot) handleNear(chatID int64, args string) {
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		b.api.Send(tgbotapi.NewMessage(chatID, "Использование: /near имя [радиус]\nПример: /near default 1500"))
+		return
+	}
+
+	name := fields[0]
+	if _, ok := b.subs.Get(chatID, name); !ok {
+		b.api.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Подписка «%s» не найдена. Список подписок: /subs", name)))
+		return
+	}
+
+	req := &geoRequest{subName: name}
+	if len(fields) > 1 {
+		radius, err := parseRadius(strings.Join(fields[1:], " "))
+		if err != nil {
+			b.api.Send(tgbotapi.NewMessage(chatID, err.Error()))
+			return
+		}
+		req.radius = radius
+	}
+	b.setGeoRequest(chatID, req)
+
+	msg := tgbotapi.NewMessage(chatID, "📍 Отправьте геопозицию центра района: кнопкой ниже или через 📎 → Геопозиция (можно выбрать точку на карте).")
+	keyboard := tgbotapi.NewReplyKeyboard(tgbotapi.NewKeyboardButtonRow(
+		tgbotapi.NewKeyboardButtonLocation("📍 Отправить мою геопозицию"),
+	))
+	keyboard.OneTimeKeyboard = true
+	msg.ReplyMarkup = keyboard
+	b.api.Send(msg)
+}
+
+// handleLocation принимает геопозицию для начатого командой /near ввода
+func (b *Bot) handleLocation(chatID int64, location *tgbotapi.Location) {
+	req := b.geoRequest(chatID)
+	if req == nil {
+		b.api.Send(tgbotapi.NewMessage(chatID, "Чтобы искать объявления рядом с этой точкой, сначала выберите подписку: /near имя [радиус]\nЧтобы сохранить точку как место: /place имя"))
+		return
+	}
+
+	if req.placeName != "" {
+		b.setGeoRequest(chatID, nil)
+		b.savePlace(chatID, req.placeName, geo.Point{Lat: location.Latitude, Lng: location.Longitude})
+		return
+	}
+
+	req.point = &geo.Point{Lat: location.Latitude, Lng: location.Longitude}
+	if req.radius > 0 {
+		b.addCircle(chatID, req)
+		return
+	}
+
+	msg := tgbotapi.NewMessage(chatID, "Укажите радиус в метрах или километрах (например, 800 или 1.5 км):")
+	msg.ReplyMarkup = tgbotapi.NewReplyKeyboard(tgbotapi.NewKeyboardButtonRow(
+		tgbotapi.NewKeyboardButton("500"),
+		tgbotapi.NewKeyboardButton("1000"),
+		tgbotapi.NewKeyboardButton("2000"),
+		tgbotapi.NewKeyboardButton("5000"),
+	))
+	b.api.Send(msg)
+}
+
+// handleGeoRadius принимает радиус после геопозиции.
+// Возвращает false, если чат не ожидает ввода радиуса
+func (b *Bot) handleGeoRadius(chatID int64, text string) bool {
+	req := b.geoRequest(chatID)
+	if req == nil || req.point == nil {
+		return false
+	}
+
+	radius, err := parseRadius(text)
+	if err != nil {
+		b.api.Send(tgbotapi.NewMessage(chatID, err.Error()))
+		return true
+	}
+	req.radius = radius
+	b.addCircle(chatID, req)
+	return true
+}
+
+// addCircle добавляет круг к ограничению подписки и завершает ввод
+func (b *Bot) addCircle(chatID int64, req *geoRequest) {
+	b.setGeoRequest(chatID, nil)
+
+	circle := geo.Circle{Center: *req.point, Radius: req.radius}
+	err := b.updateArea(chatID, req.subName, func(area *subscription.Area) error {
+		area.Circles = append(area.Circles, circle)
+		return nil
+	})
+	if err != nil {
+		b.sendWithoutKeyboard(chatID, err.Error())
+		return
+	}
+
+	b.sendWithoutKeyboard(chatID, fmt.Sprintf("✅ Подписка «%s» ограничена районом: %.0f м от точки %.5f, %.5f.\nУдалить ограничение: /area %s off",
+		req.subName, circle.Radius, circle.Center.Lat, circle.Center.Lng, req.subName))
+}
+
+// handleArea ограничивает подписку полигонами из GeoJSON-файла или снимает ограничение
+func (b *Bot) handleArea(ctx context.Context, chatID int64, args string, document *tgbotapi.Document) {
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		b.api.Send(tgbotapi.NewMessage(chatID, "Использование:\n"+
+			"• отправьте файл .geojson с подписью /area имя - ограничить подписку полигонами (например, нарисованными на geojson.io)\n"+
+			"• /area имя off - снять ограничение по району\n"+
+			"• /near имя [радиус] - искать рядом с геопозицией"))
+		return
+	}
+	name := fields[0]
+
+	if len(fields) > 1 && strings.EqualFold(fields[1], "off") {
+		err := b.updateArea(chatID, name, func(area *subscription.Area) error {
+			*area = subscription.Area{}
+			return nil
+		})
+		if err != nil {
+			b.api.Send(tgbotapi.NewMessage(chatID, err.Error()))
+			return
+		}
+		b.api.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("🗑 Ограничение по району для подписки «%s» снято.", name)))
+		return
+	}
+
+	if document == nil {
+		b.api.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Отправьте файл .geojson с подписью /area %s", name)))
+		return
+	}
+	if document.FileSize > maxGeoJSONSize {
+		b.api.Send(tgbotapi.NewMessage(chatID, "Файл слишком большой, максимум 1 МБ."))
+		return
+	}
+
+	data, err := b.downloadFile(ctx, document.FileID)
+	if err != nil {
+		log.Printf("Failed to download GeoJSON from chat %d: %v", chatID, err)
+		b.api.Send(tgbotapi.NewMessage(chatID, "Не удалось загрузить файл."))
+		return
+	}
+
+	polygons, err := geo.ParseGeoJSON(data)
+	if err != nil {
+		b.api.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Не удалось разобрать файл: %v", err)))
+		return
+	}
+
+	err = b.updateArea(chatID, name, func(area *subscription.Area) error {
+		area.Polygons = append(area.Polygons, polygons...)
+		points := 0
+		for _, p := range area.Polygons {
+			for _, ring := range p.Rings {
+				points += len(ring)
+			}
+		}
+		if points > maxAreaPoints {
+			return fmt.Errorf("Слишком подробные полигоны: %d точек, максимум %d.", points, maxAreaPoints)
+		}
+		return nil
+	})
+	if err != nil {
+		b.api.Send(tgbotapi.NewMessage(chatID, err.Error()))
+		return
+	}
+
+	b.api.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("✅ Подписка «%s» ограничена районом: добавлено полигонов - %d.", name, len(polygons))))
+}
+
+// updateArea изменяет ограничение подписки по району и сохраняет подписку.
+// Ошибки возвращаются в виде, пригодном для показа пользователю
+func (b *Bot) updateArea(chatID int64, name string, update func(area *subscription.Area) error) error {
+	sub, ok := b.subs.Get(chatID, name)
+	if !ok {
+		return fmt.Errorf("Подписка «%s» не найдена. Список подписок: /subs", name)
+	}
+
+	sub = sub.Clone()
+	if err := update(&sub.Area); err != nil {
+		return err
+	}
+	if err := b.subs.Put(sub); err != nil {
+		log.Printf("Failed to save area of subscription %s: %v", sub.Key(), err)
+		return fmt.Errorf("Не удалось сохранить подписку.")
+	}
+
+	log.Printf("Updated area of subscription %s: %s", sub.Key(), sub.Area.Describe())
+	return nil
+}
+
+// downloadFile загружает файл, отправленный боту
+func (b *Bot) downloadFile(ctx context.Context, fileID string) ([]byte, error) {
+	fileURL, err := b.api.GetFileDirectURL(fileID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get file url: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", fileURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download file: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxGeoJSONSize))
+}
+
+// sendWithoutKeyboard отправляет сообщение и убирает клавиатуру ввода
+func (b *Bot) sendWithoutKeyboard(chatID int64, text string) {
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ReplyMarkup = tgbotapi.NewRemoveKeyboard(true)
+	b.api.Send(msg)
+}
+
+func (b *Bot) geoRequest(chatID int64) *geoRequest {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.geoRequests[chatID]
+}
+
+func (b *Bot) setGeoRequest(chatID int64, req *geoRequest) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if req == nil {
+		delete(b.geoRequests, chatID)
+		return
+	}
+	b.geoRequests[chatID] = req
+}
+
+// parseRadius разбирает радиус: "800", "800 м", "1.5 км", "2km"
+func parseRadius(text string) (float64, error) {
+	text = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(text), ",", "."))
+
+	multiplier := 1.0
+	for _, suffix := range []string{"км", "km"} {
+		if strings.HasSuffix(text, suffix) {
+			text = strings.TrimSuffix(text, suffix)
+			multiplier = 1000
+		}
+	}
+	for _, suffix := range []string{"м", "m"} {
+		text = strings.TrimSuffix(text, suffix)
+	}
+
+	radius, err := strconv.ParseFloat(strings.TrimSpace(text), 64)
+	if err != nil || radius <= 0 {
+		return 0, fmt.Errorf("Не удалось разобрать радиус. Укажите число метров, например 1000, или километров: 1.5 км")
+	}
+	radius *= multiplier
+	if radius > maxRadius {
+		return 0, fmt.Errorf("Радиус не может быть больше %d км", maxRadius/1000)
+	}
+	return radius, nil
+}
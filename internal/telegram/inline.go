@@ -0,0 +1,258 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/RedNessen/inpars-telegram-bot/internal/inpars"
+	"github.com/RedNessen/inpars-telegram-bot/internal/textmatch"
+)
+
+const (
+	// inlineBatch сколько объявлений запрашивается за раз: Telegram принимает
+	// не больше 50 результатов, часть отсеют фильтры, которые API не поддерживает
+	inlineBatch = 50
+	// inlineDebounce пауза после ввода: Telegram присылает запрос на каждый
+	// набранный символ, к API уходит только последний
+	inlineDebounce = 700 * time.Millisecond
+	// inlineCacheTTL сколько хранится ответ на запрос, в боте и в Telegram
+	inlineCacheTTL = 5 * time.Minute
+	// inlineReserveShare какая часть лимита API (1/N) оставляется мониторингу:
+	// при меньшем остатке inline-запросы отвечаются только из кэша
+	inlineReserveShare = 5
+	// maxSwitchPMText максимальная длина текста кнопки над результатами
+	maxSwitchPMText = 64
+)
+
+// inlineAnswer сохраненный ответ на inline-запрос
+type inlineAnswer struct {
+	results    []interface{}
+	nextOffset string
+	expires    time.Time
+}
+
+// handleInlineQuery отвечает на запрос «@бот фраза» из любого чата. Фраза
+// разбирается так же, как в /search, регион и город берутся из подписки
+// пользователя. Ответ отправляется после паузы в наборе текста
+func (b *Bot) handleInlineQuery(ctx context.Context, inline *tgbotapi.InlineQuery) {
+	userID := inline.From.ID
+	b.mu.Lock()
+	b.inlineLatest[userID] = inline.ID
+	b.mu.Unlock()
+
+	go func() {
+		select {
+		case <-time.After(inlineDebounce):
+		case <-ctx.Done():
+			return
+		}
+
+		b.mu.RLock()
+		latest := b.inlineLatest[userID] == inline.ID
+		b.mu.RUnlock()
+		// Пользователь продолжил печатать: на устаревший запрос Telegram ответа не ждет
+		if !latest {
+			return
+		}
+		b.answerInline(ctx, inline)
+	}()
+}
+
+// answerInline отправляет результаты inline-запроса. Если результатов нет,
+// над списком показывается кнопка с подсказкой, открывающая чат с ботом
+func (b *Bot) answerInline(ctx context.Context, inline *tgbotapi.InlineQuery) {
+	key := fmt.Sprintf("%d|%s|%s", inline.From.ID, strings.Join(strings.Fields(textmatch.Fold(inline.Query)), " "), inline.Offset)
+
+	config := tgbotapi.InlineConfig{
+		InlineQueryID: inline.ID,
+		Results:       []interface{}{},
+		CacheTime:     int(inlineCacheTTL.Seconds()),
+		IsPersonal:    true, // Регион и скрытые объявления у каждого пользователя свои
+	}
+
+	answer, hint := b.inlineCached(key), ""
+	if answer == nil {
+		if answer, hint = b.inlineSearch(ctx, inline); answer != nil {
+			b.setInlineCached(key, answer)
+		}
+	}
+	if answer != nil {
+		config.Results = answer.results
+		config.NextOffset = answer.nextOffset
+		if len(answer.results) == 0 && inline.Offset == "" {
+			hint = "Ничего не найдено"
+		}
+	}
+	if hint != "" {
+		config.SwitchPMText = truncate(hint, maxSwitchPMText)
+		config.SwitchPMParameter = "inline"
+		config.CacheTime = 0
+	}
+
+	if _, err := b.api.Request(config); err != nil {
+		log.Printf("Failed to answer inline query from %d: %v", inline.From.ID, err)
+	}
+}
+
+// inlineAllowed сообщает, можно ли отправить inline-запрос в API: остаток
+// лимита до его сброса не меньше доли, оставляемой мониторингу
+func inlineAllowed(budget inpars.RateBudget, now time.Time) bool {
+	if !budget.Known() || !now.Before(budget.Reset) {
+		return true
+	}
+	return budget.Remaining >= inlineReserve(budget.Limit)
+}
+
+// inlineReserve возвращает, сколько запросов из лимита оставляется мониторингу
+func inlineReserve(limit int) int {
+	return max(limit/inlineReserveShare, 1)
+}
+
+// inlineSearch запрашивает объявления по фразе. Страницы идут от недавно
+// обновленных к более старым, смещение - timeEnd следующей страницы, как
+// в /search. Если ответить не удалось, возвращает подсказку для пользователя
+func (b *Bot) inlineSearch(ctx context.Context, inline *tgbotapi.InlineQuery) (*inlineAnswer, string) {
+	if budget := b.client.RateBudget(); !inlineAllowed(budget, time.Now()) {
+		log.Printf("Skipping inline query from %d: %d API requests left", inline.From.ID, budget.Remaining)
+		return nil, "Много запросов, попробуйте через минуту"
+	}
+
+	// В личном чате ID чата совпадает с ID пользователя
+	userID := inline.From.ID
+	_, base, ok := b.chatSubscription(userID, "")
+	if !ok {
+		base = b.subs.Defaults(userID)
+	}
+	sub := base
+	if strings.TrimSpace(inline.Query) != "" {
+		var err error
+		if sub, err = b.searchArgs(ctx, base, inline.Query); err != nil {
+			return nil, err.Error()
+		}
+	}
+
+	params := sub.Params()
+	params.Expand = append([]string(nil), inpars.DefaultExpand...)
+	params.Limit = inlineBatch
+	params.SortBy = "updated_desc"
+	if inline.Offset != "" {
+		timeEnd, err := strconv.ParseInt(inline.Offset, 10, 64)
+		if err != nil || timeEnd <= 0 {
+			return &inlineAnswer{results: []interface{}{}}, ""
+		}
+		params.TimeEnd = timeEnd
+	}
+
+	resp, err := b.client.GetEstateListContext(ctx, params)
+	if err != nil {
+		log.Printf("Failed to search estates for inline query from %d: %v", userID, err)
+		return nil, "Не удалось выполнить поиск, попробуйте позже"
+	}
+
+	// Объявления на границе страниц откладываются до следующей, поэтому ID
+	// результатов разных страниц не повторяются
+	estates, timeEnd := updatedPage(resp.Data, len(resp.Data) == params.Limit)
+	answer := &inlineAnswer{results: []interface{}{}}
+	for i := range estates {
+		estate := &estates[i]
+		if sub.Matches(estate) && !b.IsHidden(userID, estate.ID) {
+			answer.results = append(answer.results, b.inlineResult(userID, estate))
+		}
+	}
+	if timeEnd > 0 {
+		answer.nextOffset = strconv.FormatInt(timeEnd, 10)
+	}
+	return answer, ""
+}
+
+// inlineResult возвращает карточку объявления для inline-режима: фото с
+// подписью, если фото есть, пользователь их не отключил и карточка помещается
+// в подпись, иначе текст с миниатюрой. Кнопки действий в чужом чате не
+// работают, поэтому у карточки только ссылки
+func (b *Bot) inlineResult(userID int64, estate *inpars.Estate) interface{} {
+	id := strconv.Itoa(estate.ID)
+	// Расстояния до мест пользователя в общий чат не попадают
+	text := b.formatEstateMessage(0, estate)
+	description := inlineDescription(estate)
+	keyboard := inlineKeyboard(estate)
+
+	photos := b.albumPhotos(userID, estate)
+	if len(photos) > 0 && captionLength(text) <= maxCaptionLength {
+		photo := tgbotapi.NewInlineQueryResultPhotoWithThumb(id, photos[0], photos[0])
+		photo.Title = estate.Title
+		photo.Description = description
+		photo.Caption = text
+		photo.ParseMode = "HTML"
+		photo.ReplyMarkup = keyboard
+		return photo
+	}
+
+	article := tgbotapi.NewInlineQueryResultArticleHTML(id, estate.Title, text)
+	article.Description = description
+	if len(photos) > 0 {
+		article.ThumbURL = photos[0]
+	}
+	article.ReplyMarkup = keyboard
+	return article
+}
+
+// inlineDescription возвращает строку под заголовком результата: цена, метро или адрес
+func inlineDescription(estate *inpars.Estate) string {
+	parts := []string{estate.FormatCost()}
+	if estate.Sq > 0 {
+		parts = append(parts, fmt.Sprintf("%.0f м²", estate.Sq))
+	}
+	if estate.Metro != "" {
+		parts = append(parts, "м. "+estate.Metro)
+	} else if estate.Address != "" {
+		parts = append(parts, estate.Address)
+	}
+	return strings.Join(parts, " · ")
+}
+
+// inlineKeyboard возвращает кнопки-ссылки карточки или nil, если ссылок нет
+func inlineKeyboard(estate *inpars.Estate) *tgbotapi.InlineKeyboardMarkup {
+	var row []tgbotapi.InlineKeyboardButton
+	if estate.URL != "" {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonURL("🔗 Объявление", estate.URL))
+	}
+	if estate.Lat != 0 || estate.Lng != 0 {
+		row = append(row, tgbotapi.NewInlineKeyboardButtonURL("🗺 Карта", mapURL(estate)))
+	}
+	if len(row) == 0 {
+		return nil
+	}
+	keyboard := tgbotapi.NewInlineKeyboardMarkup(row)
+	return &keyboard
+}
+
+func (b *Bot) inlineCached(key string) *inlineAnswer {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	answer := b.inlineCache[key]
+	if answer == nil || time.Now().After(answer.expires) {
+		return nil
+	}
+	return answer
+}
+
+// setInlineCached сохраняет ответ и удаляет устаревшие
+func (b *Bot) setInlineCached(key string, answer *inlineAnswer) {
+	now := time.Now()
+	answer.expires = now.Add(inlineCacheTTL)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for k, cached := range b.inlineCache {
+		if now.After(cached.expires) {
+			delete(b.inlineCache, k)
+		}
+	}
+	b.inlineCache[key] = answer
+}
@@ -0,0 +1,54 @@
+package telegram
+
+import (
+	"testing"
+	"time"
+
+	"github.com/RedNessen/inpars-telegram-bot/internal/inpars"
+)
+
+func TestInlineReserve(t *testing.T) {
+	tests := []struct {
+		limit int
+		want  int
+	}{
+		{0, 1},
+		{3, 1},
+		{5, 1},
+		{10, 2},
+		{60, 12},
+		{1000, 200},
+	}
+	for _, tt := range tests {
+		if got := inlineReserve(tt.limit); got != tt.want {
+			t.Errorf("inlineReserve(%d) = %d, want %d", tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestInlineAllowed(t *testing.T) {
+	now := time.Date(2025, 10, 18, 12, 0, 0, 0, time.UTC)
+	reset := now.Add(time.Minute)
+
+	tests := []struct {
+		name   string
+		budget inpars.RateBudget
+		want   bool
+	}{
+		{"лимит неизвестен", inpars.RateBudget{}, true},
+		{"большой остаток", inpars.RateBudget{Limit: 60, Remaining: 40, Reset: reset, Updated: now}, true},
+		{"остаток равен резерву", inpars.RateBudget{Limit: 60, Remaining: 12, Reset: reset, Updated: now}, true},
+		{"остаток меньше резерва", inpars.RateBudget{Limit: 60, Remaining: 11, Reset: reset, Updated: now}, false},
+		{"лимит уже сброшен", inpars.RateBudget{Limit: 60, Remaining: 0, Reset: now, Updated: now}, true},
+		// При маленьком лимите резерв - один запрос, а не весь лимит
+		{"маленький лимит", inpars.RateBudget{Limit: 5, Remaining: 1, Reset: reset, Updated: now}, true},
+		{"маленький лимит исчерпан", inpars.RateBudget{Limit: 5, Remaining: 0, Reset: reset, Updated: now}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := inlineAllowed(tt.budget, now); got != tt.want {
+				t.Errorf("inlineAllowed(%+v) = %v, want %v", tt.budget, got, tt.want)
+			}
+		})
+	}
+}
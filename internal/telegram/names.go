@@ -0,0 +1,124 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+)
+
+// resolveNames заменяет названия в ключах region, city и metro аргументов /sub
+// на ID по справочнику: region=питер, city=Зеленоградск, metro=парк_культуры.
+// Пробел в названии заменяется на «_». Города ищутся в регионах подписки,
+// станции - в ее первом регионе и городе. Регион и город должны определяться
+// однозначно, а название станции может подходить к нескольким станциям
+func (b *Bot) resolveNames(ctx context.Context, chatID int64, args string) (string, error) {
+	fields := strings.Fields(args)
+	index := make(map[string]int) // Позиция последнего ключа в fields
+	for i := 1; i < len(fields); i++ {
+		if key, _, ok := strings.Cut(fields[i], "="); ok {
+			index[strings.ToLower(key)] = i
+		}
+	}
+
+	defaults := b.subs.Defaults(chatID)
+	regions, cities := defaults.RegionIDs, defaults.CityIDs
+
+	if i, ok := index["region"]; ok {
+		var err error
+		fields[i], regions, err = resolveField(fields[i], true, func(name string) ([]int, []string, error) {
+			found, err := b.ref.FindRegions(ctx, name)
+			ids, titles := make([]int, len(found)), make([]string, len(found))
+			for j, region := range found {
+				ids[j], titles[j] = region.ID, region.Title
+			}
+			return ids, titles, err
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if i, ok := index["city"]; ok {
+		searchRegions := regions
+		if len(searchRegions) == 0 {
+			searchRegions = []int{0} // Все регионы
+		}
+		var err error
+		fields[i], cities, err = resolveField(fields[i], true, func(name string) ([]int, []string, error) {
+			var ids []int
+			var titles []string
+			for _, regionID := range searchRegions {
+				found, err := b.ref.FindCities(ctx, regionID, name)
+				if err != nil {
+					return nil, nil, err
+				}
+				for _, city := range found {
+					ids, titles = append(ids, city.ID), append(titles, city.Title)
+				}
+			}
+			return ids, titles, nil
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if i, ok := index["metro"]; ok {
+		var err error
+		fields[i], _, err = resolveField(fields[i], false, func(name string) ([]int, []string, error) {
+			found, err := b.ref.FindMetro(ctx, firstInt(regions), firstInt(cities), name)
+			ids, titles := make([]int, len(found)), make([]string, len(found))
+			for j, station := range found {
+				ids[j], titles[j] = station.ID, station.Title
+			}
+			return ids, titles, err
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return strings.Join(fields, " "), nil
+}
+
+// resolveField заменяет названия в значении «ключ=a,b» на ID. Если single,
+// название должно соответствовать одному элементу справочника. Возвращает
+// новое поле и все ID значения
+func resolveField(field string, single bool, find func(name string) ([]int, []string, error)) (string, []int, error) {
+	key, value, _ := strings.Cut(field, "=")
+
+	var ids []int
+	var parts []string
+	for _, part := range strings.Split(value, ",") {
+		if id, err := strconv.Atoi(part); err == nil {
+			ids = append(ids, id)
+			parts = append(parts, part)
+			continue
+		}
+		if part == "" {
+			// Пустые значения оставляются для проверки в subscription.Parse
+			parts = append(parts, part)
+			continue
+		}
+
+		name := strings.ReplaceAll(part, "_", " ")
+		found, titles, err := find(name)
+		if err != nil {
+			log.Printf("Failed to look up %s %q: %v", key, name, err)
+			return "", nil, fmt.Errorf("%s: не удалось загрузить справочник, попробуйте позже", key)
+		}
+		switch {
+		case len(found) == 0:
+			return "", nil, fmt.Errorf("%s: «%s» нет в справочнике", key, name)
+		case single && len(found) > 1:
+			return "", nil, fmt.Errorf("%s: «%s» подходит к нескольким: %s", key, name, strings.Join(titles, ", "))
+		}
+		for _, id := range found {
+			ids = append(ids, id)
+			parts = append(parts, strconv.Itoa(id))
+		}
+	}
+	return key + "=" + strings.Join(parts, ","), ids, nil
+}
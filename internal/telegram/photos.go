@@ -0,0 +1,218 @@
+package telegram
+
+import (
+	"fmt"
+	"html"
+	"log"
+	"strings"
+	"unicode/utf16"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/RedNessen/inpars-telegram-bot/internal/dedup"
+	"github.com/RedNessen/inpars-telegram-bot/internal/inpars"
+	"github.com/RedNessen/inpars-telegram-bot/internal/storage"
+)
+
+const (
+	// maxAlbumPhotos максимальное количество фото в альбоме Telegram
+	maxAlbumPhotos = 10
+	// maxCaptionLength максимальная длина подписи к фото после разбора разметки
+	maxCaptionLength = 1024
+	// cardActionsText текст сообщения с кнопками под альбомом
+	cardActionsText = "⬆️ Действия с объявлением"
+)
+
+// sendCard отправляет карточку объявления с кнопками действий. Если у объявления
+// есть фото и чат их не отключил, фото отправляются альбомом с карточкой в
+// подписи. Telegram не позволяет прикреплять кнопки к альбому, поэтому они
+// отправляются следующим сообщением в ответ на альбом. Карточка, которая не
+// помещается в подпись, отправляется таким сообщением целиком. Если Telegram
+// не смог загрузить фото, карточка отправляется текстом
+func (b *Bot) sendCard(chatID int64, estate *inpars.Estate, text string) (dedup.Card, error) {
+	keyboard := b.cardKeyboard(chatID, estate)
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ParseMode = "HTML"
+	msg.ReplyMarkup = keyboard
+
+	photos := b.albumPhotos(chatID, estate)
+	fits := captionLength(text) <= maxCaptionLength
+	var err error
+	switch {
+	case len(photos) == 1 && fits:
+		photo := tgbotapi.NewPhoto(chatID, tgbotapi.FileURL(photos[0]))
+		photo.Caption = text
+		photo.ParseMode = "HTML"
+		photo.ReplyMarkup = keyboard
+
+		var sent tgbotapi.Message
+		if sent, err = b.api.Send(photo); err == nil {
+			return dedup.Card{MessageID: sent.MessageID, Caption: true}, nil
+		}
+		b.handleSendError(chatID, err)
+
+	case len(photos) > 1 && fits:
+		var albumID int
+		if albumID, err = b.sendAlbum(chatID, photos, text); err == nil {
+			actions := tgbotapi.NewMessage(chatID, cardActionsText)
+			actions.ReplyToMessageID = albumID
+			actions.ReplyMarkup = keyboard
+			// Карточка уже доставлена, без кнопок она остается подписью к альбому
+			keyboardID, err := b.sendMessage(actions)
+			if err != nil {
+				log.Printf("Failed to send actions of estate %d to %d: %v", estate.ID, chatID, err)
+			}
+			return dedup.Card{MessageID: albumID, Caption: true, KeyboardID: keyboardID}, nil
+		}
+
+	case len(photos) > 0:
+		var albumID int
+		if albumID, err = b.sendAlbum(chatID, photos, ""); err == nil {
+			msg.ReplyToMessageID = albumID
+			msg.DisableWebPagePreview = true // Фото уже есть в альбоме
+		}
+	}
+
+	if err != nil {
+		if isBlocked(err) {
+			return dedup.Card{}, fmt.Errorf("failed to send photos to %d: %w", chatID, err)
+		}
+		log.Printf("Failed to send photos of estate %d to %d, sending text only: %v", estate.ID, chatID, err)
+	}
+
+	messageID, err := b.sendMessage(msg)
+	return dedup.Card{MessageID: messageID}, err
+}
+
+// sendAlbum отправляет фото одним альбомом с подписью к первому фото и
+// возвращает ID первого сообщения. Альбом в Telegram содержит от 2 фото,
+// поэтому одно фото отправляется отдельно
+func (b *Bot) sendAlbum(chatID int64, photos []string, caption string) (int, error) {
+	if len(photos) == 1 {
+		photo := tgbotapi.NewPhoto(chatID, tgbotapi.FileURL(photos[0]))
+		photo.Caption = caption
+		photo.ParseMode = "HTML"
+
+		sent, err := b.api.Send(photo)
+		if err != nil {
+			b.handleSendError(chatID, err)
+			return 0, fmt.Errorf("failed to send photo to %d: %w", chatID, err)
+		}
+		return sent.MessageID, nil
+	}
+
+	media := make([]interface{}, len(photos))
+	for i, url := range photos {
+		photo := tgbotapi.NewInputMediaPhoto(tgbotapi.FileURL(url))
+		if i == 0 && caption != "" {
+			photo.Caption = caption
+			photo.ParseMode = "HTML"
+		}
+		media[i] = photo
+	}
+
+	messages, err := b.api.SendMediaGroup(tgbotapi.NewMediaGroup(chatID, media))
+	if err != nil {
+		b.handleSendError(chatID, err)
+		return 0, fmt.Errorf("failed to send album to %d: %w", chatID, err)
+	}
+	if len(messages) == 0 {
+		return 0, fmt.Errorf("empty album response for %d", chatID)
+	}
+	return messages[0].MessageID, nil
+}
+
+// albumPhotos возвращает ссылки на фото объявления для альбома или nil,
+// если чат отключил фото
+func (b *Bot) albumPhotos(chatID int64, estate *inpars.Estate) []string {
+	if b.chatSettings(chatID).NoPhotos {
+		return nil
+	}
+
+	var photos []string
+	seen := make(map[string]bool)
+	for _, url := range estate.Images {
+		url = strings.TrimSpace(url)
+		if seen[url] || !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+			continue
+		}
+		seen[url] = true
+		photos = append(photos, url)
+		if len(photos) == maxAlbumPhotos {
+			break
+		}
+	}
+	return photos
+}
+
+// captionLength возвращает длину текста так, как ее считает Telegram:
+// без HTML-тегов, с раскрытыми сущностями, в кодовых единицах UTF-16
+func captionLength(message string) int {
+	var sb strings.Builder
+	inTag := false
+	for _, r := range message {
+		switch {
+		case r == '<':
+			inTag = true
+		case r == '>' && inTag:
+			inTag = false
+		case !inTag:
+			sb.WriteRune(r)
+		}
+	}
+	return len(utf16.Encode([]rune(html.UnescapeString(sb.String()))))
+}
+
+// handlePhotos включает или отключает фото в уведомлениях чата
+func (b *Bot) handlePhotos(chatID int64, args string) {
+	var noPhotos bool
+	switch strings.ToLower(strings.TrimSpace(args)) {
+	case "on":
+		noPhotos = false
+	case "off":
+		noPhotos = true
+	default:
+		state := "включены"
+		if b.chatSettings(chatID).NoPhotos {
+			state = "отключены"
+		}
+		b.api.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Фото в уведомлениях %s.\n\n/photos on - присылать объявления альбомом с фото\n/photos off - присылать только текст", state)))
+		return
+	}
+
+	err := b.updateSettings(chatID, func(settings *storage.ChatSettings) {
+		settings.NoPhotos = noPhotos
+	})
+	if err != nil {
+		log.Printf("Failed to save settings of chat %d: %v", chatID, err)
+		b.api.Send(tgbotapi.NewMessage(chatID, "Не удалось сохранить настройку."))
+		return
+	}
+
+	if noPhotos {
+		b.api.Send(tgbotapi.NewMessage(chatID, "✅ Объявления будут приходить без фото."))
+	} else {
+		b.api.Send(tgbotapi.NewMessage(chatID, "✅ Объявления будут приходить альбомом с фото."))
+	}
+}
+
+// chatSettings возвращает настройки чата
+func (b *Bot) chatSettings(chatID int64) storage.ChatSettings {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.settings[chatID]
+}
+
+// updateSettings изменяет и сохраняет настройки чата
+func (b *Bot) updateSettings(chatID int64, update func(settings *storage.ChatSettings)) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	settings := b.settings[chatID]
+	update(&settings)
+	if err := b.store.SaveChatSettings(chatID, settings); err != nil {
+		return err
+	}
+	b.settings[chatID] = settings
+	return nil
+}
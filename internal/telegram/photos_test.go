@@ -0,0 +1,77 @@
+package telegram
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/RedNessen/inpars-telegram-bot/internal/inpars"
+	"github.com/RedNessen/inpars-telegram-bot/internal/storage"
+)
+
+func TestCaptionLength(t *testing.T) {
+	tests := []struct {
+		message string
+		want    int
+	}{
+		{"", 0},
+		{"abc", 3},
+		// Кириллица - одна кодовая единица UTF-16 на букву, а не два байта
+		{"квартира", 8},
+		// Теги не учитываются
+		{"<b>🏠 Студия</b>\n<a href=\"https://avito.ru/1\">ссылка</a>", 16},
+		// Сущности считаются одним символом
+		{"5 &lt; 7 &amp;&amp; 8 &gt; 2", 14},
+		// Эмодзи вне BMP занимают две кодовые единицы
+		{"🏠", 2},
+		{"📌 Сдам • Собственник", 21},
+		// Одиночная «>» вне тега остается текстом
+		{"a > b", 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.message, func(t *testing.T) {
+			if got := captionLength(tt.message); got != tt.want {
+				t.Errorf("captionLength(%q) = %d, want %d", tt.message, got, tt.want)
+			}
+		})
+	}
+
+	// Граница подписи Telegram: разметка не мешает карточке поместиться в подпись
+	text := "<b>" + strings.Repeat("я", maxCaptionLength) + "</b>"
+	if captionLength(text) > maxCaptionLength {
+		t.Errorf("captionLength of %d letters in tags = %d", maxCaptionLength, captionLength(text))
+	}
+}
+
+func TestAlbumPhotos(t *testing.T) {
+	b := &Bot{settings: map[int64]storage.ChatSettings{2: {NoPhotos: true}}}
+
+	estate := &inpars.Estate{Images: []string{
+		"https://img.avito.st/1.jpg",
+		" https://img.avito.st/2.jpg ",
+		"https://img.avito.st/1.jpg",
+		"ftp://img.avito.st/3.jpg",
+		"",
+		"http://cdn.cian.ru/4.jpg",
+	}}
+	want := []string{"https://img.avito.st/1.jpg", "https://img.avito.st/2.jpg", "http://cdn.cian.ru/4.jpg"}
+	if got := b.albumPhotos(1, estate); !reflect.DeepEqual(got, want) {
+		t.Errorf("albumPhotos = %v, want %v", got, want)
+	}
+
+	// Чат отключил фото
+	if got := b.albumPhotos(2, estate); got != nil {
+		t.Errorf("albumPhotos with photos off = %v, want nil", got)
+	}
+
+	// В альбоме не больше maxAlbumPhotos фото
+	estate.Images = nil
+	for i := 0; i < maxAlbumPhotos+5; i++ {
+		estate.Images = append(estate.Images, fmt.Sprintf("https://img.avito.st/%d.jpg", i))
+	}
+	if got := b.albumPhotos(1, estate); len(got) != maxAlbumPhotos {
+		t.Errorf("albumPhotos returned %d photos, want %d", len(got), maxAlbumPhotos)
+	}
+}
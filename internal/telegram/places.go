@@ -0,0 +1,270 @@
+package telegram
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/RedNessen/inpars-telegram-bot/internal/geo"
+	"github.com/RedNessen/inpars-telegram-bot/internal/inpars"
+	"github.com/RedNessen/inpars-telegram-bot/internal/places"
+	"github.com/RedNessen/inpars-telegram-bot/internal/subscription"
+)
+
+const (
+	// maxPlaceName ограничение длины имени точки
+	maxPlaceName = 32
+	// maxWalkDistance расстояние, дальше которого время пешком не показывается, м
+	maxWalkDistance = 5000
+)
+
+// handlePlaces показывает точки интереса чата или включает сортировку по расстоянию
+func (b *Bot) handlePlaces(chatID int64, args string) {
+	fields := strings.Fields(strings.ToLower(args))
+	if len(fields) == 2 && fields[0] == "sort" && (fields[1] == "on" || fields[1] == "off") {
+		enabled := fields[1] == "on"
+		err := b.places.Update(chatID, func(p *places.ChatPlaces) error {
+			p.SortByDistance = enabled
+			return nil
+		})
+		if err != nil {
+			log.Printf("Failed to save places of chat %d: %v", chatID, err)
+			b.api.Send(tgbotapi.NewMessage(chatID, "Не удалось сохранить настройку."))
+			return
+		}
+		if enabled {
+			b.api.Send(tgbotapi.NewMessage(chatID, "✅ Новые объявления будут приходить от ближних к дальним."))
+		} else {
+			b.api.Send(tgbotapi.NewMessage(chatID, "✅ Сортировка по расстоянию выключена."))
+		}
+		return
+	}
+
+	chatPlaces := b.places.ForChat(chatID)
+
+	var sb strings.Builder
+	if len(chatPlaces.Places) == 0 {
+		sb.WriteString("У вас нет сохраненных мест.\n")
+	} else {
+		sb.WriteString("🎯 Ваши места:\n")
+		for _, place := range chatPlaces.Places {
+			sb.WriteString(fmt.Sprintf("\n• %s - %.5f, %.5f", place.Name, place.Point.Lat, place.Point.Lng))
+			if place.MaxDistance > 0 {
+				sb.WriteString(fmt.Sprintf(", не дальше %s", formatDistance(place.MaxDistance)))
+			}
+		}
+		if chatPlaces.SortByDistance {
+			sb.WriteString("\n\nОбъявления сортируются по расстоянию.")
+		}
+		sb.WriteString("\n")
+	}
+
+	sb.WriteString(`
+В карточках объявлений показывается расстояние до мест и примерное время в пути.
+
+/place имя - Добавить или перенести место (бот попросит геопозицию)
+/place имя 55.7558,37.6173 - Задать место координатами
+/place имя max 3км - Присылать только объявления не дальше 3 км от места
+/place имя max off - Снять ограничение
+/place имя off - Удалить место
+/places sort on|off - Сортировать новые объявления по расстоянию`)
+
+	b.api.Send(tgbotapi.NewMessage(chatID, sb.String()))
+}
+
+// handlePlace добавляет, изменяет или удаляет точку интереса чата
+func (b *Bot) handlePlace(chatID int64, args string) {
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		b.handlePlaces(chatID, "")
+		return
+	}
+
+	name := fields[0]
+	if len([]rune(name)) > maxPlaceName {
+		b.api.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Имя места не должно быть длиннее %d символов.", maxPlaceName)))
+		return
+	}
+
+	switch {
+	case len(fields) == 1:
+		b.setGeoRequest(chatID, &geoRequest{placeName: name})
+
+		msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("📍 Отправьте геопозицию места «%s»: кнопкой ниже или через 📎 → Геопозиция (можно выбрать точку на карте).", name))
+		keyboard := tgbotapi.NewReplyKeyboard(tgbotapi.NewKeyboardButtonRow(
+			tgbotapi.NewKeyboardButtonLocation("📍 Отправить мою геопозицию"),
+		))
+		keyboard.OneTimeKeyboard = true
+		msg.ReplyMarkup = keyboard
+		b.api.Send(msg)
+
+	case len(fields) == 2 && strings.EqualFold(fields[1], "off"):
+		b.deletePlace(chatID, name)
+
+	case strings.EqualFold(fields[1], "max"):
+		limit := 0.0
+		text := strings.Join(fields[2:], " ")
+		if !strings.EqualFold(text, "off") {
+			radius, err := parseRadius(text)
+			if err != nil {
+				b.api.Send(tgbotapi.NewMessage(chatID, err.Error()))
+				return
+			}
+			limit = radius
+		}
+		b.setPlaceLimit(chatID, name, limit)
+
+	default:
+		point, err := parsePoint(strings.Join(fields[1:], " "))
+		if err != nil {
+			b.api.Send(tgbotapi.NewMessage(chatID, err.Error()))
+			return
+		}
+		b.savePlace(chatID, name, point)
+	}
+}
+
+// savePlace добавляет точку или переносит существующую
+func (b *Bot) savePlace(chatID int64, name string, point geo.Point) {
+	added := false
+	err := b.places.Update(chatID, func(p *places.ChatPlaces) error {
+		if i := p.Find(name); i >= 0 {
+			p.Places[i].Point = point
+			return nil
+		}
+		p.Places = append(p.Places, places.Place{Name: name, Point: point})
+		added = true
+		return nil
+	})
+	if errors.Is(err, places.ErrTooManyPlaces) {
+		b.sendWithoutKeyboard(chatID, fmt.Sprintf("Можно сохранить не больше %d мест. Удалите лишнее: /place имя off", places.MaxPerChat))
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to save places of chat %d: %v", chatID, err)
+		b.sendWithoutKeyboard(chatID, "Не удалось сохранить место.")
+		return
+	}
+
+	action := "перенесено"
+	if added {
+		action = "сохранено"
+	}
+	b.sendWithoutKeyboard(chatID, fmt.Sprintf("✅ Место «%s» %s: %.5f, %.5f.\nРасстояние до него будет в карточках объявлений.", name, action, point.Lat, point.Lng))
+}
+
+// deletePlace удаляет точку чата
+func (b *Bot) deletePlace(chatID int64, name string) {
+	found := false
+	err := b.places.Update(chatID, func(p *places.ChatPlaces) error {
+		if i := p.Find(name); i >= 0 {
+			p.Places = append(p.Places[:i], p.Places[i+1:]...)
+			found = true
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to save places of chat %d: %v", chatID, err)
+		b.api.Send(tgbotapi.NewMessage(chatID, "Не удалось удалить место."))
+		return
+	}
+	if !found {
+		b.api.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Место «%s» не найдено. Список мест: /places", name)))
+		return
+	}
+	b.api.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("🗑 Место «%s» удалено.", name)))
+}
+
+// setPlaceLimit задает максимальное расстояние от точки (0 - без ограничения)
+func (b *Bot) setPlaceLimit(chatID int64, name string, limit float64) {
+	found := false
+	err := b.places.Update(chatID, func(p *places.ChatPlaces) error {
+		if i := p.Find(name); i >= 0 {
+			p.Places[i].MaxDistance = limit
+			found = true
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to save places of chat %d: %v", chatID, err)
+		b.api.Send(tgbotapi.NewMessage(chatID, "Не удалось сохранить место."))
+		return
+	}
+	if !found {
+		b.api.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Место «%s» не найдено. Сначала добавьте его: /place %s", name, name)))
+		return
+	}
+
+	if limit > 0 {
+		b.api.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("✅ Буду присылать только объявления не дальше %s от места «%s».", formatDistance(limit), name)))
+	} else {
+		b.api.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("✅ Ограничение расстояния от места «%s» снято.", name)))
+	}
+}
+
+// formatPlaces форматирует расстояния от объявления до точек интереса чата
+func (b *Bot) formatPlaces(chatID int64, estate *inpars.Estate) string {
+	distances := b.places.ForChat(chatID).Distances(subscription.PointOf(estate))
+
+	var sb strings.Builder
+	for _, d := range distances {
+		sb.WriteString(fmt.Sprintf("🎯 %s: %s", html.EscapeString(d.Place.Name), formatDistance(d.Meters)))
+		if d.Meters <= maxWalkDistance {
+			sb.WriteString(fmt.Sprintf(" • 🚶 %s", formatMinutes(d.Walk)))
+		}
+		sb.WriteString(fmt.Sprintf(" • 🚗 %s\n", formatMinutes(d.Drive)))
+	}
+	return sb.String()
+}
+
+// formatDistance форматирует расстояние: "850 м", "2,3 км", "15 км"
+func formatDistance(meters float64) string {
+	switch {
+	case meters < 1000:
+		return fmt.Sprintf("%.0f м", meters)
+	case meters < 10000:
+		return strings.Replace(fmt.Sprintf("%.1f км", meters/1000), ".", ",", 1)
+	default:
+		return fmt.Sprintf("%.0f км", meters/1000)
+	}
+}
+
+// formatMinutes форматирует время в пути: "~25 мин", "~1 ч 10 мин"
+func formatMinutes(d time.Duration) string {
+	minutes := int(d.Minutes())
+	if minutes < 60 {
+		return fmt.Sprintf("~%d мин", minutes)
+	}
+	if minutes%60 == 0 {
+		return fmt.Sprintf("~%d ч", minutes/60)
+	}
+	return fmt.Sprintf("~%d ч %d мин", minutes/60, minutes%60)
+}
+
+// parsePoint разбирает координаты: "55.7558,37.6173" или "55.7558 37.6173"
+func parsePoint(text string) (geo.Point, error) {
+	parts := strings.FieldsFunc(text, func(r rune) bool { return r == ',' || r == ' ' || r == ';' })
+	errFormat := fmt.Errorf("Не удалось разобрать координаты. Пример: /place офис 55.7558,37.6173")
+	if len(parts) != 2 {
+		return geo.Point{}, errFormat
+	}
+
+	lat, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil {
+		return geo.Point{}, errFormat
+	}
+	lng, err := strconv.ParseFloat(parts[1], 64)
+	if err != nil {
+		return geo.Point{}, errFormat
+	}
+	if lat < -90 || lat > 90 || lng < -180 || lng > 180 {
+		return geo.Point{}, fmt.Errorf("Координаты вне допустимого диапазона: широта от -90 до 90, долгота от -180 до 180.")
+	}
+	return geo.Point{Lat: lat, Lng: lng}, nil
+}
@@ -0,0 +1,72 @@
+package telegram
+
+import (
+	"testing"
+	"time"
+
+	"github.com/RedNessen/inpars-telegram-bot/internal/geo"
+)
+
+func TestFormatDistance(t *testing.T) {
+	tests := []struct {
+		meters float64
+		want   string
+	}{
+		{0, "0 м"},
+		{850.4, "850 м"},
+		{999, "999 м"},
+		{1000, "1,0 км"},
+		{2340, "2,3 км"},
+		{9949, "9,9 км"},
+		{15400, "15 км"},
+	}
+
+	for _, tt := range tests {
+		if got := formatDistance(tt.meters); got != tt.want {
+			t.Errorf("formatDistance(%v) = %q, want %q", tt.meters, got, tt.want)
+		}
+	}
+}
+
+func TestFormatMinutes(t *testing.T) {
+	tests := []struct {
+		d    time.Duration
+		want string
+	}{
+		{5 * time.Minute, "~5 мин"},
+		{59 * time.Minute, "~59 мин"},
+		{60 * time.Minute, "~1 ч"},
+		{70 * time.Minute, "~1 ч 10 мин"},
+		{125 * time.Minute, "~2 ч 5 мин"},
+	}
+
+	for _, tt := range tests {
+		if got := formatMinutes(tt.d); got != tt.want {
+			t.Errorf("formatMinutes(%s) = %q, want %q", tt.d, got, tt.want)
+		}
+	}
+}
+
+func TestParsePoint(t *testing.T) {
+	valid := []struct {
+		text string
+		want geo.Point
+	}{
+		{"55.7558,37.6173", geo.Point{Lat: 55.7558, Lng: 37.6173}},
+		{"55.7558, 37.6173", geo.Point{Lat: 55.7558, Lng: 37.6173}},
+		{"55.7558 37.6173", geo.Point{Lat: 55.7558, Lng: 37.6173}},
+		{"-33.9;18.4", geo.Point{Lat: -33.9, Lng: 18.4}},
+	}
+	for _, tt := range valid {
+		got, err := parsePoint(tt.text)
+		if err != nil || got != tt.want {
+			t.Errorf("parsePoint(%q) = %+v, %v, want %+v", tt.text, got, err, tt.want)
+		}
+	}
+
+	for _, text := range []string{"", "55.7558", "55,75,37", "север юг", "91,37", "55,181"} {
+		if _, err := parsePoint(text); err == nil {
+			t.Errorf("parsePoint(%q) succeeded, want error", text)
+		}
+	}
+}
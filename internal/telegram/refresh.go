@@ -0,0 +1,156 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/RedNessen/inpars-telegram-bot/internal/inpars"
+)
+
+const (
+	refreshPollInterval = 2 * time.Minute // Интервал проверки статуса задачи
+	refreshTimeout      = 24 * time.Hour  // Задача может выполняться до 24 часов
+	maxRefreshTrackers  = 20              // Сколько задач отслеживается одновременно
+)
+
+// handleRefresh отправляет ссылку на обновление и следит за задачей до ее завершения
+func (b *Bot) handleRefresh(ctx context.Context, chatID int64, args string) {
+	link := strings.TrimSpace(args)
+	if !isValidListingURL(link) {
+		b.api.Send(tgbotapi.NewMessage(chatID, "Укажите ссылку на объявление: /refresh https://www.avito.ru/..."))
+		return
+	}
+
+	resp, err := b.client.CreateTasksContext(ctx, []string{link})
+	if err != nil {
+		log.Printf("Failed to create refresh task for %s: %v", link, err)
+		b.api.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Не удалось отправить ссылку на обновление: %v", err)))
+		return
+	}
+	if len(resp.Data) == 0 {
+		b.api.Send(tgbotapi.NewMessage(chatID, "Сервис не вернул задачу на обновление."))
+		return
+	}
+
+	task := resp.Data[0]
+	log.Printf("Refresh task %d for %s: %s", task.ID, link, task.Status)
+
+	// Одну ссылку отслеживает одна горутина, сколько бы чатов ее ни ждали
+	start, tracked := false, false
+	if !task.IsFinished() {
+		start, tracked = b.waitRefresh(chatID, link)
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("🔄 Задача на обновление: %s\n%s", inpars.GetTaskStatusName(task.Status), task.Message))
+	if resp.Meta.UpdateLimit > 0 {
+		sb.WriteString(fmt.Sprintf("\n\nОсталось обновлений: %d из %d", resp.Meta.UpdateRemaining, resp.Meta.UpdateLimit))
+	}
+	switch {
+	case task.IsFinished():
+	case tracked:
+		sb.WriteString("\n\nПришлю объявление, когда задача завершится (обычно 5-10 минут).")
+	default:
+		sb.WriteString("\n\nСейчас отслеживается слишком много задач, проверьте объявление позже.")
+	}
+	b.api.Send(tgbotapi.NewMessage(chatID, sb.String()))
+
+	if task.IsFinished() {
+		b.finishRefresh(ctx, &task, chatID)
+		return
+	}
+
+	if start {
+		go b.trackRefresh(ctx, link)
+	}
+}
+
+// waitRefresh добавляет чат к ожидающим завершения задачи по ссылке link.
+// start сообщает, что ссылку еще никто не отслеживает и нужно запустить
+// trackRefresh; tracked равно false, если достигнут предел maxRefreshTrackers
+func (b *Bot) waitRefresh(chatID int64, link string) (start, tracked bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	chats, ok := b.refreshes[link]
+	if !ok && len(b.refreshes) >= maxRefreshTrackers {
+		return false, false
+	}
+	if !slices.Contains(chats, chatID) {
+		b.refreshes[link] = append(chats, chatID)
+	}
+	return !ok, true
+}
+
+// trackRefresh ждет завершения задачи и сообщает о результате всем ожидающим чатам
+func (b *Bot) trackRefresh(ctx context.Context, link string) {
+	ctx, cancel := context.WithTimeout(ctx, refreshTimeout)
+	defer cancel()
+
+	task, err := b.client.WaitTask(ctx, link, refreshPollInterval)
+
+	b.mu.Lock()
+	chats := b.refreshes[link]
+	delete(b.refreshes, link)
+	b.mu.Unlock()
+
+	if err != nil {
+		// При остановке бота просто прекращаем ожидание
+		if ctx.Err() == context.Canceled {
+			return
+		}
+		log.Printf("Failed to wait for refresh task %s: %v", link, err)
+		for _, chatID := range chats {
+			b.api.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Не дождался завершения задачи на обновление %s: %v", link, err)))
+		}
+		return
+	}
+
+	b.finishRefresh(ctx, task, chats...)
+}
+
+// finishRefresh отправляет обновленное объявление или сообщение об ошибке задачи
+func (b *Bot) finishRefresh(ctx context.Context, task *inpars.Task, chatIDs ...int64) {
+	log.Printf("Refresh task %d for %s finished: %s", task.ID, task.URL, task.Status)
+
+	if task.Status != inpars.TaskStatusCompleted {
+		for _, chatID := range chatIDs {
+			b.api.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("❌ Не удалось обновить объявление %s\n%s", task.URL, task.Message)))
+		}
+		return
+	}
+
+	estate, err := b.client.FindEstateByURL(ctx, task.URL)
+	if err != nil {
+		log.Printf("Failed to find refreshed estate %s: %v", task.URL, err)
+		for _, chatID := range chatIDs {
+			b.api.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("✅ Объявление обновлено, но получить его не удалось: %s", task.URL)))
+		}
+		return
+	}
+
+	for _, chatID := range chatIDs {
+		if err := b.SendEstate(chatID, estate, ""); err != nil {
+			log.Printf("Failed to send refreshed estate %d: %v", estate.ID, err)
+		}
+	}
+}
+
+// isValidListingURL проверяет, что строка похожа на ссылку на объявление
+func isValidListingURL(link string) bool {
+	if link == "" || len(link) > 1000 {
+		return false
+	}
+	u, err := url.Parse(link)
+	if err != nil {
+		return false
+	}
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
@@ -0,0 +1,69 @@
+package telegram
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/RedNessen/inpars-telegram-bot/internal/rule"
+)
+
+// ruleUsage подсказка по команде /rule
+const ruleUsage = `Использование:
+/rule имя выражение - задать правило подписки
+/rule имя - показать правило
+/rule имя off - удалить правило
+
+Пример: /rule default rooms >= 2 && cost / sq < 900 && agent == 0 && !contains(text, "посуточно")`
+
+// handleRule задает, показывает или удаляет правило подписки
+func (b *Bot) handleRule(chatID int64, args string) {
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		b.api.Send(tgbotapi.NewMessage(chatID, ruleUsage+"\n\n"+rule.Help()))
+		return
+	}
+
+	name := fields[0]
+	sub, ok := b.subs.Get(chatID, name)
+	if !ok {
+		b.api.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Подписка «%s» не найдена. Список подписок: /subs", name)))
+		return
+	}
+
+	expr := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(args), name))
+	switch {
+	case expr == "":
+		if sub.Rule == "" {
+			b.api.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("У подписки «%s» нет правила.\n\n%s", name, ruleUsage)))
+		} else {
+			b.api.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("📐 Правило подписки «%s»:\n%s", name, sub.Rule)))
+		}
+		return
+	case strings.EqualFold(expr, "off"):
+		expr = ""
+	default:
+		if _, err := rule.Compile(expr); err != nil {
+			b.api.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Ошибка в правиле: %v\n\nСправка по полям и функциям: /rule", err)))
+			return
+		}
+	}
+
+	sub = sub.Clone()
+	sub.Rule = expr
+	if err := b.subs.Put(sub); err != nil {
+		log.Printf("Failed to save rule of subscription %s: %v", sub.Key(), err)
+		b.api.Send(tgbotapi.NewMessage(chatID, "Не удалось сохранить правило."))
+		return
+	}
+
+	if expr == "" {
+		log.Printf("Removed rule of subscription %s", sub.Key())
+		b.api.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("🗑 Правило подписки «%s» удалено.", name)))
+		return
+	}
+	log.Printf("Saved rule of subscription %s: %s", sub.Key(), expr)
+	b.api.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("✅ Правило подписки «%s» сохранено.", name)))
+}
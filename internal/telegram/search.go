@@ -0,0 +1,399 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/RedNessen/inpars-telegram-bot/internal/dedup"
+	"github.com/RedNessen/inpars-telegram-bot/internal/inpars"
+	"github.com/RedNessen/inpars-telegram-bot/internal/query"
+	"github.com/RedNessen/inpars-telegram-bot/internal/subscription"
+)
+
+const (
+	// actionSearch префикс кнопок листания результатов /search.
+	// Аргумент - направление и курсор показанного объявления (см. searchCursor)
+	actionSearch = "srch"
+	// searchBatch сколько объявлений запрашивается за раз: часть из них
+	// может не пройти фильтры, которые API не поддерживает
+	searchBatch = 20
+	// searchMaxPages сколько запросов делается в поисках следующего подходящего объявления
+	searchMaxPages = 3
+	// searchTitle начало заголовка карточки результата. Фраза поиска идет
+	// в заголовке после двоеточия, по ней кнопки листания повторяют поиск
+	searchTitle = "🔎 Поиск"
+)
+
+// searchCursor показанный результат поиска. Результаты идут от недавно
+// обновленных к старым, а при одинаковом времени обновления - по убыванию ID,
+// поэтому время обновления и ID задают место объявления в выдаче.
+// Курсор хранится в данных кнопок листания, и поиск не зависит от состояния
+// бота: кнопки работают и после перезапуска
+type searchCursor struct {
+	updated int64 // Время обновления объявления в UNIX-time
+	id      int
+	pos     int // Номер объявления в выдаче
+	total   int // Количество объявлений по запросу к API, без учета остальных фильтров
+}
+
+// arg возвращает аргумент кнопки листания "направление:время:ID:номер:всего"
+func (c searchCursor) arg(direction string) string {
+	return fmt.Sprintf("%s:%d:%d:%d:%d", direction, c.updated, c.id, c.pos, c.total)
+}
+
+// parseSearchArg разбирает аргумент кнопки листания
+func parseSearchArg(arg string) (string, searchCursor, error) {
+	parts := strings.Split(arg, ":")
+	if len(parts) != 5 || parts[0] != "n" && parts[0] != "p" {
+		return "", searchCursor{}, fmt.Errorf("invalid search cursor %q", arg)
+	}
+	var values [4]int64
+	for i, part := range parts[1:] {
+		v, err := strconv.ParseInt(part, 10, 64)
+		if err != nil || v < 0 {
+			return "", searchCursor{}, fmt.Errorf("invalid search cursor %q", arg)
+		}
+		values[i] = v
+	}
+	c := searchCursor{updated: values[0], id: int(values[1]), pos: int(values[2]), total: int(values[3])}
+	if c.updated == 0 || c.pos < 1 {
+		return "", searchCursor{}, fmt.Errorf("invalid search cursor %q", arg)
+	}
+	return parts[0], c, nil
+}
+
+// older сообщает, идет ли объявление в выдаче после курсора
+func (c searchCursor) older(updated int64, id int) bool {
+	return updated < c.updated || updated == c.updated && id < c.id
+}
+
+// newer сообщает, идет ли объявление в выдаче перед курсором
+func (c searchCursor) newer(updated int64, id int) bool {
+	return updated > c.updated || updated == c.updated && id > c.id
+}
+
+// handleSearch ищет объявления по фильтрам подписки чата или по фразе
+// ("двушка у метро Сокол до 60 тыс") и показывает первое из них
+func (b *Bot) handleSearch(ctx context.Context, chatID int64, args string) {
+	phrase := strings.Join(strings.Fields(args), " ")
+	sub, err := b.searchFilters(ctx, chatID, phrase)
+	if err != nil {
+		b.api.Send(tgbotapi.NewMessage(chatID, err.Error()+"\n\nПример: /search двушка у метро Сокол до 60 тыс без комиссии"))
+		return
+	}
+
+	estate, total, err := b.searchNext(ctx, chatID, sub, nil, true)
+	if err != nil {
+		log.Printf("Failed to search estates for chat %d: %v", chatID, err)
+		b.api.Send(tgbotapi.NewMessage(chatID, "Не удалось выполнить поиск, попробуйте позже."))
+		return
+	}
+	if estate == nil {
+		b.api.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("🔎 Ничего не найдено: %s", sub.Describe())))
+		return
+	}
+
+	cursor := searchCursor{updated: updatedUnix(estate), id: estate.ID, pos: 1, total: total}
+	msg := tgbotapi.NewMessage(chatID, b.searchText(chatID, phrase, estate, cursor))
+	msg.ParseMode = "HTML"
+	msg.ReplyMarkup = b.searchKeyboard(chatID, estate, cursor)
+	if _, err := b.sendMessage(msg); err != nil {
+		log.Printf("Failed to send search result: %v", err)
+	}
+}
+
+// onSearch листает результаты поиска: "n" - следующее (более старое)
+// объявление, "p" - предыдущее. Фильтры поиска восстанавливаются из фразы
+// в заголовке карточки, а место в выдаче - из курсора в данных кнопки
+func (b *Bot) onSearch(ctx context.Context, query *tgbotapi.CallbackQuery, arg string) (string, bool) {
+	chatID := query.Message.Chat.ID
+	direction, cursor, err := parseSearchArg(arg)
+	if err != nil {
+		return "Кнопка больше не действует", false
+	}
+	older := direction == "n"
+	if !older && cursor.pos == 1 {
+		return "Это первое объявление", false
+	}
+
+	sub, err := b.searchFilters(ctx, chatID, searchPhrase(query.Message.Text))
+	if err != nil {
+		return "Не удалось повторить поиск, выполните /search снова", false
+	}
+
+	estate, _, err := b.searchNext(ctx, chatID, sub, &cursor, older)
+	if err != nil {
+		log.Printf("Failed to search estates for chat %d: %v", chatID, err)
+		return "Не удалось загрузить объявление, попробуйте позже", false
+	}
+	if estate == nil {
+		if older {
+			return "Больше объявлений нет", false
+		}
+		return "Это первое объявление", false
+	}
+
+	next := searchCursor{updated: updatedUnix(estate), id: estate.ID, pos: cursor.pos + 1, total: cursor.total}
+	if !older {
+		next.pos = max(cursor.pos-1, 1)
+	}
+	phrase := searchPhrase(query.Message.Text)
+	if err := b.editCardMessage(chatID, dedup.Card{MessageID: query.Message.MessageID}, b.searchText(chatID, phrase, estate, next), b.searchKeyboard(chatID, estate, next)); err != nil {
+		log.Printf("Failed to show search result in %d: %v", chatID, err)
+	}
+	return "", false
+}
+
+// searchFilters возвращает фильтры поиска: подписку чата (по умолчанию или
+// единственную), а если задана фраза - фильтры из фразы (см. searchArgs)
+func (b *Bot) searchFilters(ctx context.Context, chatID int64, phrase string) (*subscription.Subscription, error) {
+	_, base, ok := b.chatSubscription(chatID, "")
+	if !ok {
+		base = b.subs.Defaults(chatID)
+	}
+	if phrase == "" {
+		return base, nil
+	}
+	return b.searchArgs(ctx, base, phrase)
+}
+
+// searchNext находит ближайшее к курсору подходящее объявление: более старое
+// (older) или более новое. Без курсора ищется самое недавно обновленное.
+// Более старые объявления запрашиваются с sortBy=updated_desc и timeEnd,
+// более новые - с sortBy=updated_asc и timeStart, равными времени курсора.
+// Объявления, обновленные в одну секунду, API может разрезать между
+// страницами, поэтому на полной странице последняя секунда откладывается:
+// следующая страница начнется с нее и вернет ее целиком.
+// total - количество объявлений по запросу к API при поиске без курсора
+func (b *Bot) searchNext(ctx context.Context, chatID int64, sub *subscription.Subscription, cursor *searchCursor, older bool) (*inpars.Estate, int, error) {
+	params := sub.Params()
+	params.Expand = append([]string(nil), inpars.DefaultExpand...)
+	params.Limit = searchBatch
+	params.SortBy = "updated_desc"
+	if !older {
+		params.SortBy = "updated_asc"
+	}
+
+	total := 0
+	for page := 0; page < searchMaxPages; page++ {
+		if cursor != nil {
+			if older {
+				params.TimeEnd = cursor.updated
+			} else {
+				params.TimeStart = cursor.updated
+			}
+		}
+
+		resp, err := b.client.GetEstateListContext(ctx, params)
+		if err != nil {
+			return nil, 0, err
+		}
+		if page == 0 && cursor == nil {
+			total = resp.Meta.TotalCount
+		}
+
+		estates := resp.Data
+		sortByUpdated(estates, older)
+		full := len(estates) == params.Limit
+
+		var last int64
+		if len(estates) > 0 {
+			last = updatedUnix(&estates[len(estates)-1])
+		}
+		// Вся страница обновлена в одну секунду: дальше нее не продвинуться,
+		// остаток секунды пропускается
+		oneSecond := full && last > 0 && updatedUnix(&estates[0]) == last
+
+		for i := range estates {
+			estate := &estates[i]
+			updated := updatedUnix(estate)
+			if updated <= 0 || full && !oneSecond && updated == last {
+				continue
+			}
+			if cursor != nil && (older && !cursor.older(updated, estate.ID) || !older && !cursor.newer(updated, estate.ID)) {
+				continue
+			}
+			if sub.Matches(estate) && !b.IsHidden(chatID, estate.ID) {
+				return estate, total, nil
+			}
+		}
+
+		if !full || last <= 0 {
+			return nil, total, nil
+		}
+		// Следующая страница начинается с отложенной секунды целиком
+		switch {
+		case older && oneSecond:
+			cursor = &searchCursor{updated: last - 1, id: math.MaxInt}
+		case older:
+			cursor = &searchCursor{updated: last, id: math.MaxInt}
+		case oneSecond:
+			cursor = &searchCursor{updated: last + 1}
+		default:
+			cursor = &searchCursor{updated: last}
+		}
+	}
+	return nil, total, nil
+}
+
+// sortByUpdated упорядочивает страницу так, как идет выдача поиска: по времени
+// обновления и ID, от новых к старым (desc) или наоборот. Порядок объявлений,
+// обновленных в одну секунду, API не гарантирует
+func sortByUpdated(estates []inpars.Estate, desc bool) {
+	sort.SliceStable(estates, func(i, j int) bool {
+		ui, uj := updatedUnix(&estates[i]), updatedUnix(&estates[j])
+		if ui != uj {
+			return ui > uj == desc
+		}
+		return estates[i].ID > estates[j].ID == desc
+	})
+}
+
+// updatedPage возвращает объявления страницы, отсортированной по updated_desc,
+// и timeEnd следующей страницы (0 - страниц больше нет). Объявления, обновленные
+// в одну секунду с последним на полной странице, откладываются до следующей
+// страницы: она начнется с этой секунды, и они не потеряются между страницами
+func updatedPage(page []inpars.Estate, full bool) ([]inpars.Estate, int64) {
+	if !full || len(page) == 0 {
+		return page, 0
+	}
+
+	last := updatedUnix(&page[len(page)-1])
+	if last <= 0 {
+		return page, 0
+	}
+	end := len(page)
+	for end > 0 && updatedUnix(&page[end-1]) == last {
+		end--
+	}
+	if end == 0 {
+		// Вся страница обновлена в одну секунду - продолжаем с предыдущей
+		return page, last - 1
+	}
+	return page[:end], last
+}
+
+// updatedUnix возвращает время обновления объявления в UNIX-time или 0
+func updatedUnix(estate *inpars.Estate) int64 {
+	updated, err := estate.GetUpdatedTime()
+	if err != nil {
+		return 0
+	}
+	return updated.Unix()
+}
+
+// searchText форматирует карточку результата поиска
+func (b *Bot) searchText(chatID int64, phrase string, estate *inpars.Estate, cursor searchCursor) string {
+	return searchHeader(phrase, cursor) + "\n\n" + b.formatEstateMessage(chatID, estate)
+}
+
+// searchHeader возвращает заголовок карточки результата поиска. Фраза поиска
+// записывается в заголовок, чтобы кнопки листания могли повторить поиск
+func searchHeader(phrase string, cursor searchCursor) string {
+	header := "<b>" + searchTitle + "</b>"
+	if phrase != "" {
+		header += ": " + html.EscapeString(phrase)
+	}
+	header += fmt.Sprintf(" · объявление %d", cursor.pos)
+	if cursor.total > 0 {
+		header += fmt.Sprintf(" из ~%d", cursor.total)
+	}
+	return header
+}
+
+// searchPhrase извлекает фразу поиска из текста карточки результата
+func searchPhrase(text string) string {
+	header, _, _ := strings.Cut(text, "\n")
+	header, ok := strings.CutPrefix(header, searchTitle+": ")
+	if !ok {
+		return ""
+	}
+	if i := strings.LastIndex(header, " · объявление "); i >= 0 {
+		header = header[:i]
+	}
+	return strings.TrimSpace(header)
+}
+
+// searchKeyboard возвращает кнопки карточки с рядом листания
+func (b *Bot) searchKeyboard(chatID int64, estate *inpars.Estate, cursor searchCursor) tgbotapi.InlineKeyboardMarkup {
+	keyboard := b.cardKeyboard(chatID, estate)
+
+	var nav []tgbotapi.InlineKeyboardButton
+	if cursor.pos > 1 {
+		nav = append(nav, tgbotapi.NewInlineKeyboardButtonData("◀️", callbackData(actionSearch, cursor.arg("p"))))
+	}
+	nav = append(nav, tgbotapi.NewInlineKeyboardButtonData("▶️", callbackData(actionSearch, cursor.arg("n"))))
+	keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, nav)
+	return keyboard
+}
+
+// keepSearchRow переносит ряд листания результатов поиска из сообщения
+// в новые кнопки карточки, чтобы кнопки действий не убирали его
+func keepSearchRow(message *tgbotapi.Message, keyboard tgbotapi.InlineKeyboardMarkup) tgbotapi.InlineKeyboardMarkup {
+	if message.ReplyMarkup == nil {
+		return keyboard
+	}
+	for _, row := range message.ReplyMarkup.InlineKeyboard {
+		if len(row) > 0 && row[0].CallbackData != nil && strings.HasPrefix(*row[0].CallbackData, actionSearch+":") {
+			keyboard.InlineKeyboard = append(keyboard.InlineKeyboard, row)
+		}
+	}
+	return keyboard
+}
+
+// keepSearchTitle переносит заголовок результата поиска из сообщения в новый
+// текст карточки: по фразе в заголовке кнопки листания повторяют поиск
+func keepSearchTitle(message *tgbotapi.Message, text string) string {
+	header, _, _ := strings.Cut(message.Text, "\n")
+	rest, ok := strings.CutPrefix(header, searchTitle)
+	if !ok {
+		return text
+	}
+	return "<b>" + searchTitle + "</b>" + html.EscapeString(rest) + "\n\n" + text
+}
+
+// searchArgs применяет фразу /search к фильтрам подписки. Регион, город,
+// сделка и продавец берутся из подписки, если фраза их не задает
+func (b *Bot) searchArgs(ctx context.Context, base *subscription.Subscription, args string) (*subscription.Subscription, error) {
+	sub := &subscription.Subscription{
+		ChatID:      base.ChatID,
+		Name:        "search",
+		RegionIDs:   base.RegionIDs,
+		CityIDs:     base.CityIDs,
+		TypeAd:      base.TypeAd,
+		SellerTypes: base.SellerTypes,
+	}
+	sub.Filters.RentTime = base.Filters.RentTime
+
+	q, err := query.Parse(ctx, args, b.metroFinder(sub))
+	if err != nil {
+		return nil, err
+	}
+	q.Apply(sub)
+	return sub, nil
+}
+
+// metroFinder возвращает поиск станций метро в регионе и городе подписки
+// по справочнику (см. inpars.NameScore)
+func (b *Bot) metroFinder(sub *subscription.Subscription) query.MetroFinder {
+	return func(ctx context.Context, name string) ([]int, error) {
+		stations, err := b.ref.FindMetro(ctx, firstInt(sub.RegionIDs), firstInt(sub.CityIDs), name)
+		if err != nil {
+			log.Printf("Failed to load metro stations: %v", err)
+			return nil, fmt.Errorf("Не удалось загрузить станции метро, попробуйте позже")
+		}
+		ids := make([]int, len(stations))
+		for i, station := range stations {
+			ids[i] = station.ID
+		}
+		return ids, nil
+	}
+}
@@ -0,0 +1,164 @@
+package telegram
+
+import (
+	"html"
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/RedNessen/inpars-telegram-bot/internal/inpars"
+)
+
+func TestSearchCursorArg(t *testing.T) {
+	cursor := searchCursor{updated: 1760781600, id: 987654321, pos: 1234, total: 56789}
+	for _, direction := range []string{"n", "p"} {
+		data := callbackData(actionSearch, cursor.arg(direction))
+		// Telegram ограничивает данные кнопки 64 байтами
+		if len(data) > 64 {
+			t.Errorf("callback data %q is %d bytes, want at most 64", data, len(data))
+		}
+
+		_, arg, _ := strings.Cut(data, ":")
+		gotDirection, got, err := parseSearchArg(arg)
+		if err != nil {
+			t.Fatalf("parseSearchArg(%q) error: %v", arg, err)
+		}
+		if gotDirection != direction || got != cursor {
+			t.Errorf("parseSearchArg(%q) = %q, %+v, want %q, %+v", arg, gotDirection, got, direction, cursor)
+		}
+	}
+
+	// Максимальные значения тоже помещаются в данные кнопки
+	largest := searchCursor{updated: math.MaxInt32 * 2, id: math.MaxInt32, pos: 99999, total: 9999999}
+	if data := callbackData(actionSearch, largest.arg("n")); len(data) > 64 {
+		t.Errorf("callback data %q is %d bytes, want at most 64", data, len(data))
+	}
+}
+
+func TestParseSearchArgErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"n",
+		"n:1:2:3",
+		"x:1760781600:1:1:10",
+		"n:abc:1:1:10",
+		"n:1760781600:1:0:10",
+		"n:0:1:1:10",
+		"n:1760781600:-1:1:10",
+		"n:1760781600:1:1:10:1",
+		// Старый формат кнопок с номером в сохраненной выдаче
+		"n:3",
+	}
+	for _, arg := range tests {
+		t.Run(arg, func(t *testing.T) {
+			if _, _, err := parseSearchArg(arg); err == nil {
+				t.Errorf("parseSearchArg(%q) succeeded, want error", arg)
+			}
+		})
+	}
+}
+
+func TestSearchCursorOrder(t *testing.T) {
+	cursor := searchCursor{updated: 100, id: 50}
+	tests := []struct {
+		name    string
+		updated int64
+		id      int
+		older   bool
+		newer   bool
+	}{
+		{"обновлено раньше", 99, 1000, true, false},
+		{"обновлено позже", 101, 1, false, true},
+		{"та же секунда, меньше ID", 100, 49, true, false},
+		{"та же секунда, больше ID", 100, 51, false, true},
+		{"то же объявление", 100, 50, false, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cursor.older(tt.updated, tt.id); got != tt.older {
+				t.Errorf("older(%d, %d) = %v, want %v", tt.updated, tt.id, got, tt.older)
+			}
+			if got := cursor.newer(tt.updated, tt.id); got != tt.newer {
+				t.Errorf("newer(%d, %d) = %v, want %v", tt.updated, tt.id, got, tt.newer)
+			}
+		})
+	}
+}
+
+func TestSortByUpdated(t *testing.T) {
+	estates := []inpars.Estate{
+		{ID: 1, Updated: "2025-10-18T10:00:00+03:00"},
+		{ID: 3, Updated: "2025-10-18T12:00:00+03:00"},
+		{ID: 2, Updated: "2025-10-18T12:00:00+03:00"},
+		{ID: 4, Updated: "2025-10-18T11:00:00+03:00"},
+	}
+
+	sortByUpdated(estates, true)
+	if got := estateIDs(estates); got != "3 2 4 1" {
+		t.Errorf("desc order = %s, want 3 2 4 1", got)
+	}
+	sortByUpdated(estates, false)
+	if got := estateIDs(estates); got != "1 4 2 3" {
+		t.Errorf("asc order = %s, want 1 4 2 3", got)
+	}
+}
+
+func TestSearchPhrase(t *testing.T) {
+	cursor := searchCursor{updated: 1760781600, id: 1, pos: 2, total: 40}
+
+	tests := []string{
+		"",
+		"двушка у метро Сокол до 60 тыс",
+		"студия <от 30> & без комиссии",
+		// Разделитель из заголовка внутри фразы
+		"1-2к · м. Тульская",
+	}
+	for _, phrase := range tests {
+		t.Run(phrase, func(t *testing.T) {
+			// Telegram присылает текст сообщения без разметки
+			text := stripTags(searchHeader(phrase, cursor) + "\n\n2-к квартира")
+			if got := searchPhrase(text); got != phrase {
+				t.Errorf("searchPhrase(%q) = %q, want %q", text, got, phrase)
+			}
+
+			// Кнопки карточки сохраняют заголовок, и фраза не теряется
+			kept := stripTags(keepSearchTitle(&tgbotapi.Message{Text: text}, "🙈 <b>Скрыто:</b> 2-к квартира"))
+			if got := searchPhrase(kept); got != phrase {
+				t.Errorf("phrase after card edit = %q, want %q", got, phrase)
+			}
+		})
+	}
+
+	if got := keepSearchTitle(&tgbotapi.Message{Text: "2-к квартира"}, "текст"); got != "текст" {
+		t.Errorf("keepSearchTitle() for a notification card = %q, want text unchanged", got)
+	}
+}
+
+// estateIDs возвращает ID объявлений через пробел
+func estateIDs(estates []inpars.Estate) string {
+	ids := make([]string, len(estates))
+	for i, estate := range estates {
+		ids[i] = strconv.Itoa(estate.ID)
+	}
+	return strings.Join(ids, " ")
+}
+
+// stripTags убирает HTML-разметку так, как ее убирает Telegram
+func stripTags(text string) string {
+	var sb strings.Builder
+	inTag := false
+	for _, r := range text {
+		switch {
+		case r == '<':
+			inTag = true
+		case r == '>' && inTag:
+			inTag = false
+		case !inTag:
+			sb.WriteRune(r)
+		}
+	}
+	return html.UnescapeString(sb.String())
+}
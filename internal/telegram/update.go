@@ -0,0 +1,70 @@
+package telegram
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/RedNessen/inpars-telegram-bot/internal/inpars"
+)
+
+// EstateChange описывает изменения ранее отправленного объявления
+type EstateChange struct {
+	OldCost       int  // Цена на момент прошлого уведомления (0 - цена не изменилась)
+	NewCost       int  // Текущая цена
+	PhonesChanged bool // Изменились телефоны
+	Republished   bool // Объявление опубликовано заново
+	TextChanged   bool // Изменилось описание
+	Removed       bool // Объявление снято с публикации
+	Restored      bool // Снятое объявление снова доступно
+}
+
+// IsEmpty возвращает true, если изменений для уведомления нет
+func (c EstateChange) IsEmpty() bool {
+	return c.OldCost == 0 && !c.PhonesChanged && !c.Republished && !c.TextChanged && !c.Removed && !c.Restored
+}
+
+// SendEstateUpdate уведомляет чат об изменении ранее отправленного объявления
+func (b *Bot) SendEstateUpdate(chatID int64, estate *inpars.Estate, change EstateChange, subName string) error {
+	var sb strings.Builder
+
+	sb.WriteString("🔄 <b>Изменение в объявлении</b>\n")
+	sb.WriteString(formatChange(change))
+	sb.WriteString("\n")
+	sb.WriteString(b.formatEstateMessage(chatID, estate))
+	if subName != "" {
+		sb.WriteString(fmt.Sprintf("\n🔔 Подписка: %s", html.EscapeString(subName)))
+	}
+
+	msg := tgbotapi.NewMessage(chatID, sb.String())
+	msg.ParseMode = "HTML"
+	msg.ReplyMarkup = b.cardKeyboard(chatID, estate)
+	_, err := b.sendMessage(msg)
+	return err
+}
+
+// formatChange перечисляет изменения объявления по строке на каждое
+func formatChange(change EstateChange) string {
+	var sb strings.Builder
+	if change.Removed {
+		sb.WriteString("• ❌ Объявление снято с публикации\n")
+	}
+	if change.Restored {
+		sb.WriteString("• ✅ Объявление снова опубликовано\n")
+	}
+	if change.OldCost > 0 {
+		sb.WriteString(fmt.Sprintf("• Цена: %s\n", formatPriceChange(change.OldCost, change.NewCost)))
+	}
+	if change.PhonesChanged {
+		sb.WriteString("• 📞 Изменились телефоны\n")
+	}
+	if change.TextChanged {
+		sb.WriteString("• 📝 Изменилось описание\n")
+	}
+	if change.Republished {
+		sb.WriteString("• 🆕 Объявление опубликовано заново\n")
+	}
+	return sb.String()
+}
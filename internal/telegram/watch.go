@@ -0,0 +1,225 @@
+package telegram
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	tgbotapi "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+
+	"github.com/RedNessen/inpars-telegram-bot/internal/inpars"
+	"github.com/RedNessen/inpars-telegram-bot/internal/storage"
+)
+
+const (
+	// actionUnwatch префикс кнопки, прекращающей отслеживание объявления
+	actionUnwatch = "unwatch"
+	// maxWatchesPerChat сколько объявлений может отслеживать один чат:
+	// каждое из них - отдельный запрос к API при каждой проверке
+	maxWatchesPerChat = 20
+)
+
+// handleWatch начинает отслеживать объявление по ID или ссылке
+func (b *Bot) handleWatch(ctx context.Context, chatID int64, args string) {
+	arg := strings.TrimSpace(args)
+	if arg == "" {
+		b.api.Send(tgbotapi.NewMessage(chatID, "Укажите ID объявления или ссылку на него: /watch 123456 или /watch https://www.avito.ru/..."))
+		return
+	}
+
+	watches, err := b.chatWatches(chatID)
+	if err != nil {
+		log.Printf("Failed to load watches of chat %d: %v", chatID, err)
+		b.api.Send(tgbotapi.NewMessage(chatID, "Не удалось загрузить отслеживаемые объявления."))
+		return
+	}
+	if len(watches) >= maxWatchesPerChat {
+		b.api.Send(tgbotapi.NewMessage(chatID, fmt.Sprintf("Можно следить не больше чем за %d объявлениями. Уберите лишние в /watches", maxWatchesPerChat)))
+		return
+	}
+
+	estate, err := b.findEstate(ctx, arg)
+	if err != nil {
+		log.Printf("Failed to find estate %q to watch: %v", arg, err)
+		if inpars.IsNotFound(err) {
+			b.api.Send(tgbotapi.NewMessage(chatID, "Объявление не найдено или уже снято с публикации."))
+		} else {
+			b.api.Send(tgbotapi.NewMessage(chatID, "Не удалось получить объявление. Проверьте ID или ссылку и попробуйте позже."))
+		}
+		return
+	}
+
+	for _, watch := range watches {
+		if watch.Estate.ID == estate.ID {
+			b.api.Send(tgbotapi.NewMessage(chatID, "Это объявление уже отслеживается. Список: /watches"))
+			return
+		}
+	}
+
+	watch := &storage.WatchedEstate{ChatID: chatID, Estate: *estate, Saved: time.Now()}
+	if err := b.store.SaveWatch(watch); err != nil {
+		log.Printf("Failed to save watch of estate %d in chat %d: %v", estate.ID, chatID, err)
+		b.api.Send(tgbotapi.NewMessage(chatID, "Не удалось сохранить отслеживание."))
+		return
+	}
+
+	msg := tgbotapi.NewMessage(chatID, fmt.Sprintf("👁 Слежу за объявлением «%s» (%s).\nПришлю уведомление, если изменятся цена, телефоны или описание или объявление снимут с публикации. Список: /watches",
+		html.EscapeString(estate.Title), estate.FormatCost()))
+	msg.ParseMode = "HTML"
+	b.api.Send(msg)
+}
+
+// findEstate получает объявление по ID или по ссылке на источник
+func (b *Bot) findEstate(ctx context.Context, arg string) (*inpars.Estate, error) {
+	if isValidListingURL(arg) {
+		return b.client.FindEstateByURL(ctx, arg)
+	}
+
+	id, err := strconv.Atoi(arg)
+	if err != nil || id <= 0 {
+		return nil, fmt.Errorf("invalid estate id %q", arg)
+	}
+	resp, err := b.client.GetEstateContext(ctx, id, inpars.DefaultExpand...)
+	if err != nil {
+		return nil, err
+	}
+	return &resp.Data, nil
+}
+
+// sendWatches отправляет список отслеживаемых объявлений с кнопками удаления.
+// Если messageID не равен нулю, список изменяется на месте
+func (b *Bot) sendWatches(chatID int64, messageID int) {
+	watches, err := b.chatWatches(chatID)
+	if err != nil {
+		log.Printf("Failed to load watches of chat %d: %v", chatID, err)
+		b.api.Send(tgbotapi.NewMessage(chatID, "Не удалось загрузить отслеживаемые объявления."))
+		return
+	}
+
+	var text string
+	var rows [][]tgbotapi.InlineKeyboardButton
+	if len(watches) == 0 {
+		text = "Вы не следите ни за одним объявлением. Начать: /watch ID или /watch ссылка"
+	} else {
+		var sb strings.Builder
+		sb.WriteString(fmt.Sprintf("👁 <b>Отслеживаемые объявления</b> (%d):\n", len(watches)))
+		for i, watch := range watches {
+			estate := &watch.Estate
+			title := html.EscapeString(estate.Title)
+			if estate.URL != "" {
+				title = fmt.Sprintf("<a href=\"%s\">%s</a>", estate.URL, title)
+			}
+			status := estate.FormatCost()
+			if watch.Removed {
+				status = "❌ снято с публикации"
+			}
+			sb.WriteString(fmt.Sprintf("\n%d. %s\n💰 %s • ID %d\n", i+1, title, status, estate.ID))
+
+			label := fmt.Sprintf("❌ %d. %s", i+1, truncate(estate.Title, 40))
+			rows = append(rows, tgbotapi.NewInlineKeyboardRow(
+				tgbotapi.NewInlineKeyboardButtonData(label, callbackData(actionUnwatch, strconv.Itoa(estate.ID))),
+			))
+		}
+		sb.WriteString("\nНажмите на кнопку, чтобы перестать следить за объявлением.")
+		text = sb.String()
+	}
+
+	var keyboard *tgbotapi.InlineKeyboardMarkup
+	if len(rows) > 0 {
+		markup := tgbotapi.NewInlineKeyboardMarkup(rows...)
+		keyboard = &markup
+	}
+
+	if messageID != 0 {
+		edit := tgbotapi.NewEditMessageText(chatID, messageID, text)
+		edit.ParseMode = "HTML"
+		edit.DisableWebPagePreview = true
+		edit.ReplyMarkup = keyboard
+		if _, err := b.api.Send(edit); err != nil && !isNotModified(err) {
+			log.Printf("Failed to edit watches of chat %d: %v", chatID, err)
+		}
+		return
+	}
+
+	msg := tgbotapi.NewMessage(chatID, text)
+	msg.ParseMode = "HTML"
+	msg.DisableWebPagePreview = true
+	if keyboard != nil {
+		msg.ReplyMarkup = *keyboard
+	}
+	if _, err := b.sendMessage(msg); err != nil {
+		log.Printf("Failed to send watches: %v", err)
+	}
+}
+
+// onUnwatch прекращает отслеживание объявления и обновляет список
+func (b *Bot) onUnwatch(_ context.Context, query *tgbotapi.CallbackQuery, arg string) (string, bool) {
+	id, err := strconv.Atoi(arg)
+	if err != nil {
+		return "Кнопка больше не действует", false
+	}
+
+	chatID := query.Message.Chat.ID
+	deleted, err := b.store.DeleteWatch(chatID, id)
+	if err != nil {
+		log.Printf("Failed to delete watch of estate %d in chat %d: %v", id, chatID, err)
+		return "Не удалось изменить список", true
+	}
+
+	b.sendWatches(chatID, query.Message.MessageID)
+	if !deleted {
+		return "Объявление уже не отслеживается", false
+	}
+	return "Больше не слежу за объявлением", false
+}
+
+// chatWatches возвращает отслеживаемые объявления чата в порядке добавления
+func (b *Bot) chatWatches(chatID int64) ([]*storage.WatchedEstate, error) {
+	all, err := b.store.Watches()
+	if err != nil {
+		return nil, err
+	}
+
+	var result []*storage.WatchedEstate
+	for _, watch := range all {
+		if watch.ChatID == chatID {
+			result = append(result, watch)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Saved.Before(result[j].Saved) })
+	return result, nil
+}
+
+// SendWatchUpdate уведомляет чат об изменении объявления, за которым он следит
+func (b *Bot) SendWatchUpdate(chatID int64, estate *inpars.Estate, change EstateChange) error {
+	var sb strings.Builder
+
+	sb.WriteString("👁 <b>Изменение в отслеживаемом объявлении</b>\n")
+	sb.WriteString(formatChange(change))
+	sb.WriteString("\n")
+	sb.WriteString(b.formatEstateMessage(chatID, estate))
+	sb.WriteString("\nСписок отслеживаемых: /watches")
+
+	msg := tgbotapi.NewMessage(chatID, sb.String())
+	msg.ParseMode = "HTML"
+	// Кнопки снятого объявления не работают: запросить его заново нельзя
+	if !change.Removed {
+		msg.ReplyMarkup = b.cardKeyboard(chatID, estate)
+	}
+	_, err := b.sendMessage(msg)
+	return err
+}
+
+// truncate обрезает строку до limit символов, не разрывая UTF-8
+func truncate(s string, limit int) string {
+	runes := []rune(s)
+	if len(runes) <= limit {
+		return s
+	}
+	return string(runes[:limit-1]) + "…"
+}
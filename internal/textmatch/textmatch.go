@@ -0,0 +1,187 @@
+// Package textmatch ищет ключевые слова в тексте объявлений с учетом
+// особенностей русского языка: регистра, ё/е и словоформ
+package textmatch
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Fold приводит текст к нижнему регистру и заменяет ё на е
+func Fold(text string) string {
+	return strings.ReplaceAll(strings.ToLower(text), "ё", "е")
+}
+
+// Words разбивает текст на основы слов
+func Words(text string) []string {
+	fields := strings.FieldsFunc(Fold(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for i, word := range fields {
+		fields[i] = Stem(word)
+	}
+	return fields
+}
+
+// endings окончания, которые отбрасываются при поиске основы, от длинных к коротким
+var endings = []string{
+	"иями", "ями", "ами", "ыми", "ими", "ого", "его", "ому", "ему", "ться", "тся",
+	"ешь", "ете", "ите", "ишь", "ях", "ах", "ых", "их", "ым", "ая", "яя", "ое", "ее",
+	"ые", "ие", "ый", "ий", "ой", "ей", "ую", "юю", "ом", "ем", "ам", "ям",
+	"ов", "ев", "ия", "ье", "ют", "ут", "ит", "ат", "ят", "ет", "ть",
+	"а", "я", "о", "е", "ы", "и", "у", "ю", "ь", "й",
+}
+
+// minStem минимальная длина основы: короткие слова («без», «до») не сокращаются
+const minStem = 3
+
+// Stem отбрасывает окончание слова, чтобы разные словоформы совпадали:
+// «животными», «животных», «животные» → «животн»
+func Stem(word string) string {
+	for _, ending := range endings {
+		if strings.HasSuffix(word, ending) && utf8.RuneCountInString(word)-utf8.RuneCountInString(ending) >= minStem {
+			return strings.TrimSuffix(word, ending)
+		}
+	}
+	return word
+}
+
+// Phrase ключевое слово или фраза из нескольких слов
+type Phrase struct {
+	Text  string
+	stems []string
+}
+
+// NewPhrase подготавливает фразу для поиска
+func NewPhrase(text string) Phrase {
+	return Phrase{Text: text, stems: Words(text)}
+}
+
+// In проверяет, встречается ли фраза в тексте, разбитом функцией Words.
+// Слова фразы должны идти подряд
+func (p Phrase) In(words []string) bool {
+	if len(p.stems) == 0 {
+		return false
+	}
+	for i := 0; i+len(p.stems) <= len(words); i++ {
+		match := true
+		for j, stem := range p.stems {
+			if words[i+j] != stem {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+	return false
+}
+
+// Matcher набор условий по ключевым словам и регулярным выражениям
+type Matcher struct {
+	any          []Phrase
+	all          []Phrase
+	exclude      []Phrase
+	regex        []*regexp.Regexp
+	excludeRegex []*regexp.Regexp
+}
+
+// Rules условия для Matcher в исходном виде
+type Rules struct {
+	Any          []string // Должна встретиться хотя бы одна фраза
+	All          []string // Должны встретиться все фразы
+	Exclude      []string // Не должна встретиться ни одна фраза
+	Regex        []string // Должно совпасть каждое выражение
+	ExcludeRegex []string // Не должно совпасть ни одно выражение
+}
+
+// Compile проверяет регулярные выражения и подготавливает фразы.
+// Выражения применяются к тексту после Fold и не зависят от регистра,
+// ё в выражении заменяется на е
+func Compile(rules Rules) (*Matcher, error) {
+	m := &Matcher{
+		any:     phrases(rules.Any),
+		all:     phrases(rules.All),
+		exclude: phrases(rules.Exclude),
+	}
+
+	var err error
+	if m.regex, err = compileRegexps(rules.Regex); err != nil {
+		return nil, err
+	}
+	if m.excludeRegex, err = compileRegexps(rules.ExcludeRegex); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Match проверяет тексты (заголовок, описание, адрес) по всем условиям
+func (m *Matcher) Match(texts ...string) bool {
+	text := strings.Join(texts, "\n")
+	words := Words(text)
+	folded := Fold(text)
+
+	if len(m.any) > 0 && !anyIn(m.any, words) {
+		return false
+	}
+	for _, p := range m.all {
+		if !p.In(words) {
+			return false
+		}
+	}
+	if anyIn(m.exclude, words) {
+		return false
+	}
+
+	for _, re := range m.regex {
+		if !re.MatchString(folded) {
+			return false
+		}
+	}
+	for _, re := range m.excludeRegex {
+		if re.MatchString(folded) {
+			return false
+		}
+	}
+	return true
+}
+
+func phrases(texts []string) []Phrase {
+	var result []Phrase
+	for _, text := range texts {
+		if p := NewPhrase(text); len(p.stems) > 0 {
+			result = append(result, p)
+		}
+	}
+	return result
+}
+
+func anyIn(phrases []Phrase, words []string) bool {
+	for _, p := range phrases {
+		if p.In(words) {
+			return true
+		}
+	}
+	return false
+}
+
+func compileRegexps(patterns []string) ([]*regexp.Regexp, error) {
+	var result []*regexp.Regexp
+	for _, pattern := range patterns {
+		re, err := regexp.Compile("(?i)" + foldPattern(pattern))
+		if err != nil {
+			return nil, fmt.Errorf("некорректное регулярное выражение %q: %w", pattern, err)
+		}
+		result = append(result, re)
+	}
+	return result, nil
+}
+
+// foldPattern заменяет в регулярном выражении ё на е в обоих регистрах, как Fold в тексте
+func foldPattern(pattern string) string {
+	return strings.NewReplacer("ё", "е", "Ё", "Е").Replace(pattern)
+}
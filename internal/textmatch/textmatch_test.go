@@ -0,0 +1,111 @@
+package textmatch
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStem(t *testing.T) {
+	tests := []struct {
+		word string
+		want string
+	}{
+		{"животными", "животн"},
+		{"животных", "животн"},
+		{"животные", "животн"},
+		{"животным", "животн"},
+		{"балкон", "балкон"},
+		{"балконом", "балкон"},
+		{"балкона", "балкон"},
+		{"кухня", "кухн"},
+		{"кухней", "кухн"},
+		{"кухни", "кухн"},
+		// Короткие слова не сокращаются
+		{"без", "без"},
+		{"до", "до"},
+		{"дом", "дом"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.word, func(t *testing.T) {
+			if got := Stem(tt.word); got != tt.want {
+				t.Errorf("Stem(%q) = %q, want %q", tt.word, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWords(t *testing.T) {
+	got := Words("Ёлка, БАЛКОНОМ; 2-к.")
+	want := []string{"елк", "балкон", "2", "к"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Words = %q, want %q", got, want)
+	}
+}
+
+func TestPhraseIn(t *testing.T) {
+	text := Words("Квартира с ремонтом. Можно с животными и детьми")
+
+	tests := []struct {
+		phrase string
+		want   bool
+	}{
+		{"ремонт", true},
+		{"можно с животными", true},
+		{"МОЖНО С ЖИВОТНЫМ", true},
+		{"с животными можно", false}, // Слова фразы идут подряд и по порядку
+		{"можно детьми", false},
+		{"евроремонт", false},
+		{"", false},
+		{"!!!", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.phrase, func(t *testing.T) {
+			if got := NewPhrase(tt.phrase).In(text); got != tt.want {
+				t.Errorf("NewPhrase(%q).In = %v, want %v", tt.phrase, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatcher(t *testing.T) {
+	title := "2-к квартира, 54 м²"
+	text := "Евроремонт, есть балкон. Без животных"
+	address := "Москва, ул. Лётная, 5"
+
+	tests := []struct {
+		name  string
+		rules Rules
+		want  bool
+	}{
+		{"без условий", Rules{}, true},
+		{"любое из слов", Rules{Any: []string{"лоджия", "балконом"}}, true},
+		{"ни одного из слов", Rules{Any: []string{"лоджия", "терраса"}}, false},
+		{"все слова", Rules{All: []string{"балкон", "евроремонт"}}, true},
+		{"не все слова", Rules{All: []string{"балкон", "лоджия"}}, false},
+		{"исключение", Rules{Exclude: []string{"без животных"}}, false},
+		{"исключение не встретилось", Rules{Exclude: []string{"посуточно"}}, true},
+		{"адрес с ё", Rules{All: []string{"летная"}}, true},
+		{"регулярное выражение по заголовку", Rules{Regex: []string{`^\d-к`}}, true},
+		{"регулярное выражение без учета регистра и ё", Rules{Regex: []string{"ЛЁТНАЯ"}}, true},
+		{"каждое регулярное выражение", Rules{Regex: []string{"балкон", "лоджия"}}, false},
+		{"исключающее регулярное выражение", Rules{ExcludeRegex: []string{"евро.?ремонт"}}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := Compile(tt.rules)
+			if err != nil {
+				t.Fatalf("Compile error: %v", err)
+			}
+			if got := m.Match(title, text, address); got != tt.want {
+				t.Errorf("Match = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := Compile(Rules{ExcludeRegex: []string{"("}}); err == nil {
+		t.Errorf("Compile with invalid regexp succeeded")
+	}
+}